@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib" // pgxドライバー
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 
+	"anime-score-backend/internal/cache"
 	"anime-score-backend/internal/handlers"
+	"anime-score-backend/internal/mail"
 	"anime-score-backend/internal/middlewares"
+	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/repositories"
 	"anime-score-backend/internal/services"
 )
@@ -43,27 +53,115 @@ func main() {
 	// Ginルーターのセットアップ
 	r := gin.Default()
 
+	// クライアントのIPアドレス（ログインの失敗回数の記録に使う）を X-Forwarded-For から取り出してよいプロキシ
+	// TRUSTED_PROXIES: カンマ区切りのIPアドレス・CIDR (例: BFF のアドレス 172.16.0.0/12)
+	// 未設定ならどのプロキシも信頼せず、接続元のアドレスを使う（X-Forwarded-For を偽装して失敗回数の制限を逃れられないようにする）
+	var trustedProxies []string
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		trustedProxies = strings.Split(v, ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalln("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// CORSミドルウェアの適用
 	r.Use(middlewares.CORSMiddleware())
 
 	// 依存関係の注入 (DI)
 
 	// 認証関連
+	// メールは MAIL_DRIVER=smtp ならSMTPサーバーから送り、それ以外は送らずに内容を出力する（開発用）
+	mailer, err := loadMailSender()
+	if err != nil {
+		log.Fatalln("Failed to configure mail sender:", err)
+	}
 	userRepo := repositories.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo)
+	tokenRevocationRepo := repositories.NewTokenRevocationRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	tokenConfig := loadTokenConfig()
+	signingKeyRepo := repositories.NewSigningKeyRepository(db)
+	signingKeys, err := services.NewSigningKeyManager(signingKeyRepo, loadSigningKeyConfig(tokenConfig.AccessTTL))
+	if err != nil {
+		log.Fatalln("Failed to load JWT signing keys:", err)
+	}
+	// ユーザー登録時に、メールアドレスの確認用のリンクを送る
+	emailVerificationConfig, err := loadEmailVerificationConfig()
+	if err != nil {
+		log.Fatalln("Failed to configure email verification:", err)
+	}
+	emailVerificationService := services.NewEmailVerificationService(userRepo, mailer, emailVerificationConfig)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService)
+	// 総当たり攻撃への対策として、ログインの試行を記録し、失敗が続いたらログインを制限する
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db)
+	loginAttemptService := services.NewLoginAttemptService(loginAttemptRepo, loadLoginThrottleConfig())
+	authService := services.NewAuthService(
+		userRepo, tokenRevocationRepo, refreshTokenRepo, signingKeys, emailVerificationService, loginAttemptService, tokenConfig,
+	)
 	authHandler := handlers.NewAuthHandler(authService)
+	adminHandler := handlers.NewAdminHandler(loginAttemptService)
+
+	// パスワード再設定関連
+	passwordResetRepo := repositories.NewPasswordResetRepository(db)
+	passwordResetConfig := loadPasswordResetConfig()
+	passwordResetService := services.NewPasswordResetService(
+		userRepo, passwordResetRepo, authService, loginAttemptService, mailer, passwordResetConfig,
+	)
+	passwordHandler := handlers.NewPasswordHandler(passwordResetService)
+	// 再設定メールの送信依頼は、同じIPアドレスから短い間に何度も受け付けない
+	passwordResetLimiter := middlewares.NewRateLimiter(passwordResetConfig.RateLimit, passwordResetConfig.RateWindow)
 
 	// アニメ検索関連
-	annictRepo := repositories.NewAnnictRepository(os.Getenv("ANNICT_ACCESS_TOKEN"))
+	// 同じ検索でAnnictに何度も問い合わせないように、検索結果をプロセス内にキャッシュする
+	// cache.Cache を満たせば Redis などの外部キャッシュにも差し替えられる
+	annictConfig := loadAnnictClientConfig()
+	var searchCache cache.Cache
+	if annictConfig.SearchCacheTTL > 0 && annictConfig.SearchCacheSize > 0 {
+		searchCache = cache.NewLRU(annictConfig.SearchCacheSize)
+	}
+	annictRepo := repositories.NewAnnictRepository(os.Getenv("ANNICT_ACCESS_TOKEN"), annictConfig, searchCache)
 	animeRepo := repositories.NewAnimeRepository(db)
-	animeService := services.NewAnimeService(annictRepo, animeRepo)
+	// 作品を対応付けるほかの取得元（ANILIST_ENABLED=true のときだけAniListを使う）
+	var crossProviders []repositories.MetadataProvider
+	if os.Getenv("ANILIST_ENABLED") == "true" {
+		crossProviders = append(crossProviders, repositories.NewAniListRepository(loadAniListClientConfig()))
+	}
+	animeService := services.NewAnimeService(annictRepo, crossProviders, animeRepo, loadRankingOptions())
 	animeHandler := handlers.NewAnimeHandler(animeService)
 
+	// キャッシュ済みアニメ情報の定期的な再取得（バックグラウンド）
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshWorker := services.NewAnimeRefreshWorker(animeService, loadAnimeRefreshConfig())
+	go refreshWorker.Run(ctx)
+
+	// 新しく保存したアニメとほかの取得元（AniListなど）の対応付け（バックグラウンド）
+	go animeService.RunExternalIDLinker(ctx)
+
+	// 期限切れになったトークンの失効情報・パスワード再設定用のトークンと、古いログインの試行履歴の定期的な削除（バックグラウンド）
+	tokenCleanupWorker := services.NewTokenCleanupWorker(authService, passwordResetService, loginAttemptService, loadTokenCleanupInterval())
+	go tokenCleanupWorker.Run(ctx)
+
+	// パスワード再設定メールの送信（バックグラウンド）
+	go passwordResetService.Run(ctx)
+
+	// JWTの署名鍵の定期的な交換と、ほかのプロセスが作った鍵の読み込み（バックグラウンド）
+	go signingKeys.Run(ctx)
+
 	// レビュー関連
 	reviewRepo := repositories.NewReviewRepository(db)
 	reviewService := services.NewReviewService(reviewRepo, animeService)
 	reviewHandler := handlers.NewReviewHandler(reviewService)
 
+	// エピソード関連
+	episodeRepo := repositories.NewEpisodeRepository(db)
+	episodeService := services.NewEpisodeService(episodeRepo, annictRepo, animeService)
+	episodeHandler := handlers.NewEpisodeHandler(episodeService)
+
+	// 視聴ステータス（ライブラリ）関連
+	libraryRepo := repositories.NewLibraryRepository(db)
+	libraryService := services.NewLibraryService(libraryRepo, animeService)
+	libraryHandler := handlers.NewLibraryHandler(libraryService)
+
 	// ルーティング
 	// 階層をずらさなくても動作はするが、可読性のためにインデントをつけている
 	// また、Goでは{}で囲むとスコープが作られるため、誤って変数が外に漏れるのを防げる
@@ -72,42 +170,119 @@ func main() {
 		api.POST("/signup", authHandler.Signup)
 		api.POST("/login", authHandler.Login)
 
-		// アニメ一覧平均点順取得エンドポイント (GET /api/animes)
+		// アクセストークンの再発行エンドポイント (POST /api/token/refresh)
+		// アクセストークンの期限が切れていても使えるように、認証ミドルウェアは通さない
+		api.POST("/token/refresh", authHandler.Refresh)
+
+		// ログアウトエンドポイント (POST /api/logout)
+		// 使っていたトークンを失効させる
+		// アクセストークンの期限が切れていてもリフレッシュトークンだけでログアウトできるように、任意認証にする
+		api.POST("/logout", middlewares.OptionalAuthMiddleware(authService), authHandler.Logout)
+
+		// パスワード再設定 (POST /api/password/forgot, POST /api/password/reset)
+		// forgot で再設定用のリンクをメールで送り、reset でリンクのトークンと新しいパスワードを受け取る
+		api.POST("/password/forgot", middlewares.RateLimitMiddleware(passwordResetLimiter), passwordHandler.Forgot)
+		api.POST("/password/reset", passwordHandler.Reset)
+
+		// メールアドレスの確認 (POST /api/email/verify)
+		// メールのリンクから開いたページがトークンを送る（ログインしていなくても使える）
+		api.POST("/email/verify", emailVerificationHandler.Verify)
+
+		// アニメ一覧ランキング取得エンドポイント
+		// (GET /api/animes?sort=average|bayesian|wilson&year=2024&season=spring&yearFrom=2020&yearTo=2024)
 		api.GET("/animes", animeHandler.GetList)
 
 		// アニメ検索エンドポイント (GET /api/animes/search?q=xxx&limit=20&cursor=xxx)
-		api.GET("/animes/search", animeHandler.Search)
+		// ログインしていれば、検索結果に自分のスコアも含める
+		api.GET("/animes/search", middlewares.OptionalAuthMiddleware(authService), animeHandler.Search)
 
 		// 新着レビュー一覧取得エンドポイント (GET /api/reviews/recent)
 		api.GET("/reviews/recent", reviewHandler.ListRecent)
 
-		// 特定のアニメのレビュー取得エンドポイント (GET /api/reviews?animeId=xxx)
+		// 特定のアニメのレビュー取得エンドポイント
+		// (GET /api/reviews?anime_id=xxx&sort=newest&limit=20&cursor=xxx&has_comment=true&min_score=0&max_score=100)
 		api.GET("/reviews", reviewHandler.ListByAnime)
 
+		// レビューの編集履歴取得エンドポイント (GET /api/reviews/:id/revisions)
+		api.GET("/reviews/:id/revisions", reviewHandler.ListRevisions)
+
+		// 取得元での作品IDからのアニメ取得エンドポイント
+		// (GET /api/animes/external/:provider/:externalId  provider = annict | anilist)
+		api.GET("/animes/external/:provider/:externalId", animeHandler.GetByExternalID)
+
 		// アニメ詳細取得エンドポイント (GET /api/animes/:id)
 		api.GET("/animes/:id", animeHandler.GetDetail)
 
+		// エピソード一覧取得エンドポイント (GET /api/animes/:id/episodes)
+		api.GET("/animes/:id/episodes", episodeHandler.ListByAnime)
+
 		// 認証が必要なエンドポイント
 		authorized := api.Group("")
-		authorized.Use(middlewares.AuthMiddleware())
+		authorized.Use(middlewares.AuthMiddleware(authService))
 		{
 			// レビュー投稿 (POST /api/reviews)
-			authorized.POST("/reviews", reviewHandler.Create)
+			// EMAIL_VERIFICATION_REQUIRED_FOR_REVIEWS=true なら、メールアドレスを確認していないユーザーは投稿できない
+			requireVerifiedEmail := middlewares.VerifiedEmailMiddleware(emailVerificationService, emailVerificationConfig.RequiredForReviews)
+			authorized.POST("/reviews", requireVerifiedEmail, reviewHandler.Create)
+
+			// レビュー編集・削除 (PUT/DELETE /api/reviews/:id)
+			authorized.PUT("/reviews/:id", reviewHandler.Update)
+			authorized.DELETE("/reviews/:id", reviewHandler.Delete)
+
+			// 「参考になった」の付与・取り消し (PUT/DELETE /api/reviews/:id/helpful)
+			authorized.PUT("/reviews/:id/helpful", reviewHandler.MarkHelpful)
+			authorized.DELETE("/reviews/:id/helpful", reviewHandler.UnmarkHelpful)
 
 			// マイページ用エンドポイント (GET /api/me/reviews)
 			authorized.GET("/me/reviews", reviewHandler.ListByMe)
 
-			// ログアウトエンドポイント (POST /api/logout)
-			authorized.POST("/logout", authHandler.Logout)
+			// 視聴ステータス（ライブラリ）
+			// (GET /api/me/library?status=watching|completed|dropped|plan_to_watch)
+			// (PUT/DELETE /api/me/library/:annictId)
+			// 視聴履歴のまとめて取り込み (POST /api/me/library/import)
+			authorized.GET("/me/library", libraryHandler.List)
+			authorized.POST("/me/library/import", libraryHandler.Import)
+			authorized.PUT("/me/library/:annictId", libraryHandler.SetStatus)
+			authorized.DELETE("/me/library/:annictId", libraryHandler.RemoveStatus)
+
+			// エピソードの視聴記録 (PUT/DELETE /api/episodes/:id/watch)
+			authorized.PUT("/episodes/:id/watch", episodeHandler.RecordWatch)
+			authorized.DELETE("/episodes/:id/watch", episodeHandler.RemoveWatch)
+
+			// 自分の視聴済みエピソード (GET /api/me/animes/:annictId/episodes)
+			authorized.GET("/me/animes/:annictId/episodes", episodeHandler.ListMyWatches)
+
+			// すべての端末からログアウト (POST /api/logout/all)
+			// これまでに発行したトークンをすべて失効させる
+			authorized.POST("/logout/all", authHandler.LogoutAll)
+
+			// 確認メールの再送 (POST /api/email/verification/resend)
+			authorized.POST("/email/verification/resend", emailVerificationHandler.Resend)
+
+			// 管理者用エンドポイント
+			admin := authorized.Group("/admin")
+			admin.Use(middlewares.AdminMiddleware(authService))
+			{
+				// ログインの試行履歴 (GET /api/admin/login-attempts?email=xxx&ip=xxx&user_id=1&result=invalid_credentials&limit=50&cursor=xxx)
+				admin.GET("/login-attempts", adminHandler.ListLoginAttempts)
+			}
 		}
 	}
 
+	// トークン検証用の公開鍵の一覧 (GET /.well-known/jwks.json)
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// ヘルスチェック用エンドポイント
+	// 検索結果のキャッシュのヒット率なども返す
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"status": "ok",
 			"db":     "connected",
-		})
+		}
+		if searchCache != nil {
+			response["annictSearchCache"] = searchCache.Stats()
+		}
+		c.JSON(http.StatusOK, response)
 	})
 
 	// サーバー起動
@@ -121,3 +296,383 @@ func main() {
 		log.Fatalln("Failed to start server:", err)
 	}
 }
+
+// loadRankingOptions はアニメ一覧ランキングの設定を環境変数から読み込む
+// RANKING_DEFAULT_SORT: sort未指定時の並び順 (average / bayesian / wilson, デフォルト average)
+// RANKING_MIN_VOTES: ベイズ平均の最低得票数 (デフォルト 10)
+// RANKING_WILSON_Z: Wilsonスコアの z 値 (デフォルト 1.96 = 信頼水準95%)
+func loadRankingOptions() models.RankingOptions {
+	ranking := models.RankingOptions{
+		Sort:     models.AnimeSortAverage,
+		MinVotes: 10,
+		Z:        1.96,
+	}
+
+	switch sort := os.Getenv("RANKING_DEFAULT_SORT"); sort {
+	case "":
+	case models.AnimeSortAverage, models.AnimeSortBayesian, models.AnimeSortWilson:
+		ranking.Sort = sort
+	default:
+		log.Printf("Unknown RANKING_DEFAULT_SORT %q, using %q", sort, ranking.Sort)
+	}
+	if v, err := strconv.Atoi(os.Getenv("RANKING_MIN_VOTES")); err == nil && v >= 0 {
+		ranking.MinVotes = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("RANKING_WILSON_Z"), 64); err == nil && v > 0 {
+		ranking.Z = v
+	}
+
+	return ranking
+}
+
+// loadAnimeRefreshConfig はアニメ情報の再取得処理の設定を環境変数から読み込む
+// ANIME_REFRESH_INTERVAL: 再取得処理の実行間隔 (例: 1h, 0で無効, デフォルト 1h)
+// ANIME_REFRESH_STALE_AFTER: 最後の同期からこの時間が経ったら再取得する (デフォルト 168h = 7日)
+// ANIME_REFRESH_BATCH_SIZE: 1回の処理で再取得する最大件数 (デフォルト 50)
+// ANIME_REFRESH_CONCURRENCY: 再取得したアニメの保存・ほかの取得元との対応付けの同時実行数 (デフォルト 4)
+func loadAnimeRefreshConfig() models.AnimeRefreshConfig {
+	config := models.AnimeRefreshConfig{
+		Interval:    time.Hour,
+		StaleAfter:  7 * 24 * time.Hour,
+		BatchSize:   50,
+		Concurrency: 4,
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("ANIME_REFRESH_INTERVAL")); err == nil {
+		config.Interval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("ANIME_REFRESH_STALE_AFTER")); err == nil && v > 0 {
+		config.StaleAfter = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANIME_REFRESH_BATCH_SIZE")); err == nil && v > 0 {
+		config.BatchSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANIME_REFRESH_CONCURRENCY")); err == nil && v > 0 {
+		config.Concurrency = v
+	}
+
+	return config
+}
+
+// loadTokenConfig はトークンの有効期限の設定を環境変数から読み込む
+// ACCESS_TOKEN_TTL: アクセストークン（JWT）の有効期限 (デフォルト 15m)
+// REFRESH_TOKEN_TTL: リフレッシュトークンの有効期限 (デフォルト 720h = 30日)
+func loadTokenConfig() models.TokenConfig {
+	config := models.TokenConfig{
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && v > 0 {
+		config.AccessTTL = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && v > 0 {
+		config.RefreshTTL = v
+	}
+
+	return config
+}
+
+// loadSigningKeyConfig はJWTの署名鍵の設定を環境変数から読み込む
+// accessTTL はアクセストークンの有効期限で、交換した古い鍵はそれより長く検証用に残す
+// JWT_ALGORITHM: 署名アルゴリズム (HS256 / RS256 / EdDSA, デフォルト HS256)
+// JWT_SECRET_KEY: HS256 の共通鍵 (HS256 以外では、切り替え前に発行したトークンの検証だけに使う)
+// JWT_KEY_ENCRYPTION_KEY: DBに保存する秘密鍵を暗号化する鍵 (base64 の32バイト, RS256 / EdDSA では必須)
+// JWT_KEY_ROTATION_INTERVAL: 署名鍵を新しくする間隔 (例: 720h, 0で自動では交換しない, デフォルト 720h = 30日)
+// JWT_KEY_CHECK_INTERVAL: 鍵の交換が必要か確認し、ほかのプロセスが作った鍵を読み込む間隔 (デフォルト 1m)
+func loadSigningKeyConfig(accessTTL time.Duration) models.SigningKeyConfig {
+	config := models.SigningKeyConfig{
+		Algorithm:        models.SigningAlgorithmHS256,
+		Secret:           os.Getenv("JWT_SECRET_KEY"),
+		EncryptionKey:    os.Getenv("JWT_KEY_ENCRYPTION_KEY"),
+		RotationInterval: 30 * 24 * time.Hour,
+		CheckInterval:    time.Minute,
+	}
+
+	if v := os.Getenv("JWT_ALGORITHM"); v != "" {
+		config.Algorithm = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION_INTERVAL")); err == nil && v >= 0 {
+		config.RotationInterval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("JWT_KEY_CHECK_INTERVAL")); err == nil && v > 0 {
+		config.CheckInterval = v
+	}
+	// ほかのプロセスは鍵を読み込み直すまで（最大 CheckInterval）古い鍵で署名し続けるので、その分だけ長く残す
+	config.VerifyGrace = accessTTL + config.CheckInterval
+
+	return config
+}
+
+// loadLoginThrottleConfig はログインの総当たり攻撃への対策の設定を環境変数から読み込む
+// LOGIN_THROTTLE_WINDOW: 失敗回数を数える期間 (デフォルト 15m, LOGIN_LOCKOUT_DURATION より短くはしない)
+// LOGIN_THROTTLE_BASE_DELAY / LOGIN_THROTTLE_MAX_DELAY: 失敗が続いたときの最初の待ち時間と上限 (デフォルト 1s / 1m, 失敗するたびに2倍)
+// LOGIN_LOCKOUT_DURATION: ロックする時間 (デフォルト 15m)
+// LOGIN_ACCOUNT_FREE_ATTEMPTS / LOGIN_ACCOUNT_LOCKOUT_THRESHOLD: メールアドレスごとの、待たずに再試行できる失敗回数とロックする失敗回数 (デフォルト 3 / 10)
+// LOGIN_IP_FREE_ATTEMPTS / LOGIN_IP_LOCKOUT_THRESHOLD: IPアドレスごとの、待たずに再試行できる失敗回数とロックする失敗回数 (デフォルト 10 / 50)
+// LOGIN_ATTEMPT_RETENTION: ログインの試行履歴を残す期間 (0で削除しない, デフォルト 2160h = 90日)
+// LOGIN_THROTTLED_RECORD_INTERVAL: 拒否した試行を、同じメールアドレス・IPアドレスにつき記録する最短の間隔 (0ならすべて記録, デフォルト 1m)
+func loadLoginThrottleConfig() models.LoginThrottleConfig {
+	config := models.LoginThrottleConfig{
+		Window:                  15 * time.Minute,
+		BaseDelay:               time.Second,
+		MaxDelay:                time.Minute,
+		LockoutDuration:         15 * time.Minute,
+		AccountFreeAttempts:     3,
+		AccountLockoutThreshold: 10,
+		IPFreeAttempts:          10,
+		IPLockoutThreshold:      50,
+		AuditRetention:          90 * 24 * time.Hour,
+		ThrottledRecordInterval: time.Minute,
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_THROTTLE_WINDOW")); err == nil && v > 0 {
+		config.Window = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_THROTTLE_BASE_DELAY")); err == nil && v > 0 {
+		config.BaseDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_THROTTLE_MAX_DELAY")); err == nil && v > 0 {
+		config.MaxDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION")); err == nil && v > 0 {
+		config.LockoutDuration = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_ACCOUNT_FREE_ATTEMPTS")); err == nil && v >= 0 {
+		config.AccountFreeAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_ACCOUNT_LOCKOUT_THRESHOLD")); err == nil && v >= 0 {
+		config.AccountLockoutThreshold = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_IP_FREE_ATTEMPTS")); err == nil && v >= 0 {
+		config.IPFreeAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_IP_LOCKOUT_THRESHOLD")); err == nil && v >= 0 {
+		config.IPLockoutThreshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPT_RETENTION")); err == nil && v >= 0 {
+		config.AuditRetention = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_THROTTLED_RECORD_INTERVAL")); err == nil && v >= 0 {
+		config.ThrottledRecordInterval = v
+	}
+	// 数える期間がロックする時間より短いと、古い失敗が数えられなくなった時点でロックが解けてしまう
+	config.Window = max(config.Window, config.LockoutDuration)
+
+	return config
+}
+
+// loadTokenCleanupInterval は期限切れのトークン失効情報・リフレッシュトークン・パスワード再設定用のトークンと、
+// 古いログインの試行履歴を削除する間隔を環境変数から読み込む
+// TOKEN_CLEANUP_INTERVAL: 削除処理の実行間隔 (例: 1h, 0で無効, デフォルト 1h)
+func loadTokenCleanupInterval() time.Duration {
+	interval := time.Hour
+	if v, err := time.ParseDuration(os.Getenv("TOKEN_CLEANUP_INTERVAL")); err == nil && v >= 0 {
+		interval = v
+	}
+	return interval
+}
+
+// loadPasswordResetConfig はパスワード再設定の設定を環境変数から読み込む
+// PASSWORD_RESET_TTL: メールで送る再設定用のリンクの有効期限 (デフォルト 1h)
+// PASSWORD_RESET_RESEND_INTERVAL: 同じユーザーに再設定メールを送る最短の間隔 (デフォルト 1m)
+// PASSWORD_RESET_QUEUE_SIZE: 送信待ちにできる再設定メールの数 (超えた依頼は捨てる, デフォルト 100)
+// PASSWORD_RESET_WORKERS: 再設定メールを送る goroutine の数 (デフォルト 2)
+// PASSWORD_RESET_RATE_LIMIT: 同じIPアドレスから受け付ける送信依頼の数 (0で無制限, デフォルト 5)
+// PASSWORD_RESET_RATE_WINDOW: PASSWORD_RESET_RATE_LIMIT を数える期間 (デフォルト 15m)
+// FRONTEND_URL: メールのリンク先のフロントエンドのURL (デフォルト http://localhost:3000, /password/reset を付けて使う)
+func loadPasswordResetConfig() models.PasswordResetConfig {
+	config := models.PasswordResetConfig{
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Minute,
+		QueueSize:      100,
+		Workers:        2,
+		RateLimit:      5,
+		RateWindow:     15 * time.Minute,
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && v > 0 {
+		config.TokenTTL = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_RESEND_INTERVAL")); err == nil && v >= 0 {
+		config.ResendInterval = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_QUEUE_SIZE")); err == nil && v > 0 {
+		config.QueueSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_WORKERS")); err == nil && v > 0 {
+		config.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_RATE_LIMIT")); err == nil && v >= 0 {
+		config.RateLimit = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_RATE_WINDOW")); err == nil && v > 0 {
+		config.RateWindow = v
+	}
+	config.ResetURL = frontendURL() + "/password/reset"
+
+	return config
+}
+
+// loadEmailVerificationConfig はメールアドレスの確認の設定を環境変数から読み込む
+// EMAIL_VERIFICATION_SECRET: 確認用のリンクの署名に使う鍵 (必須。ENV=localdevelopment のときだけ、未設定なら起動ごとにランダムに作る)
+// EMAIL_VERIFICATION_TTL: 確認用のリンクの有効期限 (デフォルト 24h)
+// EMAIL_VERIFICATION_RESEND_INTERVAL: 確認メールを再送できる最短の間隔 (デフォルト 1m)
+// EMAIL_VERIFICATION_REQUIRED_FOR_REVIEWS: true ならメールアドレスを確認していないユーザーはレビューを投稿できない (デフォルト false)
+// FRONTEND_URL: メールのリンク先のフロントエンドのURL (/email/verify を付けて使う)
+func loadEmailVerificationConfig() (models.EmailVerificationConfig, error) {
+	config := models.EmailVerificationConfig{
+		Secret:             []byte(os.Getenv("EMAIL_VERIFICATION_SECRET")),
+		TokenTTL:           24 * time.Hour,
+		ResendInterval:     time.Minute,
+		VerifyURL:          frontendURL() + "/email/verify",
+		RequiredForReviews: os.Getenv("EMAIL_VERIFICATION_REQUIRED_FOR_REVIEWS") == "true",
+	}
+
+	if len(config.Secret) == 0 {
+		// 本番で設定し忘れると、再起動やプロセスごとに鍵が変わって送ったリンクが使えなくなるので、起動させない
+		if os.Getenv("ENV") != "localdevelopment" {
+			return config, errors.New("EMAIL_VERIFICATION_SECRET is required (a random key is only used with ENV=localdevelopment)")
+		}
+		log.Println("EMAIL_VERIFICATION_SECRET is not set, using a random key (verification links will not survive a restart)")
+		config.Secret = make([]byte, 32)
+		if _, err := rand.Read(config.Secret); err != nil {
+			return config, fmt.Errorf("failed to generate email verification key: %w", err)
+		}
+	}
+	if v, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL")); err == nil && v > 0 {
+		config.TokenTTL = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_RESEND_INTERVAL")); err == nil && v >= 0 {
+		config.ResendInterval = v
+	}
+
+	return config, nil
+}
+
+// frontendURL はメールのリンク先にするフロントエンドのURLを返す (FRONTEND_URL, デフォルト http://localhost:3000)
+func frontendURL() string {
+	if v := os.Getenv("FRONTEND_URL"); v != "" {
+		return strings.TrimSuffix(v, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// loadMailSender はメールの送信方法を環境変数から読み込む
+// MAIL_DRIVER: smtp ならSMTPサーバーから送信、log なら送信せずに内容を出力 (デフォルト log)
+// MAIL_FROM: 送信元のアドレス (デフォルト AnimeScore <no-reply@localhost>)
+// MAIL_LOG_FILE: log のときの出力先のファイル (空なら標準出力)
+// SMTP_HOST / SMTP_PORT: SMTPサーバー (smtp のときは SMTP_HOST が必須, ポートのデフォルト 587)
+// SMTP_USERNAME / SMTP_PASSWORD: SMTPの認証情報 (空なら認証しない)
+// SMTP_TIMEOUT: 接続から送信完了までのタイムアウト (デフォルト 10s)
+// SMTP_ALLOW_PLAINTEXT: true なら STARTTLS に対応していないサーバーにも暗号化せずに送る (開発用, デフォルト false)
+func loadMailSender() (mail.Sender, error) {
+	from := "AnimeScore <no-reply@localhost>"
+	if v := os.Getenv("MAIL_FROM"); v != "" {
+		from = v
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "", "log":
+		return mail.NewLogSender(os.Getenv("MAIL_LOG_FILE")), nil
+	case "smtp":
+		config := mail.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     587,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+			Timeout:  10 * time.Second,
+			// 暗号化せずに送るのは、明示的に許可したときだけ
+			AllowPlaintext: os.Getenv("SMTP_ALLOW_PLAINTEXT") == "true",
+		}
+		if config.Host == "" {
+			return nil, errors.New("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+		if v, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil && v > 0 {
+			config.Port = v
+		}
+		if v, err := time.ParseDuration(os.Getenv("SMTP_TIMEOUT")); err == nil && v > 0 {
+			config.Timeout = v
+		}
+		return mail.NewSMTPSender(config), nil
+	default:
+		return nil, fmt.Errorf("unsupported MAIL_DRIVER: %q", driver)
+	}
+}
+
+// loadRetryConfig は取得元のAPIクライアントの再試行・サーキットブレーカーの設定を、prefix の付いた環境変数から読み込む
+// <prefix>_TIMEOUT: 1回のリクエストのタイムアウト (デフォルト 10s)
+// <prefix>_MAX_RETRIES: 5xx・タイムアウト時の再試行回数 (デフォルト 2)
+// <prefix>_RETRY_BASE_BACKOFF / <prefix>_RETRY_MAX_BACKOFF: 再試行までの待ち時間の基準と上限 (デフォルト 200ms / 5s)
+// <prefix>_BREAKER_THRESHOLD: 何回連続で失敗したら問い合わせを止めるか (0で無効, デフォルト 5)
+// <prefix>_BREAKER_OPEN_TIMEOUT: 問い合わせを止めてから再開を試すまでの時間 (デフォルト 30s)
+func loadRetryConfig(prefix string) models.RetryConfig {
+	config := models.RetryConfig{
+		Timeout:          10 * time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      200 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_TIMEOUT")); err == nil && v > 0 {
+		config.Timeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_MAX_RETRIES")); err == nil && v >= 0 {
+		config.MaxRetries = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_RETRY_BASE_BACKOFF")); err == nil && v > 0 {
+		config.BaseBackoff = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_RETRY_MAX_BACKOFF")); err == nil && v > 0 {
+		config.MaxBackoff = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_BREAKER_THRESHOLD")); err == nil && v >= 0 {
+		config.FailureThreshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_BREAKER_OPEN_TIMEOUT")); err == nil && v > 0 {
+		config.OpenTimeout = v
+	}
+
+	return config
+}
+
+// loadAnnictClientConfig は Annict API クライアントの設定を環境変数から読み込む
+// 再試行・サーキットブレーカーの設定は ANNICT_TIMEOUT などで指定する（loadRetryConfig を参照）
+// ANNICT_ENDPOINT: GraphQL API のURL (デフォルト https://api.annict.com/graphql, ローカルの偽サーバーを使う場合に変更)
+// ANNICT_SEARCH_CACHE_TTL: 検索結果をキャッシュする時間 (0で無効, デフォルト 5m)
+// ANNICT_SEARCH_CACHE_SIZE: キャッシュする検索結果の最大件数 (デフォルト 1000)
+// ANNICT_BATCH_SIZE: 作品をまとめて取得するときに1回のリクエストで指定するIDの数 (1〜50, デフォルト 50)
+func loadAnnictClientConfig() models.AnnictClientConfig {
+	config := models.AnnictClientConfig{
+		RetryConfig:     loadRetryConfig("ANNICT"),
+		SearchCacheTTL:  5 * time.Minute,
+		SearchCacheSize: 1000,
+		BatchSize:       50,
+	}
+
+	config.Endpoint = os.Getenv("ANNICT_ENDPOINT")
+	if v, err := time.ParseDuration(os.Getenv("ANNICT_SEARCH_CACHE_TTL")); err == nil && v >= 0 {
+		config.SearchCacheTTL = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANNICT_SEARCH_CACHE_SIZE")); err == nil && v > 0 {
+		config.SearchCacheSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANNICT_BATCH_SIZE")); err == nil && v > 0 {
+		config.BatchSize = v
+	}
+
+	return config
+}
+
+// loadAniListClientConfig は AniList API クライアントの設定を環境変数から読み込む
+// 再試行・サーキットブレーカーの設定は ANILIST_TIMEOUT などで指定する（loadRetryConfig を参照。Annict とは別に数える）
+// ANILIST_ENDPOINT: GraphQL API のURL (デフォルト https://graphql.anilist.co)
+func loadAniListClientConfig() models.AniListClientConfig {
+	return models.AniListClientConfig{
+		RetryConfig: loadRetryConfig("ANILIST"),
+		Endpoint:    os.Getenv("ANILIST_ENDPOINT"),
+	}
+}
@@ -0,0 +1,259 @@
+// fakeannict は Annict GraphQL API の代わりにローカルで動く偽サーバー
+// JSONのフィクスチャファイルから作品・エピソードを返すので、ネットワークやAnnictのトークンなしで
+// バックエンドを動かしたり、本物の AnnictRepository を通した結合テストをしたりできる
+//
+// 使い方:
+//
+//	go run ./cmd/fakeannict -addr :8081 -fixture ./cmd/fakeannict/testdata/works.json
+//	ANNICT_ENDPOINT=http://localhost:8081/graphql go run ./cmd/api
+//
+// GraphQLのクエリは解析せず、AnnictRepository が送るクエリ（searchWorks）だけに対応している
+//   - 変数 title があればタイトル（読み仮名・英語タイトルを含む）の部分一致で検索し、放送時期の新しい順に返す
+//   - 変数 annictId / annictIds があればIDで作品を返す
+//   - クエリに episodes(...) が含まれていれば、1件目の作品のエピソード一覧を返す
+//   - first / after（または limit / after）でカーソルによるページングができる
+package main
+
+import (
+	"anime-score-backend/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fixtureWork はフィクスチャファイルの作品（Annictの作品情報＋エピソード一覧）
+type fixtureWork struct {
+	models.AnnictWork
+	Episodes []models.AnnictEpisode `json:"episodes"`
+}
+
+// fixture はフィクスチャファイル全体
+type fixture struct {
+	Works []fixtureWork `json:"works"`
+}
+
+// graphQLRequest は AnnictRepository から送られてくるリクエスト
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// seasonOrder は放送時期の並び替え用（同じ年なら秋→夏→春→冬の順に新しい）
+var seasonOrder = map[string]int{"WINTER": 1, "SPRING": 2, "SUMMER": 3, "AUTUMN": 4}
+
+func main() {
+	addr := flag.String("addr", ":8081", "待ち受けるアドレス")
+	fixturePath := flag.String("fixture", "cmd/fakeannict/testdata/works.json", "作品データのJSONファイル")
+	failRate := flag.Float64("fail-rate", 0, "503を返す割合 (0〜1, 再試行・サーキットブレーカーの動作確認用)")
+	flag.Parse()
+
+	data, err := loadFixture(*fixturePath)
+	if err != nil {
+		log.Fatalln("Failed to load fixture:", err)
+	}
+
+	log.Printf("Fake Annict server listening on %s (%d works)", *addr, len(data.Works))
+	if err := http.ListenAndServe(*addr, newHandler(data, *failRate)); err != nil {
+		log.Fatalln("Failed to start server:", err)
+	}
+}
+
+// newHandler は /graphql で data の作品を返すハンドラーを作る
+// failRate の割合で503を返す（0なら常に正常に応答する）
+// テストでは httptest.NewServer に渡して使う
+func newHandler(data *fixture, failRate float64) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if failRate > 0 && rand.Float64() < failRate {
+			http.Error(w, "fake annict: simulated failure", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := handleQuery(data, req)
+		if err != nil {
+			// GraphQLのエラーは200で errors に入れて返す
+			resp = map[string]any{"errors": []map[string]string{{"message": err.Error()}}}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+// loadFixture はフィクスチャファイルを読み込む
+func loadFixture(path string) (*fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data fixture
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid fixture: %w", err)
+	}
+	return &data, nil
+}
+
+// handleQuery はリクエストの変数に応じて searchWorks のレスポンスを作る
+func handleQuery(data *fixture, req graphQLRequest) (map[string]any, error) {
+	if !strings.Contains(req.Query, "searchWorks") {
+		return nil, fmt.Errorf("fake annict supports only searchWorks")
+	}
+
+	works, err := filterWorks(data.Works, req.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	// エピソード一覧の取得（作品は1件目だけを使う）
+	// 作品の項目 episodesCount と区別するため、引数付きの episodes( で判定する
+	if strings.Contains(req.Query, "episodes(") {
+		nodes := []any{}
+		if len(works) > 0 {
+			episodes, pageInfo, err := paginate(works[0].Episodes, req.Variables["limit"], req.Variables["after"])
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, map[string]any{
+				"episodes": map[string]any{"nodes": episodes, "pageInfo": pageInfo},
+			})
+		}
+		return searchWorksResponse(nodes, models.PageInfo{}), nil
+	}
+
+	// ID指定なら first、検索なら limit で件数を受け取る
+	first := req.Variables["limit"]
+	if first == nil {
+		first = req.Variables["first"]
+	}
+	page, pageInfo, err := paginate(works, first, req.Variables["after"])
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]any, len(page))
+	for i, work := range page {
+		nodes[i] = work.AnnictWork
+	}
+	return searchWorksResponse(nodes, pageInfo), nil
+}
+
+// filterWorks は変数 title / annictId / annictIds で作品を絞り込む
+func filterWorks(works []fixtureWork, variables map[string]any) ([]fixtureWork, error) {
+	var ids []int
+	if id, ok := variables["annictId"].(float64); ok {
+		ids = append(ids, int(id))
+	}
+	if list, ok := variables["annictIds"].([]any); ok {
+		for _, v := range list {
+			id, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("annictIds must be a list of Int")
+			}
+			ids = append(ids, int(id))
+		}
+	}
+	if ids != nil {
+		var result []fixtureWork
+		for _, work := range works {
+			if slices.Contains(ids, work.AnnictID) {
+				result = append(result, work)
+			}
+		}
+		return result, nil
+	}
+
+	title, _ := variables["title"].(string)
+	keyword := strings.ToLower(title)
+	var result []fixtureWork
+	for _, work := range works {
+		for _, t := range []string{work.Title, work.TitleKana, work.TitleEn} {
+			if t != "" && strings.Contains(strings.ToLower(t), keyword) {
+				result = append(result, work)
+				break
+			}
+		}
+	}
+
+	// Annictの orderBy: { field: SEASON, direction: DESC } と同じく、放送時期の新しい順に並べる
+	slices.SortStableFunc(result, func(a, b fixtureWork) int {
+		return seasonKey(b.AnnictWork) - seasonKey(a.AnnictWork)
+	})
+	return result, nil
+}
+
+// seasonKey は放送時期を比較用の数値にする（不明なものは最も古い扱い）
+func seasonKey(work models.AnnictWork) int {
+	key := 0
+	if work.SeasonYear != nil {
+		key = *work.SeasonYear * 10
+	}
+	if work.SeasonName != nil {
+		key += seasonOrder[*work.SeasonName]
+	}
+	return key
+}
+
+// paginate は first 件ずつのページに分け、after（前ページの endCursor）の続きを返す
+// カーソルは「何件目まで返したか」をbase64にしたもの
+func paginate[T any](items []T, first, after any) ([]T, models.PageInfo, error) {
+	start := 0
+	if cursor, ok := after.(string); ok && cursor != "" {
+		b, err := base64.StdEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, models.PageInfo{}, fmt.Errorf("invalid cursor")
+		}
+		start, err = strconv.Atoi(string(b))
+		if err != nil || start < 0 {
+			return nil, models.PageInfo{}, fmt.Errorf("invalid cursor")
+		}
+	}
+	start = min(start, len(items))
+
+	end := len(items)
+	if n, ok := first.(float64); ok && n >= 0 {
+		end = min(start+int(n), len(items))
+	}
+
+	pageInfo := models.PageInfo{HasNextPage: end < len(items)}
+	if end > start {
+		pageInfo.EndCursor = base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+
+	page := items[start:end]
+	if page == nil {
+		page = []T{}
+	}
+	return page, pageInfo, nil
+}
+
+// searchWorksResponse は searchWorks のレスポンスの形に包む
+func searchWorksResponse(nodes []any, pageInfo models.PageInfo) map[string]any {
+	return map[string]any{
+		"data": map[string]any{
+			"searchWorks": map[string]any{
+				"nodes":    nodes,
+				"pageInfo": pageInfo,
+			},
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestRepository はフィクスチャを返す偽サーバーを立て、そこを向いた本物の AnnictRepository を返す
+func newTestRepository(t *testing.T) *repositories.AnnictRepository {
+	t.Helper()
+	data, err := loadFixture("testdata/works.json")
+	if err != nil {
+		t.Fatalf("loadFixture() error = %v", err)
+	}
+
+	server := httptest.NewServer(newHandler(data, 0))
+	t.Cleanup(server.Close)
+
+	return repositories.NewAnnictRepository("token", models.AnnictClientConfig{
+		RetryConfig: models.RetryConfig{Timeout: time.Second, MaxBackoff: time.Millisecond},
+		Endpoint:    server.URL + "/graphql",
+		BatchSize:   2,
+	}, nil)
+}
+
+func workIDs(works []models.MetadataWork) []int {
+	ids := make([]int, len(works))
+	for i, work := range works {
+		ids[i] = work.ExternalID
+	}
+	return ids
+}
+
+func TestSearchWorksPaginates(t *testing.T) {
+	repo := newTestRepository(t)
+
+	// 英語タイトルでも検索でき、放送時期の新しい順に返る
+	works, cursor, err := repo.SearchWorks("gundam", 2, "")
+	if err != nil {
+		t.Fatalf("SearchWorks() error = %v", err)
+	}
+	if got, want := workIDs(works), []int{90003, 90002}; !slices.Equal(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	if cursor == "" {
+		t.Fatal("first page should return a cursor")
+	}
+
+	works, cursor, err = repo.SearchWorks("gundam", 2, cursor)
+	if err != nil {
+		t.Fatalf("SearchWorks() error = %v", err)
+	}
+	if got, want := workIDs(works), []int{90001}; !slices.Equal(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+	if cursor != "" {
+		t.Errorf("last page cursor = %q, want empty", cursor)
+	}
+}
+
+func TestGetWorkByID(t *testing.T) {
+	repo := newTestRepository(t)
+
+	work, err := repo.GetWorkByID(90004)
+	if err != nil {
+		t.Fatalf("GetWorkByID() error = %v", err)
+	}
+	if work.Provider != models.MetadataProviderAnnict || work.Title != "ぼくらのフィクスチャ日和" {
+		t.Errorf("work = %+v, want ぼくらのフィクスチャ日和 from annict", work)
+	}
+	if work.SeasonYear == nil || *work.SeasonYear != 2023 {
+		t.Errorf("SeasonYear = %v, want 2023", work.SeasonYear)
+	}
+
+	if _, err := repo.GetWorkByID(1); !errors.Is(err, repositories.ErrAnnictNotFound) {
+		t.Errorf("GetWorkByID(1) error = %v, want ErrAnnictNotFound", err)
+	}
+}
+
+func TestGetWorksByIDsInBatches(t *testing.T) {
+	repo := newTestRepository(t)
+
+	// BatchSize が2なので3回に分けて問い合わせる。存在しないIDは結果に含まれない
+	works, err := repo.GetWorksByIDs([]int{90001, 90004, 1, 90005, 90006})
+	if err != nil {
+		t.Fatalf("GetWorksByIDs() error = %v", err)
+	}
+	got := workIDs(works)
+	slices.Sort(got)
+	if want := []int{90001, 90004, 90005, 90006}; !slices.Equal(got, want) {
+		t.Errorf("GetWorksByIDs() = %v, want %v", got, want)
+	}
+}
+
+func TestGetEpisodesByWorkID(t *testing.T) {
+	repo := newTestRepository(t)
+
+	episodes, err := repo.GetEpisodesByWorkID(90004)
+	if err != nil {
+		t.Fatalf("GetEpisodesByWorkID() error = %v", err)
+	}
+	if len(episodes) != 4 {
+		t.Errorf("len(episodes) = %d, want 4", len(episodes))
+	}
+
+	episodes, err = repo.GetEpisodesByWorkID(90003)
+	if err != nil {
+		t.Fatalf("GetEpisodesByWorkID() error = %v", err)
+	}
+	if len(episodes) != 0 {
+		t.Errorf("len(episodes) = %d, want 0", len(episodes))
+	}
+}
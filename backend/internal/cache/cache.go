@@ -0,0 +1,39 @@
+package cache
+
+import "time"
+
+// Cache はキーと値（バイト列）を一定時間保存するキャッシュのインターフェース
+// 値をバイト列にしているので、プロセス内のLRUキャッシュだけでなく、
+// Redisのような外部のキャッシュでも同じインターフェースで実装できる
+type Cache interface {
+	// Get はキーに対応する値を返す。ない場合や期限切れの場合は false を返す
+	Get(key string) ([]byte, bool)
+	// GetStale は期限切れでも残っている値を返す。取得元の障害時に古い値で代用するために使う
+	GetStale(key string) ([]byte, bool)
+	// Set は値を ttl の間だけ保存する
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete はキーに対応する値を削除する
+	Delete(key string)
+	// DeletePrefix は prefix で始まるキーの値をすべて削除し、削除した件数を返す
+	DeletePrefix(prefix string) int
+	// Stats はヒット率などの統計情報を返す
+	Stats() Stats
+}
+
+// Stats はキャッシュの統計情報
+type Stats struct {
+	Hits      uint64  `json:"hits"`      // キャッシュから値を返せた回数
+	Misses    uint64  `json:"misses"`    // 値がなかった（期限切れを含む）回数
+	StaleHits uint64  `json:"staleHits"` // GetStale で期限切れの値を返した回数
+	HitRate   float64 `json:"hitRate"`   // Hits / (Hits + Misses)
+	Entries   int     `json:"entries"`   // 現在保存している件数
+	Evictions uint64  `json:"evictions"` // 容量を超えたために追い出した件数
+}
+
+// hitRate はヒット数とミス数からヒット率を計算する
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LRU はプロセス内で動く、件数上限付きのキャッシュ
+// 上限を超えたら最も長く使われていない（Least Recently Used）値から追い出す
+// 各値には有効期限があるが、期限切れの値もすぐには削除せず、GetStale で取り出せるように残しておく
+// （上限を超えたときに、使われていない順に追い出される）
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element // キー → リストの要素
+	order    *list.List               // 先頭ほど最近使われた値
+
+	hits      uint64
+	misses    uint64
+	staleHits uint64
+	evictions uint64
+}
+
+// lruEntry はリストの要素に入れる値
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRU は最大 capacity 件を保存するLRUキャッシュを生成する
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get はキーに対応する値を返す
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.misses++
+		return nil, false
+	}
+
+	// 使われたので先頭に移動する
+	c.order.MoveToFront(elem)
+	c.hits++
+	return entry.value, true
+}
+
+// GetStale は期限切れかどうかにかかわらず、キーに対応する値を返す
+func (c *LRU) GetStale(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.staleHits++
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set は値を ttl の間だけ保存する
+func (c *LRU) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+
+	// 既にあるキーは値を上書きして先頭に移動する
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+
+	// 上限を超えたら末尾（最も長く使われていない値）から追い出す
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Delete はキーに対応する値を削除する
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// DeletePrefix は prefix で始まるキーの値をすべて削除する
+func (c *LRU) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Stats はヒット率などの統計情報を返す
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		StaleHits: c.staleHits,
+		HitRate:   hitRate(c.hits, c.misses),
+		Entries:   c.order.Len(),
+		Evictions: c.evictions,
+	}
+}
+
+// removeElement はリストとマップの両方から値を削除する（ロックを取ってから呼ぶこと）
+func (c *LRU) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
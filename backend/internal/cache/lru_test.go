@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUGetAndExpire(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("a", []byte("1"), time.Hour)
+	c.Set("b", []byte("2"), -time.Second) // 既に期限切れ
+
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf(`Get("a") = %q, %v, want "1", true`, v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error(`Get("b") should miss an expired value`)
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Error(`Get("c") should miss a missing key`)
+	}
+
+	// 期限切れの値も GetStale では取り出せる
+	if v, ok := c.GetStale("b"); !ok || string(v) != "2" {
+		t.Errorf(`GetStale("b") = %q, %v, want "2", true`, v, ok)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.StaleHits != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, want 1 hit, 2 misses, 1 stale hit, 2 entries", stats)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"), time.Hour)
+	c.Set("b", []byte("2"), time.Hour)
+	c.Get("a") // a を最近使ったことにする
+	c.Set("c", []byte("3"), time.Hour)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should still be cached")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("c should still be cached")
+	}
+	if got := c.Stats().Evictions; got != 1 {
+		t.Errorf("Evictions = %d, want 1", got)
+	}
+}
+
+func TestLRUDeletePrefix(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("search:foo|10|", []byte("1"), time.Hour)
+	c.Set("search:foo|20|", []byte("2"), time.Hour)
+	c.Set("search:foobar|10|", []byte("3"), time.Hour)
+	c.Set("other", []byte("4"), time.Hour)
+
+	if got := c.DeletePrefix("search:foo|"); got != 2 {
+		t.Errorf(`DeletePrefix("search:foo|") = %d, want 2`, got)
+	}
+	if _, ok := c.GetStale("search:foobar|10|"); !ok {
+		t.Error("keys of other keywords should be kept")
+	}
+	if got := c.DeletePrefix("search:"); got != 1 {
+		t.Errorf(`DeletePrefix("search:") = %d, want 1`, got)
+	}
+	if _, ok := c.Get("other"); !ok {
+		t.Error("keys without the prefix should be kept")
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	loginAttempts *services.LoginAttemptService
+}
+
+func NewAdminHandler(loginAttempts *services.LoginAttemptService) *AdminHandler {
+	return &AdminHandler{loginAttempts: loginAttempts}
+}
+
+// ListLoginAttempts はログインの試行履歴を新しい順に返すハンドラー（管理者のみ）
+// メールアドレス・IPアドレス・ユーザーID・結果での絞り込みと、カーソルによるページネーションに対応
+func (h *AdminHandler) ListLoginAttempts(c *gin.Context) {
+	// 1. クエリパラメータを構造体にバインド
+	var query models.LoginAttemptListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+
+	// 2. サービス層で試行履歴を取得
+	attempts, nextCursor, err := h.loginAttempts.List(query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) || errors.Is(err, services.ErrInvalidLoginResult) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get login attempts"})
+		return
+	}
+
+	// 3. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"data":       attempts,
+		"nextCursor": nextCursor,
+	})
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/services"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -41,9 +44,22 @@ func (h *AnimeHandler) Search(c *gin.Context) {
 		limit = 15
 	}
 
+	// ログインしていれば自分のスコアも返す（OptionalAuthMiddlewareがセットする）
+	var userID int64
+	if userIDValue, exists := c.Get("userID"); exists {
+		userID = int64(userIDValue.(int))
+	}
+
 	// 3. Service層の呼び出し
-	works, nextCursor, err := h.service.SearchAnimes(keyword, limit, cursor)
+	works, nextCursor, err := h.service.SearchAnimes(keyword, limit, cursor, userID)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondAnnictError(c, err) {
+			return
+		}
 		// 外部APIのエラーなどはここでログに出し、ユーザーには500を返す
 		// 本番では詳細なエラーメッセージを隠蔽するのが一般的
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search animes"})
@@ -70,6 +86,9 @@ func (h *AnimeHandler) GetDetail(c *gin.Context) {
 	// Service を呼び出してレスポンスを返す
 	anime, stats, err := h.service.GetAnimeDetail(int64(annictID))
 	if err != nil {
+		if respondAnnictError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get anime details"})
 		return
 	}
@@ -80,11 +99,53 @@ func (h *AnimeHandler) GetDetail(c *gin.Context) {
 	})
 }
 
+// GetByExternalID は /api/animes/external/:provider/:externalId へのリクエストを処理
+// Annict / AniList などの取得元での作品IDからアニメを探す
+func (h *AnimeHandler) GetByExternalID(c *gin.Context) {
+	provider := c.Param("provider")
+	externalID, err := strconv.Atoi(c.Param("externalId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid external ID"})
+		return
+	}
+
+	anime, err := h.service.FindAnimeByExternalID(provider, externalID)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvider) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondAnnictError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrAniListNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "anime not found"})
+		case errors.Is(err, services.ErrAniListUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "anime data source is temporarily unavailable"})
+		case errors.Is(err, services.ErrAniListRequestFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch anime data from anilist"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find anime"})
+		}
+		return
+	}
+	if anime == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "anime not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anime": anime})
+}
+
 // GetList は /api/animes へのリクエストを処理（アニメ一覧取得）
+// URL: /api/animes?page=1&pageSize=10&sort=bayesian&year=2024&season=spring
+// 年の範囲で絞り込む場合: /api/animes?yearFrom=2020&yearTo=2024
 func (h *AnimeHandler) GetList(c *gin.Context) {
 	// クエリパラメータの取得
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	sort := c.Query("sort") // 指定がなければサーバーのデフォルト設定
 
 	// 数値に変換
 	page, err := strconv.Atoi(pageStr)
@@ -97,12 +158,72 @@ func (h *AnimeHandler) GetList(c *gin.Context) {
 		pageSize = 10
 	}
 
+	// 絞り込み条件（指定がない項目はnilのまま）
+	var filter models.AnimeListFilter
+	if filter.Year, err = queryInt(c, "year"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+	if filter.YearFrom, err = queryInt(c, "yearFrom"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid yearFrom"})
+		return
+	}
+	if filter.YearTo, err = queryInt(c, "yearTo"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid yearTo"})
+		return
+	}
+	if season := c.Query("season"); season != "" {
+		filter.Season = &season
+	}
+
 	// Service呼び出し
-	result, err := h.service.GetAnimeList(page, pageSize)
+	result, err := h.service.GetAnimeList(page, pageSize, sort, filter)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidSort) || errors.Is(err, services.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get anime list"})
 		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
+
+// queryInt は数値のクエリパラメータを取得する
+// 指定がなければnil、数値でなければエラーを返す
+func queryInt(c *gin.Context, key string) (*int, error) {
+	str := c.Query(key)
+	if str == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// respondAnnictError は Annict API の呼び出しに失敗したエラーなら、種類に応じたステータスコードでレスポンスを返してtrueを返す
+// 障害・レート制限で一時的に使えない → 503（分かれば Retry-After ヘッダーを付ける）
+// Annictからまともな応答が得られなかった・リクエストが拒否された → 502、作品が存在しない → 404
+// Annictのエラーでなければ何もせずにfalseを返す
+func respondAnnictError(c *gin.Context, err error) bool {
+	var annictErr *services.AnnictError
+	if !errors.As(err, &annictErr) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, services.ErrAnnictNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "anime not found"})
+	case errors.Is(err, services.ErrAnnictUnavailable), errors.Is(err, services.ErrAnnictRateLimited):
+		if annictErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(annictErr.RetryAfter.Seconds()))))
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "anime data source is temporarily unavailable"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch anime data from annict"})
+	}
+	return true
+}
@@ -3,8 +3,11 @@ package handlers
 import (
 	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/services"
+	"errors"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,8 +20,15 @@ func NewAuthHandler(service *services.AuthService) *AuthHandler {
 	return &AuthHandler{service: service}
 }
 
+// トークンを保存するクッキーの名前
+const (
+	authCookieName    = "auth_token"
+	refreshCookieName = "refresh_token"
+)
+
 // クッキーセット用のヘルパー関数
-func (h *AuthHandler) setAuthCookie(c *gin.Context, token string) {
+// クッキーの有効期限はトークン自体の有効期限と揃える
+func (h *AuthHandler) setAuthCookies(c *gin.Context, tokens *models.AuthTokens) {
 	// 1. SameSite属性の設定
 	// CSRF対策のため、SameSite属性を設定
 	// c.SetCookieを呼ぶ「前」に設定する必要がある
@@ -28,19 +38,69 @@ func (h *AuthHandler) setAuthCookie(c *gin.Context, token string) {
 	// 環境変数でSecureフラグを判定（本番ではHTTPS必須）
 	isProduction := os.Getenv("ENV") == "production"
 
-	// 2. クッキーにトークンをセット
+	// 2. クッキーにアクセストークンをセット
 	// c.SetCookie(name, value, maxAge, path, domain, secure, httpOnly)
+	maxAge := secondsUntil(tokens.AccessTokenExpiresAt)
+	c.SetCookie(
+		authCookieName,     // cookie名
+		tokens.AccessToken, // 値（JWT）
+		maxAge,             // 有効期限（秒）: トークンの有効期限まで
+		"/",                // パス: サイト全体で有効
+		"",                 // ドメイン: 空文字だと現在のドメインのみ
+		isProduction,       // Secure: ENV=productionのときtrue
+		true,               // HttpOnly: JavaScriptからのアクセス禁止（必須）
+	)
+
+	// 3. クッキーにリフレッシュトークンをセット
+	// トークンの再発行・ログアウトのときだけ送ればよいので、パスを /api に絞る
+	// アクセストークンだけを発行し直した場合は、交換済みのクッキーをそのまま残す
+	if tokens.RefreshToken == "" {
+		return
+	}
 	c.SetCookie(
-		"auth_token", // cookie名
-		token,        // 値（JWTなど）
-		3600*24,      // 有効期限（秒）: ここでは24時間
-		"/",          // パス: サイト全体で有効
-		"",           // ドメイン: 空文字だと現在のドメインのみ
-		isProduction, // Secure: ENV=productionのときtrue
-		true,         // HttpOnly: JavaScriptからのアクセス禁止（必須）
+		refreshCookieName,
+		tokens.RefreshToken,
+		secondsUntil(tokens.RefreshTokenExpiresAt),
+		"/api",
+		"",
+		isProduction,
+		true,
 	)
 }
 
+// secondsUntil はクッキーの maxAge 用に、t までの秒数を返す
+func secondsUntil(t time.Time) int {
+	return max(int(time.Until(t).Seconds()), 1)
+}
+
+// tokenResponse はトークンの組をレスポンス用に変換する
+// BFF がトークンを受け取り、有効期限に合わせて Cookie に変換する
+// アクセストークンだけを発行し直した場合は、リフレッシュトークンを含めない
+func tokenResponse(tokens *models.AuthTokens) gin.H {
+	response := gin.H{
+		"token":     tokens.AccessToken,
+		"expiresAt": tokens.AccessTokenExpiresAt,
+	}
+	if tokens.RefreshToken != "" {
+		response["refreshToken"] = tokens.RefreshToken
+		response["refreshExpiresAt"] = tokens.RefreshTokenExpiresAt
+	}
+	return response
+}
+
+// refreshTokenFromRequest はリクエストからリフレッシュトークンを取り出す（ボディ → Cookie の優先順）
+// 見つからなければ空文字を返す
+func refreshTokenFromRequest(c *gin.Context) string {
+	var input models.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err == nil && input.RefreshToken != "" {
+		return input.RefreshToken
+	}
+	if token, err := c.Cookie(refreshCookieName); err == nil {
+		return token
+	}
+	return ""
+}
+
 // Signup ハンドラー
 // c.ShouldBindJSON(&input) はリクエストボディのJSONを構造体にマッピングしている
 func (h *AuthHandler) Signup(c *gin.Context) {
@@ -50,7 +110,7 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.service.Signup(input)
+	user, tokens, err := h.service.Signup(input)
 	if err != nil {
 		// ユーザー名重複・バリデーションエラーはクライアントに内容を返す
 		errMsg := err.Error()
@@ -63,10 +123,13 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	}
 
 	// クッキーにトークンをセット
-	h.setAuthCookie(c, token)
+	h.setAuthCookies(c, tokens)
 
 	// レスポンスボディにトークンを含める（BFF がトークンを受け取り Cookie に変換する）
-	c.JSON(http.StatusCreated, gin.H{"message": "User created", "user": user, "token": token})
+	response := tokenResponse(tokens)
+	response["message"] = "User created"
+	response["user"] = user
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login ハンドラー
@@ -77,33 +140,143 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.service.Login(input)
+	// 1. ログイン（失敗回数の記録にクライアントのIPアドレスとUser-Agentを使う）
+	client := models.LoginClient{IPAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	user, tokens, err := h.service.Login(input, client)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		var throttled *services.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			// 失敗が続いたため、待ち時間が過ぎるまで拒否する
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		}
 		return
 	}
 
 	// 2. クッキーにトークンをセット
-	h.setAuthCookie(c, token)
+	h.setAuthCookies(c, tokens)
 
 	// 3. レスポンスボディにトークンを含める（BFF がトークンを受け取り Cookie に変換する）
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": user, "token": token})
+	response := tokenResponse(tokens)
+	response["message"] = "Login successful"
+	response["user"] = user
+	c.JSON(http.StatusOK, response)
+}
+
+// Refresh ハンドラー
+// POST /api/token/refresh: リフレッシュトークンを新しいアクセストークン・リフレッシュトークンに交換する
+// リフレッシュトークンはリクエストボディの refreshToken か、refresh_token クッキーで受け取る
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	refreshToken := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	tokens, err := h.service.Refresh(refreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			// 使えないトークンが残らないようにクッキーも削除する
+			h.clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	h.setAuthCookies(c, tokens)
+
+	response := tokenResponse(tokens)
+	response["message"] = "Token refreshed"
+	c.JSON(http.StatusOK, response)
 }
 
 // Logout ハンドラー
+// クッキーを削除するだけでなく、使っていたトークン自体をサーバー側で失効させる
+// （BFFからBearerトークンとしてコピーされたものも、有効期限を待たずに使えなくなる）
+// アクセストークンの期限が切れていても、リフレッシュトークンだけでログアウトできる
 func (h *AuthHandler) Logout(c *gin.Context) {
+	// 1. 任意認証ミドルウェアでセットされたユーザーID・トークンIDと、リフレッシュトークンを取得
+	userID := 0
+	if v, exists := c.Get("userID"); exists {
+		userID = v.(int)
+	}
+	refreshToken := refreshTokenFromRequest(c)
+	if userID == 0 && refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	// 2. トークンを失効させる（送られてきたアクセストークン・リフレッシュトークンのそれぞれ）
+	if userID != 0 {
+		if err := h.service.RevokeToken(c.GetString("tokenID"), userID, c.GetTime("tokenExpiresAt")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+	if refreshToken != "" {
+		if err := h.service.RevokeRefreshToken(refreshToken, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	// 3. クッキーを削除
+	h.clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+}
+
+// LogoutAll ハンドラー
+// すべての端末からログアウトする（これまでに発行したトークンをすべて失効させる）
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := userIDValue.(int)
+
+	// 2. ユーザーのトークンをすべて失効させる
+	if err := h.service.RevokeAllTokens(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	// 3. クッキーを削除
+	h.clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+// JWKS ハンドラー
+// GET /.well-known/jwks.json: トークンの検証に使える公開鍵の一覧を返す
+// BFF やほかのサービスは、秘密鍵を共有せずにこの公開鍵でトークンを検証できる
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	// 鍵の交換に追従できるよう、キャッシュは短めにする
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// クッキー削除用のヘルパー関数
+func (h *AuthHandler) clearAuthCookies(c *gin.Context) {
 	// 環境変数でSecureフラグを判定
 	isProduction := os.Getenv("ENV") == "production"
 
 	// クッキーの有効期限を過去に設定して削除
 	c.SetCookie(
-		"auth_token", // cookie名
-		"",           // 値を空に
-		-1,           // 有効期限を過去に設定
-		"/",          // パス
-		"",           // ドメイン
-		isProduction, // Secure: ENV=productionのときtrue
-		true,         // HttpOnly
+		authCookieName, // cookie名
+		"",             // 値を空に
+		-1,             // 有効期限を過去に設定
+		"/",            // パス
+		"",             // ドメイン
+		isProduction,   // Secure: ENV=productionのときtrue
+		true,           // HttpOnly
 	)
-	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+	c.SetCookie(refreshCookieName, "", -1, "/api", "", isProduction, true)
 }
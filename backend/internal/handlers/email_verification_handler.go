@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationHandler struct {
+	service *services.EmailVerificationService
+}
+
+func NewEmailVerificationHandler(service *services.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{service: service}
+}
+
+// Verify ハンドラー
+// POST /api/email/verify: メールのリンクのトークンを確認し、メールアドレスを確認済みにする
+func (h *EmailVerificationHandler) Verify(c *gin.Context) {
+	var input models.VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Verify(input.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "メールアドレスを確認しました"})
+}
+
+// Resend ハンドラー
+// POST /api/email/verification/resend: ログイン中のユーザーに確認メールを送り直す（認証必須）
+// 短い間隔で何度も送らないように、前回から一定時間が経っていなければ 429 を返す
+func (h *EmailVerificationHandler) Resend(c *gin.Context) {
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := userIDValue.(int)
+
+	// 2. 確認メールを送る
+	if err := h.service.Resend(userID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrVerificationMailThrottled):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification mail"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "確認メールを送信しました"})
+}
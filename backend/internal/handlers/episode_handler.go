@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type EpisodeHandler struct {
+	service *services.EpisodeService
+}
+
+// NewEpisodeHandler はハンドラのインスタンスを生成
+func NewEpisodeHandler(service *services.EpisodeService) *EpisodeHandler {
+	return &EpisodeHandler{service: service}
+}
+
+// ListByAnime は GET /api/animes/:id/episodes へのリクエストを処理する
+// アニメのエピソード一覧を視聴数・平均点付きで返す（:id はAnnict ID）
+func (h *EpisodeHandler) ListByAnime(c *gin.Context) {
+
+	// 1. パスパラメータからAnnict IDを取得
+	annictID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anime ID"})
+		return
+	}
+
+	// 2. サービス層でエピソード一覧を取得
+	anime, episodes, err := h.service.GetEpisodes(annictID)
+	if err != nil {
+		if respondAnnictError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get episodes"})
+		return
+	}
+
+	// 3. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"anime": anime,
+		"data":  episodes,
+	})
+}
+
+// RecordWatch は PUT /api/episodes/:id/watch へのリクエストを処理する
+// エピソードを視聴済みにする（認証必須、スコアは任意）
+func (h *EpisodeHandler) RecordWatch(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからエピソードIDを取得
+	episodeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid episode ID"})
+		return
+	}
+
+	// 3. リクエストボディをパース（ボディなしの場合はスコアなし）
+	// chunked で送られると ContentLength が -1 になり空かどうか分からないので、読んでみて io.EOF なら空とみなす
+	var input models.EpisodeWatchInput
+	if err := json.NewDecoder(c.Request.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入力データが不正です: " + err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入力データが不正です: " + err.Error()})
+		return
+	}
+
+	// 4. サービス層で視聴記録を保存
+	watch, err := h.service.RecordWatch(userID, episodeID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrEpisodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record episode watch"})
+		return
+	}
+
+	// 5. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"message": "視聴済みにしました",
+		"watch":   watch,
+	})
+}
+
+// RemoveWatch は DELETE /api/episodes/:id/watch へのリクエストを処理する
+// エピソードの視聴記録を削除する（認証必須）
+func (h *EpisodeHandler) RemoveWatch(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからエピソードIDを取得
+	episodeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid episode ID"})
+		return
+	}
+
+	// 3. サービス層で視聴記録を削除
+	if err := h.service.RemoveWatch(userID, episodeID); err != nil {
+		if errors.Is(err, services.ErrEpisodeWatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove episode watch"})
+		return
+	}
+
+	// 4. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{"message": "視聴記録を削除しました"})
+}
+
+// ListMyWatches は GET /api/me/animes/:annictId/episodes へのリクエストを処理する
+// 自分が視聴済みにしたエピソードを返す（認証必須）
+func (h *EpisodeHandler) ListMyWatches(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからAnnict IDを取得
+	annictID, err := strconv.Atoi(c.Param("annictId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anime ID"})
+		return
+	}
+
+	// 3. サービス層で視聴記録を取得
+	watches, err := h.service.GetMyWatches(userID, annictID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get episode watches"})
+		return
+	}
+
+	// 4. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"data": watches,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LibraryHandler struct {
+	service *services.LibraryService
+}
+
+// NewLibraryHandler はハンドラのインスタンスを生成
+func NewLibraryHandler(service *services.LibraryService) *LibraryHandler {
+	return &LibraryHandler{service: service}
+}
+
+// SetStatus は PUT /api/me/library/:annictId へのリクエストを処理する
+// アニメの視聴ステータスを設定（認証必須）
+func (h *LibraryHandler) SetStatus(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからAnnict IDを取得
+	annictID, err := strconv.Atoi(c.Param("annictId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anime ID"})
+		return
+	}
+
+	// 3. リクエストボディをパース
+	var input models.LibraryStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入力データが不正です: " + err.Error()})
+		return
+	}
+
+	// 4. サービス層で視聴ステータスを保存
+	entry, err := h.service.SetStatus(userID, annictID, input.Status)
+	if err != nil {
+		if respondAnnictError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWatchStatus) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set watch status"})
+		return
+	}
+
+	// 5. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"message": "視聴ステータスを更新しました",
+		"status":  entry,
+	})
+}
+
+// Import は POST /api/me/library/import へのリクエストを処理する
+// 視聴履歴をまとめて取り込む（認証必須、1回に500件まで）
+func (h *LibraryHandler) Import(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. リクエストボディをパース
+	var input models.LibraryImportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入力データが不正です: " + err.Error()})
+		return
+	}
+
+	// 3. サービス層でまとめて保存
+	result, err := h.service.ImportStatuses(userID, input.Entries)
+	if err != nil {
+		if respondAnnictError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWatchStatus) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import library"})
+		return
+	}
+
+	// 4. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "視聴履歴を取り込みました",
+		"imported": result.Imported,
+		"notFound": result.NotFound,
+	})
+}
+
+// RemoveStatus は DELETE /api/me/library/:annictId へのリクエストを処理する
+// アニメをライブラリから外す（認証必須）
+func (h *LibraryHandler) RemoveStatus(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからAnnict IDを取得
+	annictID, err := strconv.Atoi(c.Param("annictId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anime ID"})
+		return
+	}
+
+	// 3. サービス層で削除
+	if err := h.service.RemoveStatus(userID, annictID); err != nil {
+		if errors.Is(err, services.ErrLibraryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove watch status"})
+		return
+	}
+
+	// 4. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{"message": "ライブラリから削除しました"})
+}
+
+// List は GET /api/me/library へのリクエストを処理する
+// 自分のライブラリを取得（認証必須）
+// URL: /api/me/library?status=watching
+func (h *LibraryHandler) List(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. サービス層でライブラリを取得（statusの指定がなければすべて）
+	entries, err := h.service.GetLibrary(userID, c.Query("status"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWatchStatus) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get library"})
+		return
+	}
+
+	// 3. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	service *services.PasswordResetService
+}
+
+func NewPasswordHandler(service *services.PasswordResetService) *PasswordHandler {
+	return &PasswordHandler{service: service}
+}
+
+// Forgot ハンドラー
+// POST /api/password/forgot: パスワード再設定用のリンクをメールで送る
+// 登録されていないメールアドレスかどうかを知られないように、常に同じレスポンスを返す
+func (h *PasswordHandler) Forgot(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.service.RequestReset(input.Email)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "登録されているメールアドレスの場合、パスワード再設定用のリンクを送信しました",
+	})
+}
+
+// Reset ハンドラー
+// POST /api/password/reset: メールのリンクのトークンを使って新しいパスワードを設定する
+// 再設定すると、すべての端末からログアウトし、ログインの失敗が続いてかかったロックも解ける
+func (h *PasswordHandler) Reset(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client := models.LoginClient{IPAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	if err := h.service.ResetPassword(input.Token, input.Password, client); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) || errors.Is(err, services.ErrPasswordTooLong) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/services"
+	"errors"
 
 	"net/http"
 
@@ -44,6 +45,9 @@ func (h *ReviewHandler) Create(c *gin.Context) {
 	// 3. サービス層でレビュー作成
 	review, err := h.service.CreateReview(userID, input)
 	if err != nil {
+		if respondAnnictError(c, err) {
+			return
+		}
 		// エラーメッセージに応じてステータスコードを変える
 		// この書き方だとエラーメッセージの文言に依存してしまうので
 		// 本当はカスタムエラー型を定義して判別したい
@@ -62,34 +66,192 @@ func (h *ReviewHandler) Create(c *gin.Context) {
 	})
 }
 
-// 特定のアニメのレビュー一覧を取得するハンドラー
-func (h *ReviewHandler) ListByAnime(c *gin.Context) {
+// Update は PUT /api/reviews/:id へのリクエストを処理する
+// 自分のレビューの編集（認証必須）
+func (h *ReviewHandler) Update(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
 
-	// 1. クエリパラメータからアニメIDを取得
-	animeIDStr := c.Query("anime_id")
+	// 2. パスパラメータからレビューIDを取得
+	reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	// 3. リクエストボディをパース
+	var input models.ReviewUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入力データが不正です: " + err.Error()})
+		return
+	}
 
-	// クエリパラメータは文字列なので
-	// animeIDを数値に変換（失敗したらエラーを返すなどの安全策）
-	animeID, err := strconv.ParseInt(animeIDStr, 10, 64)
+	// 4. サービス層でレビュー更新
+	review, err := h.service.UpdateReview(userID, reviewID, input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anime_id"})
+		respondReviewError(c, err)
+		return
+	}
+
+	// 5. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{
+		"message": "レビューを更新しました",
+		"review":  review,
+	})
+}
+
+// Delete は DELETE /api/reviews/:id へのリクエストを処理する
+// 自分のレビューの削除（認証必須）
+func (h *ReviewHandler) Delete(c *gin.Context) {
+
+	// 1. 認証ミドルウェアでセットされたユーザーIDを取得
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	// 2. パスパラメータからレビューIDを取得
+	reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	// 3. サービス層でレビュー削除
+	if err := h.service.DeleteReview(userID, reviewID); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	// 4. 成功レスポンス
+	c.JSON(http.StatusOK, gin.H{"message": "レビューを削除しました"})
+}
+
+// ListRevisions は GET /api/reviews/:id/revisions へのリクエストを処理する
+// レビューの編集履歴を古い順に返す
+func (h *ReviewHandler) ListRevisions(c *gin.Context) {
+
+	// 1. パスパラメータからレビューIDを取得
+	reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	// 2. サービス層でレビューと編集履歴を取得
+	review, revisions, err := h.service.GetReviewRevisions(reviewID)
+	if err != nil {
+		if errors.Is(err, services.ErrReviewNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get review revisions"})
+		return
+	}
+
+	// 3. 成功レスポンス
+	// review が現在の内容、data が更新前の内容（古い順）
+	c.JSON(http.StatusOK, gin.H{
+		"review": review,
+		"data":   revisions,
+	})
+}
+
+// respondReviewError はレビュー編集・削除時のエラーをステータスコードに変換して返す
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrReviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReviewForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to modify review"})
+	}
+}
+
+// 特定のアニメのレビュー一覧を取得するハンドラー
+// 並び順・絞り込み・カーソルによるページネーションに対応
+func (h *ReviewHandler) ListByAnime(c *gin.Context) {
+
+	// 1. クエリパラメータを構造体にバインド
+	// ShouldBindQueryは文字列のクエリパラメータを各フィールドの型に変換してくれる
+	var query models.ReviewListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
 		return
 	}
 
 	// 2. サービス層でレビュー一覧を取得
-	reviews, err := h.service.GetReviewsByAnimeID(animeID)
+	reviews, nextCursor, err := h.service.GetReviewsByAnimeID(query)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) ||
+			errors.Is(err, services.ErrInvalidSort) ||
+			errors.Is(err, services.ErrInvalidRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get reviews"})
 		return
 	}
 
 	// 3. 成功レスポンス
 	c.JSON(http.StatusOK, gin.H{
-		"data": reviews,
+		"data":       reviews,
+		"nextCursor": nextCursor,
 	})
 
 }
 
+// MarkHelpful は PUT /api/reviews/:id/helpful へのリクエストを処理する
+// レビューに「参考になった」を付ける（認証必須）
+func (h *ReviewHandler) MarkHelpful(c *gin.Context) {
+	h.setHelpful(c, true)
+}
+
+// UnmarkHelpful は DELETE /api/reviews/:id/helpful へのリクエストを処理する
+// レビューの「参考になった」を取り消す（認証必須）
+func (h *ReviewHandler) UnmarkHelpful(c *gin.Context) {
+	h.setHelpful(c, false)
+}
+
+// setHelpful は「参考になった」の付与・取り消しの共通処理
+func (h *ReviewHandler) setHelpful(c *gin.Context, helpful bool) {
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	userID := int64(userIDValue.(int))
+
+	reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	if err := h.service.MarkHelpful(userID, reviewID, helpful); err != nil {
+		switch {
+		case errors.Is(err, services.ErrReviewNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSelfHelpfulVote):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update helpful vote"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
 // 自分のレビュー一覧を取得するハンドラー
 func (h *ReviewHandler) ListByMe(c *gin.Context) {
 
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogSender はメールを送信せずに、内容を標準出力かファイルに書き出す開発用の Sender
+// ローカルでもパスワード再設定などのメールに含まれるリンクを確認できる
+type LogSender struct {
+	path string // 書き出し先のファイル（空なら標準出力）
+
+	mu sync.Mutex
+}
+
+// NewLogSender はLogSenderのインスタンスを生成する
+// path が空なら標準出力に、そうでなければファイルに追記する
+func NewLogSender(path string) *LogSender {
+	return &LogSender{path: path}
+}
+
+// Send はメールの内容を書き出す
+func (s *LogSender) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if s.path != "" {
+		f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open mail log: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err := fmt.Fprintf(w, "----- mail %s -----\nTo: %s\nSubject: %s\n\n%s\n-----\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("failed to write mail log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidHeader は宛先・件名に改行が含まれている場合のエラー
+// 改行を許すと、ヘッダーを追加されて別の宛先にメールを送られてしまう（ヘッダーインジェクション）
+var ErrInvalidHeader = errors.New("mail header must not contain line breaks")
+
+// Message は送信するメール（本文はプレーンテキスト）
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender はメールを送信するインターフェース
+// 本番では SMTPSender、開発ではメールを送らずに内容を出力する LogSender を使う
+type Sender interface {
+	Send(msg Message) error
+}
+
+// validate は宛先・件名に改行が含まれていないか確認する
+func (m Message) validate() error {
+	if m.To == "" {
+		return errors.New("mail recipient is empty")
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
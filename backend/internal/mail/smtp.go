@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// ErrStartTLSUnsupported はSMTPサーバーが STARTTLS に対応しておらず、暗号化せずに送ることも許可されていない場合のエラー
+var ErrStartTLSUnsupported = errors.New("SMTP server does not support STARTTLS")
+
+// SMTPConfig はSMTPサーバーの接続設定
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // 空なら認証しない
+	Password string
+	From     string        // 送信元のアドレス
+	Timeout  time.Duration // 接続から送信完了までのタイムアウト
+	// AllowPlaintext が true なら、STARTTLS に対応していないサーバーにも暗号化せずに送る
+	// 開発用のSMTPサーバー（MailHog など）向けで、本番では使わないこと
+	AllowPlaintext bool
+}
+
+// SMTPSender はSMTPサーバー経由でメールを送信する Sender
+// STARTTLS で暗号化してから認証・送信し、サーバーが対応していなければ送らずにエラーにする
+// （メールにはパスワード再設定などのリンクが含まれるので、途中で読まれないようにする）
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender はSMTPSenderのインスタンスを生成する
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{config: config}
+}
+
+// Send はメールを送信する
+func (s *SMTPSender) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid mail recipient: %w", err)
+	}
+	from, err := netmail.ParseAddress(s.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid mail sender: %w", err)
+	}
+
+	data, err := buildMessage(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	// smtp.SendMail にはタイムアウトがないので、自分で接続してから送信する
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, s.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if s.config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	} else if !s.config.AllowPlaintext {
+		return ErrStartTLSUnsupported
+	}
+	// smtp.PlainAuth は暗号化されていない接続では（localhost 以外）認証情報を送らずにエラーにする
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate to SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("failed to send MAIL command: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("failed to send RCPT command: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send DATA command: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage はヘッダーと本文からメールのデータを組み立てる
+// 日本語を含められるように、件名は MIME エンコードし、本文は UTF-8 の quoted-printable にする
+func buildMessage(from, to *netmail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(msg.Body)); err != nil {
+		return nil, fmt.Errorf("failed to encode mail body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode mail body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
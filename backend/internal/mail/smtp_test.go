@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startPlaintextSMTPServer は STARTTLS に対応していないSMTPサーバーを立て、受け取ったメールのデータを received に送る
+func startPlaintextSMTPServer(t *testing.T) (addr *net.TCPAddr, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(lines ...string) {
+			conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+		}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO":
+				reply("250-localhost", "250 8BITMIME")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				ch <- data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr), ch
+}
+
+func TestSMTPSenderRequiresStartTLS(t *testing.T) {
+	addr, received := startPlaintextSMTPServer(t)
+	sender := NewSMTPSender(SMTPConfig{
+		Host:    addr.IP.String(),
+		Port:    addr.Port,
+		From:    "no-reply@example.com",
+		Timeout: time.Second,
+	})
+
+	err := sender.Send(Message{To: "user@example.com", Subject: "test", Body: "secret link"})
+	if !errors.Is(err, ErrStartTLSUnsupported) {
+		t.Fatalf("Send() error = %v, want ErrStartTLSUnsupported", err)
+	}
+	select {
+	case <-received:
+		t.Error("mail should not be sent without TLS")
+	default:
+	}
+}
+
+func TestSMTPSenderAllowPlaintext(t *testing.T) {
+	addr, received := startPlaintextSMTPServer(t)
+	sender := NewSMTPSender(SMTPConfig{
+		Host:           addr.IP.String(),
+		Port:           addr.Port,
+		From:           "no-reply@example.com",
+		Timeout:        time.Second,
+		AllowPlaintext: true,
+	})
+
+	if err := sender.Send(Message{To: "user@example.com", Subject: "test", Body: "hello"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "To: <user@example.com>") {
+			t.Errorf("mail data = %q, want recipient header", data)
+		}
+	case <-time.After(time.Second):
+		t.Error("mail was not received")
+	}
+}
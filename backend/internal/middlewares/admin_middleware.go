@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminChecker はユーザーが管理者かを調べる
+// services.AuthService が実装する
+type AdminChecker interface {
+	IsAdmin(userID int) (bool, error)
+}
+
+// AdminMiddleware は管理者以外のリクエストを 403 で拒否するミドルウェア
+// AuthMiddleware の後に使う（c.Get("userID") でユーザーを判別する）
+func AdminMiddleware(checker AdminChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDValue, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+			c.Abort()
+			return
+		}
+
+		isAdmin, err := checker.IsAdmin(userIDValue.(int))
+		if err != nil {
+			log.Println("Failed to check admin:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permission"})
+			c.Abort()
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "管理者のみ利用できます"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
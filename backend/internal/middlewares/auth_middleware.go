@@ -1,77 +1,164 @@
 package middlewares
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"math"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// トークンの検証で発生するエラー（メッセージはそのままレスポンスに使う）
+var (
+	errTokenRequired = errors.New("Authentication token is required")
+	errTokenInvalid  = errors.New("Invalid or expired token")
+	errTokenClaims   = errors.New("Invalid token claims")
+	errTokenRevoked  = errors.New("Token has been revoked")
+	errTokenCheck    = errors.New("Failed to verify token")
+)
+
+// TokenVerifier はトークンの署名の検証に使う鍵を返し、トークンが失効済み（ログアウト済み）かを調べる
+// services.AuthService が実装する
+type TokenVerifier interface {
+	VerificationKey(token *jwt.Token) (any, error)
+	IsTokenRevoked(tokenID string, userID int, issuedAt time.Time) (bool, error)
+}
+
+// authToken は検証済みのトークンから取り出した情報
+type authToken struct {
+	userID    int
+	tokenID   string    // jti
+	expiresAt time.Time // exp
+}
+
 // 認証ミドルウェア
-func AuthMiddleware() gin.HandlerFunc {
+// verifier の鍵で署名を検証し、失効済みのトークンを弾く
+func AuthMiddleware(verifier TokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. トークンを取得（Authorization ヘッダー → Cookie の優先順）
-		var tokenString string
-		if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
-			// BFF からの Bearer トークンを優先
-			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			// フォールバック: Cookie からトークンを取得
-			var err error
-			tokenString, err = c.Cookie("auth_token")
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication token is required"})
-				c.Abort()
-				return
+		token, err := authenticate(c, verifier)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errTokenCheck) {
+				// DBに問い合わせられなかっただけなので、トークンが不正という扱いにはしない
+				status = http.StatusInternalServerError
 			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
 		}
 
-		// 3. トークンの検証
-		// ※ Login時と同じシークレットキーを使うこと！
-		secret_key := os.Getenv("JWT_SECRET_KEY")
-		secretKey := []byte(secret_key)
+		// c.set(key string, value any) でコンテキストに値を保存する
+		// 後のハンドラーで c.Get("userID") として取得可能
+		c.Set("userID", token.userID)
+		// ログアウト時にこのトークンを失効させるため、トークンIDと有効期限も保存する
+		c.Set("tokenID", token.tokenID)
+		c.Set("tokenExpiresAt", token.expiresAt)
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			// アルゴリズムがHMACかどうか確認（セキュリティ対策）
-			// 型アサーション
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return secretKey, nil
-		})
+		// 次の処理へ進む
+		c.Next()
+	}
+}
 
-		// 4. トークンが無効、または期限切れの場合
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
+// OptionalAuthMiddleware はログインしていなくても使えるエンドポイント用の認証ミドルウェア
+// 有効なトークンがあれば AuthMiddleware と同じく userID をセットし、
+// トークンがない・無効な場合もエラーにせずにそのまま次の処理へ進む
+// ハンドラーでは c.Get("userID") の exists でログインしているかを判別する
+func OptionalAuthMiddleware(verifier TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, err := authenticate(c, verifier); err == nil {
+			c.Set("userID", token.userID)
+			c.Set("tokenID", token.tokenID)
+			c.Set("tokenExpiresAt", token.expiresAt)
 		}
+		c.Next()
+	}
+}
 
-		// 5. トークンからユーザーIDを取り出し、コンテキストにセットする
-		// これにより、後のハンドラーで c.Get("userID") としてIDを使えるようになる
-		// JWTはヘッダー、ペイロード、署名の3部分から構成される
-		// JWTのクレームとは、ペイロード部分に含まれる情報(JSON形式)のこと
-		// claims, ok := token.Claims.(jwt.MapClaims)は、トークンのクレームを
-		// jwt.MapClaims型に変換し、okがtrueなら成功、falseなら失敗を示す
-		// // token.Claims は interface{} 型であり、キーを指定できないからmap型に変換する
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// float64型にしないとint()を使えない
-			// claims["user_id"]のuser_idはJWT生成時にペイロードに設定したキー
-			if userID, ok := claims["user_id"].(float64); ok {
-				// c.set(key string, value any) でコンテキストに値を保存する
-				// 後のハンドラーで c.Get("userID") として取得可能
-				c.Set("userID", int(userID))
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-				c.Abort()
-				return
-			}
+// authenticate はリクエストのトークンを検証し、トークンの情報を返す
+func authenticate(c *gin.Context, verifier TokenVerifier) (*authToken, error) {
+	// 1. トークンを取得（Authorization ヘッダー → Cookie の優先順）
+	var tokenString string
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		// BFF からの Bearer トークンを優先
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	} else {
+		// フォールバック: Cookie からトークンを取得
+		var err error
+		tokenString, err = c.Cookie("auth_token")
+		if err != nil {
+			return nil, errTokenRequired
 		}
+	}
 
-		// 6. 次の処理へ進む
-		c.Next()
+	// 2. トークンの検証
+	// ヘッダーの kid に対応する鍵で署名を検証する（アルゴリズムが鍵のものと一致するかも確認される）
+	token, err := jwt.Parse(tokenString, verifier.VerificationKey)
+
+	// 3. トークンが無効、または期限切れの場合
+	if err != nil || !token.Valid {
+		return nil, errTokenInvalid
+	}
+
+	// 4. トークンからユーザーIDを取り出す
+	// JWTはヘッダー、ペイロード、署名の3部分から構成される
+	// JWTのクレームとは、ペイロード部分に含まれる情報(JSON形式)のこと
+	// claims, ok := token.Claims.(jwt.MapClaims)は、トークンのクレームを
+	// jwt.MapClaims型に変換し、okがtrueなら成功、falseなら失敗を示す
+	// // token.Claims は interface{} 型であり、キーを指定できないからmap型に変換する
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errTokenClaims
+	}
+
+	// float64型にしないとint()を使えない
+	// claims["user_id"]のuser_idはJWT生成時にペイロードに設定したキー
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errTokenClaims
+	}
+
+	// 5. 失効済み（ログアウト済み）でないか確認
+	// jti・iat がないトークンは失効を確認できないので受け付けない
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, errTokenClaims
+	}
+	issuedAt, ok := issuedAtFromClaims(claims)
+	if !ok {
+		return nil, errTokenClaims
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, errTokenClaims
+	}
+
+	revoked, err := verifier.IsTokenRevoked(tokenID, int(userID), issuedAt)
+	if err != nil {
+		log.Println("Failed to check token revocation:", err)
+		return nil, errTokenCheck
+	}
+	if revoked {
+		return nil, errTokenRevoked
+	}
+
+	return &authToken{
+		userID:    int(userID),
+		tokenID:   tokenID,
+		expiresAt: expiresAt.Time,
+	}, nil
+}
+
+// issuedAtFromClaims はトークンの iat（発行日時）をマイクロ秒まで取り出す
+// claims.GetIssuedAt は秒未満を切り捨てる（jwt.TimePrecision が秒のため）ので、値をそのまま変換する
+// 小数で表した秒は浮動小数点の誤差を含むので、マイクロ秒に丸めてから戻す
+func issuedAtFromClaims(claims jwt.MapClaims) (time.Time, bool) {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}, false
 	}
+	return time.UnixMicro(int64(math.Round(iat * 1e6))), true
 }
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testKey = []byte("test-secret")
+
+// fakeVerifier は revokedBefore 以前に発行されたトークンを失効済みとして扱う
+// TokenRevocationRepository.IsRevoked の revoked_before >= iat と同じ比較をする
+type fakeVerifier struct {
+	revokedBefore time.Time
+}
+
+func (v *fakeVerifier) VerificationKey(token *jwt.Token) (any, error) {
+	return testKey, nil
+}
+
+func (v *fakeVerifier) IsTokenRevoked(tokenID string, userID int, issuedAt time.Time) (bool, error) {
+	return !v.revokedBefore.Before(issuedAt), nil
+}
+
+// signTestToken は AuthService.generateToken と同じ形（iat はマイクロ秒までの小数）のトークンを作る
+func signTestToken(t *testing.T, issuedAt time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"jti":     "token-id",
+		"iat":     float64(issuedAt.UnixMicro()) / 1e6,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(testKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareComparesSubSecondIssuedAt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 「すべての端末からログアウト」した時刻。前後のトークンは同じ秒に発行されている
+	revokedBefore := time.Now().Add(-time.Minute).Truncate(time.Second).Add(500 * time.Millisecond)
+	r := gin.New()
+	r.GET("/me", AuthMiddleware(&fakeVerifier{revokedBefore: revokedBefore}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     int
+	}{
+		{"issued before revocation", revokedBefore.Add(-300 * time.Millisecond), http.StatusUnauthorized},
+		{"issued at revocation", revokedBefore, http.StatusUnauthorized},
+		{"issued after revocation in the same second", revokedBefore.Add(300 * time.Millisecond), http.StatusOK},
+		{"issued one microsecond after revocation", revokedBefore.Add(time.Microsecond), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, tt.issuedAt))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssuedAtFromClaims(t *testing.T) {
+	issuedAt := time.Date(2026, 10, 16, 12, 0, 5, 123456000, time.UTC)
+	got, ok := issuedAtFromClaims(jwt.MapClaims{"iat": float64(issuedAt.UnixMicro()) / 1e6})
+	if !ok || !got.Equal(issuedAt) {
+		t.Errorf("issuedAtFromClaims() = %v, %v, want %v", got, ok, issuedAt)
+	}
+
+	// 秒単位の iat（以前に発行したトークン）もそのまま読める
+	got, ok = issuedAtFromClaims(jwt.MapClaims{"iat": float64(1760616005)})
+	if !ok || !got.Equal(time.Unix(1760616005, 0)) {
+		t.Errorf("issuedAtFromClaims() = %v, %v, want whole seconds", got, ok)
+	}
+
+	if _, ok := issuedAtFromClaims(jwt.MapClaims{}); ok {
+		t.Error("issuedAtFromClaims() should fail without iat")
+	}
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter は同じキー（IPアドレスなど）からのリクエストを、一定期間ごとの回数で制限する
+// 期間の始まりから数える固定ウィンドウ方式で、状態はプロセス内だけに持つ
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	counts    map[string]*rateLimitCount
+	lastPrune time.Time
+}
+
+// rateLimitCount は1つのキーの、今の期間に受け付けた回数
+type rateLimitCount struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter は window の間に limit 回までリクエストを受け付ける RateLimiter を生成する
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		counts: map[string]*rateLimitCount{},
+	}
+}
+
+// Allow は key からのリクエストを受け付けてよいかを返す
+// 受け付けない場合は、次に受け付けられるまでの時間も返す（limit が0以下なら制限しない）
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 期間が過ぎたキーを残しておくと、IPアドレスの数だけメモリが増え続けるので、ときどき掃除する
+	if now.Sub(l.lastPrune) >= l.window {
+		for k, c := range l.counts {
+			if now.Sub(c.start) >= l.window {
+				delete(l.counts, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	c, ok := l.counts[key]
+	if !ok || now.Sub(c.start) >= l.window {
+		c = &rateLimitCount{start: now}
+		l.counts[key] = c
+	}
+	if c.count >= l.limit {
+		return false, c.start.Add(l.window).Sub(now)
+	}
+	c.count++
+	return true, 0
+}
+
+// RateLimitMiddleware はクライアントのIPアドレスごとに limiter で回数を制限する
+// 超えた場合は 429 と Retry-After ヘッダーを返す
+func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, retryAfter := limiter.Allow(c.ClientIP(), time.Now())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "リクエストが多すぎます。しばらくしてから再度お試しください"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute)
+	now := time.Now()
+
+	for i := range 2 {
+		if ok, _ := limiter.Allow("198.51.100.1", now); !ok {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("198.51.100.1", now.Add(10*time.Second))
+	if ok {
+		t.Fatal("third request should be rejected")
+	}
+	if retryAfter != 50*time.Second {
+		t.Errorf("retryAfter = %v, want 50s", retryAfter)
+	}
+
+	// ほかのIPアドレスは別に数える
+	if ok, _ := limiter.Allow("198.51.100.2", now); !ok {
+		t.Error("another key should be allowed")
+	}
+
+	// 期間が過ぎたらまた受け付ける
+	if ok, _ := limiter.Allow("198.51.100.1", now.Add(time.Minute)); !ok {
+		t.Error("request after the window should be allowed")
+	}
+}
+
+func TestRateLimiterWithoutLimit(t *testing.T) {
+	limiter := NewRateLimiter(0, time.Minute)
+	for range 10 {
+		if ok, _ := limiter.Allow("198.51.100.1", time.Now()); !ok {
+			t.Fatal("limit 0 should not reject requests")
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/forgot", RateLimitMiddleware(NewRateLimiter(1, time.Minute)), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/forgot", nil)
+		req.RemoteAddr = "198.51.100.1:12345"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusAccepted {
+		t.Fatalf("first status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	w := send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationChecker はユーザーがメールアドレスを確認済みかを調べる
+// services.EmailVerificationService が実装する
+type EmailVerificationChecker interface {
+	IsEmailVerified(userID int) (bool, error)
+}
+
+// VerifiedEmailMiddleware はメールアドレスを確認していないユーザーのリクエストを 403 で拒否するミドルウェア
+// AuthMiddleware の後に使う（c.Get("userID") でユーザーを判別する）
+// required が false の場合は何も確認せずに次の処理へ進む（設定で確認を必須にするかを切り替えるため）
+func VerifiedEmailMiddleware(checker EmailVerificationChecker, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !required {
+			c.Next()
+			return
+		}
+
+		userIDValue, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+			c.Abort()
+			return
+		}
+
+		verified, err := checker.IsEmailVerified(userIDValue.(int))
+		if err != nil {
+			log.Println("Failed to check email verification:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "メールアドレスの確認が必要です。届いたメールのリンクから確認してください"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+// AniListClientConfig は AniList API クライアントの設定
+// 一時的な障害は再試行し、障害が続く場合はサーキットブレーカーで問い合わせを止める
+type AniListClientConfig struct {
+	RetryConfig
+	Endpoint string // GraphQL API のURL（空なら本物のAniList）
+}
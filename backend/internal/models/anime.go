@@ -2,28 +2,122 @@ package models
 
 import "time"
 
-// Anime は Annict から取得した作品データをローカルにキャッシュするモデル。
+// Anime は Annict（Annictにない作品は AniList など）から取得した作品データをローカルにキャッシュするモデル。
+// 取得元が持っていない項目はnullになる
 type Anime struct {
-	ID        int64     `db:"id" json:"id"`
-	AnnictID  int64     `db:"annictid" json:"annictId"`
-	Title     string    `db:"title" json:"title"`
-	Year      int       `db:"year" json:"year"` // intは環境依存で最大値が異なるので数が大きくなる可能性のあるIDはint64を使う
-	ImageURL  *string   `db:"image_url" json:"imageUrl"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ID              int64     `db:"id" json:"id"`
+	AnnictID        *int64    `db:"annict_id" json:"annictId"` // Annictにない作品（AniListにしかない作品など）はnull
+	Title           string    `db:"title" json:"title"`
+	TitleKana       *string   `db:"title_kana" json:"titleKana"`
+	TitleEn         *string   `db:"title_en" json:"titleEn"`
+	Year            int       `db:"year" json:"year"`     // intは環境依存で最大値が異なるので数が大きくなる可能性のあるIDはint64を使う
+	Season          *string   `db:"season" json:"season"` // winter / spring / summer / autumn（不明な場合はnull）
+	Media           *string   `db:"media" json:"media"`   // tv / ova / movie / web / other
+	EpisodesCount   int       `db:"episodes_count" json:"episodesCount"`
+	ImageURL        *string   `db:"image_url" json:"imageUrl"`
+	OfficialSiteURL *string   `db:"official_site_url" json:"officialSiteUrl"`
+	TwitterUsername *string   `db:"twitter_username" json:"twitterUsername"`
+	WikipediaURL    *string   `db:"wikipedia_url" json:"wikipediaUrl"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updatedAt"` // 取得元の情報が最後に変わった日時（再取得しても内容が同じなら変えない）
+	SyncedAt        time.Time `db:"synced_at" json:"syncedAt"`   // 最後にAnnictと同期した日時
+	// 取得元ごとの作品ID（例: {"annict": 1234, "anilist": 5678}）
+	// animes テーブルの列ではないので、必要なときだけ anime_external_ids から取得して入れる
+	ExternalIDs map[string]int `db:"-" json:"externalIds,omitempty"`
 }
 
 // AnimeStats はビュー anime_stats の集計結果を表すモデル。
+// 詳細ページ用に、スコアの分布（ヒストグラム・中央値・標準偏差・パーセンタイル順位）も持つ
+// レビューがない場合、分布の値はすべて0になる
 type AnimeStats struct {
-	AnimeID     int64   `db:"anime_id" json:"animeId"`
-	ReviewCount int     `db:"review_count" json:"reviewCount"`
-	AvgScore    float64 `db:"avg_score" json:"avgScore"`
+	AnimeID         int64                 `db:"anime_id" json:"animeId"`
+	ReviewCount     int                   `db:"review_count" json:"reviewCount"`
+	AvgScore        float64               `db:"avg_score" json:"avgScore"`
+	Median          float64               `db:"median" json:"median"`
+	StdDev          float64               `db:"stddev" json:"stdDev"` // 母標準偏差
+	PercentileRanks []ScorePercentileRank `json:"percentileRanks"`    // 付けられたスコアごとのパーセンタイル順位（スコアの昇順）
+	Histogram       []ScoreBucket         `json:"histogram"`
+	Episodes        []EpisodeStats        `json:"episodes"` // エピソードごとの平均点（キャッシュ済みのエピソードのみ）
+}
+
+// ScorePercentileRank はあるスコアのパーセンタイル順位（0〜100）
+// (そのスコアより低いレビュー数 + そのスコアのレビュー数 / 2) / 全レビュー数 × 100 で求める
+// 例: 80点の PercentileRank が 75 なら、80点はレビュー全体の下から75%の位置にある
+type ScorePercentileRank struct {
+	Score          int     `db:"score" json:"score"`
+	Count          int     `db:"count" json:"count"` // このスコアを付けたレビュー数
+	PercentileRank float64 `db:"percentile_rank" json:"percentileRank"`
+}
+
+// ScoreBucket はヒストグラムの1区間（Min点以上Max点以下）のレビュー数
+type ScoreBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
 }
 
+// ScoreBucketWidth はヒストグラムの区間幅
+// 0-9, 10-19, ..., 90-100 の10区間になる（100点は最後の区間に含める）
+const ScoreBucketWidth = 10
+
 // AnimeWithStats はアニメ情報と統計情報を一緒に持つ構造体
 type AnimeWithStats struct {
 	Anime               // フィールド名を書かずに型名だけを書くと埋め込みとなり、子のフィールドにあたかも親のフィールドのようにアクセスできる
 	ReviewCount int     `db:"review_count" json:"reviewCount"`
 	AvgScore    float64 `db:"avg_score" json:"avgScore"`
+	// ランキングの並び替えに使ったスコア（sort=average のときは AvgScore と同じ）
+	WeightedScore float64 `db:"weighted_score" json:"weightedScore"`
+}
+
+// AnimeRefreshConfig はキャッシュ済みアニメをAnnictから再取得するバックグラウンド処理の設定
+type AnimeRefreshConfig struct {
+	Interval    time.Duration // 再取得処理を実行する間隔（0以下なら実行しない）
+	StaleAfter  time.Duration // 最後の同期からこの時間が経ったアニメを再取得する
+	BatchSize   int           // 1回の処理で再取得する最大件数
+	Concurrency int           // 再取得したアニメの保存・対応付けの同時実行数の上限
+}
+
+// アニメ一覧（ランキング）の並び順
+const (
+	AnimeSortAverage  = "average"  // 平均点順（デフォルト）
+	AnimeSortBayesian = "bayesian" // ベイズ平均順: レビュー数が少ないほど全体平均に引き寄せる
+	AnimeSortWilson   = "wilson"   // Wilsonスコア区間の下限順: レビュー数が少ないほど低めに見積もる
+)
+
+// RankingOptions はアニメ一覧の並び順と、重み付けスコアの計算に使うパラメータ
+type RankingOptions struct {
+	Sort string
+	// ベイズ平均で全体平均をどれだけの票数ぶん混ぜるか（最低得票数のしきい値）
+	// レビュー数がこの値と同じとき、作品の平均と全体平均が半々で混ざる
+	MinVotes int
+	// Wilsonスコアの信頼水準に対応する z 値（1.96 で95%）
+	Z float64
+}
+
+// 放送シーズン（Annict の seasonName を小文字にしたもの）
+const (
+	SeasonWinter = "winter"
+	SeasonSpring = "spring"
+	SeasonSummer = "summer"
+	SeasonAutumn = "autumn"
+)
+
+// ValidSeason はシーズン名が有効かチェック
+func ValidSeason(season string) bool {
+	switch season {
+	case SeasonWinter, SeasonSpring, SeasonSummer, SeasonAutumn:
+		return true
+	}
+	return false
+}
+
+// AnimeListFilter はアニメ一覧の絞り込み条件（nilの項目は絞り込まない）
+// 例: 2024年春アニメ → Year=2024, Season="spring"
+type AnimeListFilter struct {
+	Year     *int
+	Season   *string
+	YearFrom *int
+	YearTo   *int
 }
 
 // AnimeListResponse はアニメ一覧のレスポンス形式
@@ -39,3 +133,36 @@ type Pagination struct {
 	Total     int `json:"total"`
 	TotalPage int `json:"totalPage"`
 }
+
+// 検索結果の取得元
+const (
+	AnimeSearchSourceLocal  = "local"  // DBに保存済みのアニメ
+	AnimeSearchSourceAnnict = "annict" // Annict APIの検索結果
+)
+
+// AnimeSearchResult はアニメ検索結果の1件
+// 取得元にかかわらずAnnictWorkと同じ形で返し、取得元とレビューの統計情報を付け加える
+// DBにまだ保存されていない作品は reviewCount = 0, avgScore = 0 になる
+type AnimeSearchResult struct {
+	AnnictWork
+	Source      string  `json:"source"` // local / annict
+	ReviewCount int     `json:"reviewCount"`
+	AvgScore    float64 `json:"avgScore"`
+	MyScore     *int    `json:"myScore"` // ログイン中のユーザー自身のスコア（未ログイン・未レビューならnull）
+}
+
+// AnimeReviewSummary は検索結果に付け加える、Annict IDごとのレビューの統計情報
+type AnimeReviewSummary struct {
+	AnnictID    int     `db:"annict_id"`
+	ReviewCount int     `db:"review_count"`
+	AvgScore    float64 `db:"avg_score"`
+	MyScore     *int    `db:"my_score"`
+}
+
+// AnimeSearchCursor は検索結果の続きを取得するためのカーソル（JSONをbase64エンコードしてクライアントに返す）
+// DBの検索結果を返し切るまでは Offset を進め、その後は Annict のカーソルで続きを取得する
+type AnimeSearchCursor struct {
+	Offset       int    `json:"offset,omitempty"`
+	Annict       bool   `json:"annict,omitempty"`       // DBの検索結果を返し終え、Annictの検索結果に移ったか
+	AnnictCursor string `json:"annictCursor,omitempty"` // Annict APIのカーソル（初回は空）
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // AnnictGraphQLResponse はAnnict APIからのレスポンス全体を受け取る構造体
 // アニメ情報は複数返ってくるからスライスにする
 type AnnictGraphQLResponse struct {
@@ -16,11 +18,20 @@ type AnnictGraphQLResponse struct {
 
 // AnnictWork は単一のアニメ作品情報を表す構造体
 // 要件定義書の「取得・利用する情報」に対応
+// Annict の Work にはあらすじのフィールドがないため、あらすじは取得できない
 type AnnictWork struct {
-	AnnictID   int    `json:"annictId"`
-	Title      string `json:"title"`
-	SeasonYear *int   `json:"seasonYear"` // nullの場合があるためポインタ(int型にnullはない)
-	Image      struct {
+	AnnictID        int     `json:"annictId"`
+	Title           string  `json:"title"`
+	TitleKana       string  `json:"titleKana"`
+	TitleEn         string  `json:"titleEn"`
+	SeasonYear      *int    `json:"seasonYear"`      // nullの場合があるためポインタ(int型にnullはない)
+	SeasonName      *string `json:"seasonName"`      // WINTER / SPRING / SUMMER / AUTUMN（nullの場合あり）
+	Media           string  `json:"media"`           // TV / OVA / MOVIE / WEB / OTHER
+	EpisodesCount   int     `json:"episodesCount"`   // エピソード数
+	OfficialSiteUrl string  `json:"officialSiteUrl"` // 公式サイトURL（ない場合は空文字）
+	TwitterUsername string  `json:"twitterUsername"` // 公式Twitterのユーザー名
+	WikipediaUrl    string  `json:"wikipediaUrl"`    // WikipediaのURL
+	Image           struct {
 		RecommendedImageUrl string `json:"recommendedImageUrl"`
 	} `json:"image"`
 }
@@ -30,3 +41,40 @@ type PageInfo struct {
 	HasNextPage bool   `json:"hasNextPage"` // 次のページがあるか
 	EndCursor   string `json:"endCursor"`   // 次のページの開始位置
 }
+
+// AnnictEpisodesResponse は作品のエピソード一覧を取得したときのレスポンス
+// searchWorks で作品を1件取得し、その episodes をたどる
+type AnnictEpisodesResponse struct {
+	Data struct {
+		SearchWorks struct {
+			Nodes []struct {
+				Episodes struct {
+					Nodes    []AnnictEpisode `json:"nodes"`
+					PageInfo PageInfo        `json:"pageInfo"`
+				} `json:"episodes"`
+			} `json:"nodes"`
+		} `json:"searchWorks"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// AnnictEpisode は単一のエピソード情報を表す構造体
+type AnnictEpisode struct {
+	AnnictID   int     `json:"annictId"`
+	Number     *int    `json:"number"`     // 話数（総集編などはnull）
+	NumberText *string `json:"numberText"` // 表示用の話数（例: "第1話"）
+	SortNumber int     `json:"sortNumber"` // 並び順
+	Title      *string `json:"title"`      // サブタイトル（未定の場合はnull）
+}
+
+// AnnictClientConfig は Annict API クライアントの設定
+// 一時的な障害は再試行し、障害が続く場合はサーキットブレーカーで問い合わせを止める
+type AnnictClientConfig struct {
+	RetryConfig
+	Endpoint        string        // GraphQL API のURL（空なら本物のAnnict）
+	SearchCacheTTL  time.Duration // 検索結果をキャッシュする時間（0でキャッシュしない）
+	SearchCacheSize int           // キャッシュする検索結果の最大件数
+	BatchSize       int           // ID指定で作品をまとめて取得するときに、1回のリクエストで指定するIDの数（上限50）
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// EmailVerificationConfig はメールアドレスの確認の設定
+type EmailVerificationConfig struct {
+	Secret             []byte        // 確認用のリンクの署名に使う鍵
+	TokenTTL           time.Duration // 確認用のリンクの有効期限
+	ResendInterval     time.Duration // 確認メールを再送できる最短の間隔
+	VerifyURL          string        // メールに載せる確認ページのURL（?token= を付けて送る）
+	RequiredForReviews bool          // true ならメールアドレスを確認していないユーザーはレビューを投稿できない
+}
+
+// VerifyEmailInput: メールのリンクに含まれる確認用のトークン
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Episode は Annict から取得したエピソードをローカルにキャッシュするモデル。
+type Episode struct {
+	ID         int64     `db:"id" json:"id"`
+	AnimeID    int64     `db:"anime_id" json:"animeId"`
+	AnnictID   int64     `db:"annict_id" json:"annictId"`
+	Number     *int      `db:"number" json:"number"`
+	NumberText *string   `db:"number_text" json:"numberText"`
+	SortNumber int       `db:"sort_number" json:"sortNumber"`
+	Title      *string   `db:"title" json:"title"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
+
+// EpisodeStats はエピソードごとの視聴数と平均点
+// 詳細ページで AnimeStats と一緒に返す
+type EpisodeStats struct {
+	EpisodeID  int64    `db:"episode_id" json:"episodeId"`
+	Number     *int     `db:"number" json:"number"`
+	NumberText *string  `db:"number_text" json:"numberText"`
+	SortNumber int      `db:"sort_number" json:"sortNumber"`
+	Title      *string  `db:"title" json:"title"`
+	WatchCount int      `db:"watch_count" json:"watchCount"` // 視聴済みにしたユーザー数
+	ScoreCount int      `db:"score_count" json:"scoreCount"` // スコアを付けたユーザー数
+	AvgScore   *float64 `db:"avg_score" json:"avgScore"`     // スコアがない場合はnull
+}
+
+// EpisodeWatch はユーザーがエピソードを視聴済みにした記録
+// スコアは任意で、視聴済みにするだけでもよい
+type EpisodeWatch struct {
+	UserID    int64     `db:"user_id" json:"userId"`
+	EpisodeID int64     `db:"episode_id" json:"episodeId"`
+	Score     *int      `db:"score" json:"score"`
+	WatchedAt time.Time `db:"watched_at" json:"watchedAt"`
+}
+
+// EpisodeWatchInput はエピソード視聴記録時の入力データ
+// スコアを付けない場合は省略する
+type EpisodeWatchInput struct {
+	Score *int `json:"score" binding:"omitempty,min=0,max=100"`
+}
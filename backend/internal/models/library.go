@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// 視聴ステータス
+const (
+	WatchStatusWatching    = "watching"      // 視聴中
+	WatchStatusCompleted   = "completed"     // 視聴完了
+	WatchStatusDropped     = "dropped"       // 視聴中止
+	WatchStatusPlanToWatch = "plan_to_watch" // 視聴予定
+)
+
+// ValidWatchStatus は視聴ステータスが有効かチェック
+func ValidWatchStatus(status string) bool {
+	switch status {
+	case WatchStatusWatching, WatchStatusCompleted, WatchStatusDropped, WatchStatusPlanToWatch:
+		return true
+	}
+	return false
+}
+
+// UserAnimeStatus はユーザーがアニメに設定した視聴ステータスを保持するモデル。
+// レビュー（スコア）とは独立していて、採点せずに視聴状況だけを記録できる
+type UserAnimeStatus struct {
+	UserID    int64     `db:"user_id" json:"userId"`
+	AnimeID   int64     `db:"anime_id" json:"animeId"`
+	Status    string    `db:"status" json:"status"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// LibraryStatusInput は視聴ステータス設定時の入力データ
+type LibraryStatusInput struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// LibraryImportInput は視聴履歴をまとめて取り込むときの入力データ
+type LibraryImportInput struct {
+	Entries []LibraryImportEntry `json:"entries" binding:"required,min=1,max=500,dive"`
+}
+
+// LibraryImportEntry は取り込む視聴履歴の1件分
+type LibraryImportEntry struct {
+	AnnictID int    `json:"annictId" binding:"required"`
+	Status   string `json:"status" binding:"required"`
+}
+
+// LibraryImportResult は視聴履歴の取り込み結果
+type LibraryImportResult struct {
+	Imported int   `json:"imported"` // 視聴ステータスを保存した件数
+	NotFound []int `json:"notFound"` // Annictに存在せず取り込めなかったAnnict ID
+}
+
+// LibraryEntry はライブラリ（視聴ステータス一覧）の1件分
+// 視聴ステータスとアニメ情報を組み合わせた構造体
+type LibraryEntry struct {
+	AnimeID   int64     `db:"anime_id" json:"animeId"`
+	Status    string    `db:"status" json:"status"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+	// アニメ情報
+	AnimeAnnictID int64   `db:"anime_annict_id" json:"animeAnnictId"`
+	AnimeTitle    string  `db:"anime_title" json:"animeTitle"`
+	AnimeYear     int     `db:"anime_year" json:"animeYear"`
+	AnimeSeason   *string `db:"anime_season" json:"animeSeason"`
+	AnimeImageURL *string `db:"anime_image_url" json:"animeImageUrl"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// ログインの試行結果
+const (
+	LoginResultSuccess            = "success"             // ログイン成功
+	LoginResultInvalidCredentials = "invalid_credentials" // メールアドレスかパスワードが違う
+	LoginResultThrottled          = "throttled"           // 失敗が続いたため、パスワードを確認せずに拒否した
+	LoginResultPasswordReset      = "password_reset"      // パスワードを再設定した（メールアドレスの失敗回数がリセットされる）
+)
+
+// LoginThrottleConfig はログインの総当たり攻撃への対策の設定
+// 失敗が FreeAttempts 回を超えると、最後の失敗から BaseDelay, 2倍, 4倍…（最大 MaxDelay）待つまでログインできなくなり、
+// LockoutThreshold 回に達すると LockoutDuration の間ロックする
+// 失敗回数はメールアドレス単位（ログインに成功するかパスワードを再設定したらリセット）とIPアドレス単位のそれぞれで Window の間だけ数える
+type LoginThrottleConfig struct {
+	Window                  time.Duration // 失敗回数を数える期間
+	BaseDelay               time.Duration // 最初の待ち時間
+	MaxDelay                time.Duration // 待ち時間の上限
+	LockoutDuration         time.Duration // ロックする時間
+	AccountFreeAttempts     int           // メールアドレスごとに、待たずに再試行できる失敗回数
+	AccountLockoutThreshold int           // メールアドレスごとに、ロックする失敗回数（0でロックしない）
+	IPFreeAttempts          int           // IPアドレスごとに、待たずに再試行できる失敗回数
+	IPLockoutThreshold      int           // IPアドレスごとに、ロックする失敗回数（0でロックしない）
+	AuditRetention          time.Duration // 試行履歴を残す期間（0で削除しない）
+	ThrottledRecordInterval time.Duration // 拒否した試行を、同じメールアドレス・IPアドレスにつき記録する最短の間隔（0ならすべて記録）
+}
+
+// LoginClient はログインを試みたクライアントの情報
+type LoginClient struct {
+	IPAddress string
+	UserAgent string
+}
+
+// LoginAttempt 構造体: DBのlogin_attemptsテーブルに対応（ログインの試行履歴）
+type LoginAttempt struct {
+	ID        int64     `db:"id" json:"id"`
+	Email     string    `db:"email" json:"email"`
+	UserID    *int      `db:"user_id" json:"userId"` // 登録されていないメールアドレスならnull
+	IPAddress string    `db:"ip_address" json:"ipAddress"`
+	UserAgent string    `db:"user_agent" json:"userAgent"`
+	Result    string    `db:"result" json:"result"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// LoginFailureStats は一定期間内のログインの失敗回数と、最後に失敗した日時
+type LoginFailureStats struct {
+	Count        int        `db:"count"`
+	LastFailedAt *time.Time `db:"last_failed_at"`
+}
+
+// LoginAttemptListQuery はログインの試行履歴の取得時のクエリパラメータ（管理者用）
+// 例: /api/admin/login-attempts?email=foo@example.com&ip=203.0.113.1&result=invalid_credentials&limit=50&cursor=xxx
+type LoginAttemptListQuery struct {
+	Email  string `form:"email"`
+	IP     string `form:"ip"`
+	UserID *int   `form:"user_id"`
+	Result string `form:"result"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// LoginAttemptListOptions はログインの試行履歴の取得条件（Repositoryに渡す形）
+// 新しい順に並べ、BeforeID があればそれより古い（IDが小さい）ものだけを返す
+type LoginAttemptListOptions struct {
+	Email    string
+	IP       string
+	UserID   *int
+	Result   string
+	Limit    int
+	BeforeID int64
+}
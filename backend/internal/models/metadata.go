@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// アニメのメタデータ（タイトル・放送時期・画像など）の取得元
+const (
+	MetadataProviderAnnict  = "annict"
+	MetadataProviderAniList = "anilist"
+)
+
+// MetadataWork は取得元にかかわらない共通の作品情報
+// 各取得元（Annict / AniList）のレスポンスをこの形に変換して扱う
+type MetadataWork struct {
+	Provider        string  // 取得元 (annict / anilist)
+	ExternalID      int     // 取得元での作品ID
+	Title           string  // 正式タイトル（日本語）
+	TitleKana       string  // 読み仮名（ない場合は空文字）
+	TitleEn         string  // 英語・ローマ字タイトル（ない場合は空文字）
+	SeasonYear      *int    // 放送年（不明ならnil）
+	SeasonName      *string // WINTER / SPRING / SUMMER / AUTUMN（不明ならnil）
+	Media           string  // TV / OVA / MOVIE / WEB / OTHER
+	EpisodesCount   int     // エピソード数
+	ImageURL        string  // 作品画像URL
+	OfficialSiteURL string  // 公式サイトURL
+	TwitterUsername string  // 公式Twitterのユーザー名
+	WikipediaURL    string  // WikipediaのURL
+}
+
+// AnimeExternalID はDBのアニメと、各取得元での作品IDの対応
+type AnimeExternalID struct {
+	AnimeID    int64  `db:"anime_id" json:"animeId"`
+	Provider   string `db:"provider" json:"provider"`
+	ExternalID int    `db:"external_id" json:"externalId"`
+}
+
+// RetryConfig は取得元のAPIクライアントの再試行・サーキットブレーカーの設定（Annict・AniList で共通）
+type RetryConfig struct {
+	Timeout          time.Duration // 1回のリクエストのタイムアウト
+	MaxRetries       int           // 5xx・タイムアウト時の再試行回数（0で再試行しない）
+	BaseBackoff      time.Duration // 再試行までの待ち時間の基準（試行ごとに2倍になる）
+	MaxBackoff       time.Duration // 再試行までの待ち時間の上限
+	FailureThreshold int           // 連続で何回失敗したらサーキットブレーカーを開くか（0で無効）
+	OpenTimeout      time.Duration // サーキットブレーカーを開いてから、再び問い合わせるまでの時間
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// PasswordResetConfig はパスワード再設定の設定
+type PasswordResetConfig struct {
+	TokenTTL       time.Duration // 再設定用のトークン（メールのリンク）の有効期限
+	ResendInterval time.Duration // 同じユーザーに再設定メールを送る最短の間隔（メールを大量に送られないようにする）
+	ResetURL       string        // メールに載せる再設定ページのURL（?token= を付けて送る）
+	QueueSize      int           // 送信待ちにできる再設定メールの数（超えた依頼は送らずに捨てる）
+	Workers        int           // 再設定メールを送る goroutine の数
+	RateLimit      int           // 同じIPアドレスから RateWindow の間に受け付ける送信依頼の数（0で無制限）
+	RateWindow     time.Duration // RateLimit を数える期間
+}
+
+// PasswordResetToken 構造体: DBのpassword_reset_tokensテーブルに対応
+// リフレッシュトークンと同じく、トークン自体は保存せずSHA-256のハッシュだけを保存する
+type PasswordResetToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"` // 使用済み（または無効化した）日時
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// ForgotPasswordInput: パスワード再設定メールの送信依頼
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordInput: メールのリンクのトークンと新しいパスワード
+// bcrypt は72バイトを超えるパスワードを扱えないので、長すぎるものは受け付けない
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
+}
@@ -4,29 +4,106 @@ import "time"
 
 // Review はユーザーがアニメに付けたスコアと任意コメントを保持するモデル。
 type Review struct {
-	ID        int64     `db:"id" json:"id"`
-	UserID    int64     `db:"user_id" json:"userId"`
-	AnimeID   int64     `db:"anime_id" json:"animeId"`
-	Score     int       `db:"score" json:"score"`
-	Comment   *string   `db:"comment" json:"comment"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ID        int64      `db:"id" json:"id"`
+	UserID    int64      `db:"user_id" json:"userId"`
+	AnimeID   int64      `db:"anime_id" json:"animeId"`
+	Score     int        `db:"score" json:"score"`
+	Comment   *string    `db:"comment" json:"comment"`
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updatedAt"` // 一度も編集されていない場合はnull
+	// 「参考になった」の数（アニメ別レビュー一覧でのみ集計する）
+	HelpfulCount int `db:"helpful_count" json:"helpfulCount"`
+	// 投稿者情報（users をJOINした一覧でのみセットする）
+	Author *ReviewAuthor `db:"author" json:"author,omitempty"`
+}
+
+// ReviewAuthor はレビュー投稿者の公開プロフィール
+// email や password_hash を含めないよう、models.User とは別の構造体にしている
+// sqlxでは列名を "author.id" のようにドット区切りにすると、この構造体にマッピングされる
+type ReviewAuthor struct {
+	ID          int64     `db:"id" json:"id"`
+	Username    string    `db:"username" json:"username"`
+	ReviewCount int       `db:"review_count" json:"reviewCount"`
+	JoinedAt    time.Time `db:"joined_at" json:"joinedAt"`
+}
+
+// ReviewRevision はレビューが編集される前のスコアとコメントを保持するモデル。
+// 編集のたびに1件ずつ追加され、スコアの推移を追えるようにする
+type ReviewRevision struct {
+	ID         int64     `db:"id" json:"id"`
+	ReviewID   int64     `db:"review_id" json:"reviewId"`
+	Score      int       `db:"score" json:"score"`
+	Comment    *string   `db:"comment" json:"comment"`
+	WrittenAt  time.Time `db:"written_at" json:"writtenAt"`   // この内容が書かれた日時
+	ReplacedAt time.Time `db:"replaced_at" json:"replacedAt"` // 編集によって置き換えられた日時
 }
 
 // ReviewInput はレビュー投稿時の入力データ
+// required は0を未入力として弾いてしまうため、Score はポインタにして0点の投稿も受け付ける
 type ReviewInput struct {
 	AnnictID int     `json:"annictId" binding:"required"` // Annict APIのアニメID
-	Score    int     `json:"score" binding:"required,min=0,max=100"`
+	Score    *int    `json:"score" binding:"required,min=0,max=100"`
 	Comment  *string `json:"comment"`
 }
 
+// ReviewUpdateInput はレビュー編集時の入力データ
+// 対象のアニメはURLのレビューIDで決まるので、スコアとコメントのみ受け取る
+// required は0を未入力として弾いてしまうため、Score はポインタにして0点への編集も受け付ける
+type ReviewUpdateInput struct {
+	Score   *int    `json:"score" binding:"required,min=0,max=100"`
+	Comment *string `json:"comment"`
+}
+
+// レビュー一覧の並び順
+const (
+	ReviewSortNewest    = "newest"     // 新着順（デフォルト）
+	ReviewSortOldest    = "oldest"     // 古い順
+	ReviewSortScoreDesc = "score_desc" // スコアが高い順
+	ReviewSortScoreAsc  = "score_asc"  // スコアが低い順
+	ReviewSortHelpful   = "helpful"    // 「参考になった」が多い順
+)
+
+// ReviewListQuery はアニメ別レビュー一覧取得時のクエリパラメータ
+// 例: /api/reviews?anime_id=1&sort=score_desc&limit=20&cursor=xxx&has_comment=true&min_score=80
+type ReviewListQuery struct {
+	AnimeID    int64  `form:"anime_id" binding:"required"`
+	Sort       string `form:"sort"`
+	Limit      int    `form:"limit"`
+	Cursor     string `form:"cursor"`
+	HasComment bool   `form:"has_comment"`
+	MinScore   *int   `form:"min_score"`
+	MaxScore   *int   `form:"max_score"`
+}
+
+// ReviewListOptions はアニメ別レビュー一覧の取得条件（Repositoryに渡す形）
+// カーソルは前ページ最後のレビューの並び替えキーとIDで、初回はnil
+type ReviewListOptions struct {
+	Sort       string
+	Limit      int
+	Cursor     *ReviewCursor
+	HasComment bool // trueならコメント付きのレビューのみ
+	MinScore   *int
+	MaxScore   *int
+}
+
+// ReviewCursor はキーセットページネーション用のカーソル
+// Value は並び順に応じて作成日時(RFC3339)・スコア・参考になった数のいずれかを文字列で持つ
+type ReviewCursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
 // ReviewWithAnime はレビュー情報とアニメ情報を組み合わせた構造体
 type ReviewWithAnime struct {
-	ID        int64     `db:"id" json:"id"`
-	UserID    int64     `db:"user_id" json:"userId"`
-	AnimeID   int64     `db:"anime_id" json:"animeId"`
-	Score     int       `db:"score" json:"score"`
-	Comment   *string   `db:"comment" json:"comment"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ID        int64      `db:"id" json:"id"`
+	UserID    int64      `db:"user_id" json:"userId"`
+	AnimeID   int64      `db:"anime_id" json:"animeId"`
+	Score     int        `db:"score" json:"score"`
+	Comment   *string    `db:"comment" json:"comment"`
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updatedAt"`
+	// 投稿者情報（新着レビュー一覧でのみセットする）
+	Author *ReviewAuthor `db:"author" json:"author,omitempty"`
 	// アニメ情報
 	AnimeAnnictID int64   `db:"anime_annict_id" json:"animeAnnictId"`
 	Animetitle    string  `db:"anime_title" json:"animeTitle"`
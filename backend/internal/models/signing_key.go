@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// JWTの署名アルゴリズム
+const (
+	SigningAlgorithmHS256 = "HS256" // 共通鍵（JWT_SECRET_KEY）。検証する側にも秘密鍵を渡す必要がある
+	SigningAlgorithmRS256 = "RS256" // RSA
+	SigningAlgorithmEdDSA = "EdDSA" // Ed25519
+)
+
+// SigningKeyConfig はJWTの署名鍵の設定
+type SigningKeyConfig struct {
+	Algorithm        string        // 署名アルゴリズム（HS256 / RS256 / EdDSA）
+	Secret           string        // HS256 の共通鍵（HS256 以外では、切り替え前に発行したトークンの検証だけに使う）
+	EncryptionKey    string        // DBに保存する秘密鍵を暗号化する鍵（KEK, base64 の32バイト。RS256 / EdDSA では必須）
+	RotationInterval time.Duration // 署名鍵を新しくする間隔（0で自動では新しくしない）
+	CheckInterval    time.Duration // 鍵の交換が必要か確認し、ほかのプロセスが作った鍵を読み込む間隔
+	VerifyGrace      time.Duration // 交換した古い鍵を検証用に残しておく期間（アクセストークンの有効期限以上にする）
+}
+
+// SigningKey 構造体: DBのsigning_keysテーブルに対応
+// 非対称鍵（RS256 / EdDSA）の秘密鍵を PKCS#8 の PEM にし、EncryptionKey で暗号化して保存する
+type SigningKey struct {
+	KID        string     `db:"kid"`
+	Algorithm  string     `db:"algorithm"`
+	PrivateKey string     `db:"private_key"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RetiredAt  *time.Time `db:"retired_at"` // 署名に使わなくなった日時（nilなら現在の署名鍵）
+	ExpiresAt  *time.Time `db:"expires_at"` // 検証にも使わなくなる日時
+}
+
+// JWK は公開鍵を JSON Web Key の形式で表したもの
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS は /.well-known/jwks.json で公開する公開鍵の一覧
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TokenConfig はアクセストークン・リフレッシュトークンの有効期限の設定
+type TokenConfig struct {
+	AccessTTL  time.Duration // アクセストークン（JWT）の有効期限。短くして盗まれたときの被害を抑える
+	RefreshTTL time.Duration // リフレッシュトークンの有効期限。この期間はログインし直さずにアクセストークンを再発行できる
+}
+
+// AuthTokens はログイン・ユーザー登録・トークン再発行で発行するトークンの組
+// 交換直後の同時リクエストへの再発行では、アクセストークンだけを発行する（RefreshToken は空）
+type AuthTokens struct {
+	AccessToken           string    `json:"token"`
+	AccessTokenExpiresAt  time.Time `json:"expiresAt"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// RefreshToken 構造体: DBのrefresh_tokensテーブルに対応
+// トークン自体は保存せず、SHA-256のハッシュだけを保存する
+// 使うたびに新しいトークンに交換（ローテーション）し、同じログインから続くトークンは FamilyID でまとめる
+type RefreshToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	FamilyID  string     `db:"family_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	RotatedAt *time.Time `db:"rotated_at"` // 新しいトークンに交換済みならその日時
+	// 交換済みのトークンで猶予時間内にアクセストークンを発行し直した日時（発行し直せるのは1回だけ）
+	ReissuedAt *time.Time `db:"reissued_at"`
+	RevokedAt  *time.Time `db:"revoked_at"` // 失効させた日時
+}
+
+// RefreshTokenInput: トークン再発行・ログアウト時に送られてくるリフレッシュトークン
+// （Cookie で送られてくる場合は空でよい）
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -6,11 +6,15 @@ import (
 
 // User 構造体: DBのusersテーブルに対応
 type User struct {
-	ID           int       `db:"id" json:"id"`
-	Username     string    `db:"username" json:"username"`
-	Email        string    `db:"email" json:"email"`
-	PasswordHash string    `db:"password_hash" json:"-"` // JSONには出力しない設定
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	ID                      int        `db:"id" json:"id"`
+	Username                string     `db:"username" json:"username"`
+	Email                   string     `db:"email" json:"email"`
+	PasswordHash            string     `db:"password_hash" json:"-"` // JSONには出力しない設定
+	CreatedAt               time.Time  `db:"created_at" json:"created_at"`
+	EmailVerifiedAt         *time.Time `db:"email_verified_at" json:"email_verified_at"` // メールアドレスを確認した日時（未確認ならnull）
+	EmailVerificationSentAt *time.Time `db:"email_verification_sent_at" json:"-"`        // 最後に確認メールを送った日時
+	PasswordResetSentAt     *time.Time `db:"password_reset_sent_at" json:"-"`            // 最後にパスワード再設定メールを送った日時
+	IsAdmin                 bool       `db:"is_admin" json:"-"`                          // 管理者（ログインの試行履歴を閲覧できる）
 }
 
 // ValidateUsername: ユーザー名が有効かチェック（文字数のみ）
@@ -0,0 +1,368 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AniList GraphQL API のエンドポイント（認証なしで利用できる。設定で変更しなかった場合に使う）
+const defaultAniListEndpoint = "https://graphql.anilist.co"
+
+// AniList API の呼び出しで発生するエラー
+var (
+	// サーキットブレーカーが開いている・レート制限中で問い合わせを止めている（→ 503）
+	ErrAniListUnavailable = errors.New("anilist api is temporarily unavailable")
+	// 5xx・タイムアウト・4xx など、AniListからまともな応答が得られなかった（→ 502）
+	ErrAniListRequestFailed = errors.New("anilist api request failed")
+	// 指定したIDの作品がAniListに存在しない（→ 404）
+	ErrAniListNotFound = errors.New("anime not found on anilist")
+)
+
+// aniListPerPage は ID指定で作品を取得するときの1ページの件数（AniListの上限）
+const aniListPerPage = 50
+
+// AniListRepository は AniList API と通信するためのリポジトリ（MetadataProvider の実装）
+// Annictの作品とAniListの作品を対応付けるのに使う
+// Annictと同じく、5xx・タイムアウト・429 は再試行し、失敗が続いたらサーキットブレーカーを開く
+type AniListRepository struct {
+	endpoint string
+	client   *http.Client
+	retrier  *graphQLRetrier // 再試行とサーキットブレーカー
+}
+
+// NewAniListRepository はリポジトリのインスタンスを作成
+// config で再試行とサーキットブレーカーの設定を指定する（Annict とは別に数える）
+func NewAniListRepository(config models.AniListClientConfig) *AniListRepository {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAniListEndpoint
+	}
+
+	return &AniListRepository{
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout: config.Timeout,
+		},
+		retrier: newGraphQLRetrier(config.RetryConfig),
+	}
+}
+
+// aniListError は AniList API の呼び出しに失敗したときのエラー
+type aniListError struct {
+	kind       error // ErrAniListXxx のどれか
+	statusCode int   // AniListのHTTPステータスコード（応答がなければ0）
+	err        error
+	retryAfter time.Duration
+	retryable  bool // 再試行すれば成功する可能性があるか（5xx・タイムアウト・429）
+	// AniList自体が応答しなかったか（サーキットブレーカーの失敗として数えるか）
+	unhealthy bool
+}
+
+func (e *aniListError) Error() string {
+	msg := e.kind.Error()
+	if e.statusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.statusCode)
+	}
+	if e.err != nil {
+		msg += ": " + e.err.Error()
+	}
+	return msg
+}
+
+func (e *aniListError) Unwrap() []error {
+	if e.err == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.err}
+}
+
+func (e *aniListError) shouldRetry() bool        { return e.retryable }
+func (e *aniListError) retryWait() time.Duration { return e.retryAfter }
+func (e *aniListError) upstreamFailure() bool    { return e.unhealthy }
+
+// aniListMediaFragment は作品情報として取得する項目（aniListMedia に対応）
+const aniListMediaFragment = `
+	fragment MediaFields on Media {
+		id
+		title {
+			romaji
+			english
+			native
+		}
+		season
+		seasonYear
+		format
+		episodes
+		coverImage {
+			large
+		}
+		externalLinks {
+			site
+			url
+		}
+	}
+`
+
+// aniListMedia は AniList の作品（Media）
+type aniListMedia struct {
+	ID    int `json:"id"`
+	Title struct {
+		Romaji  string `json:"romaji"`
+		English string `json:"english"`
+		Native  string `json:"native"`
+	} `json:"title"`
+	Season     *string `json:"season"` // WINTER / SPRING / SUMMER / FALL
+	SeasonYear *int    `json:"seasonYear"`
+	Format     string  `json:"format"` // TV / TV_SHORT / MOVIE / SPECIAL / OVA / ONA / MUSIC
+	Episodes   *int    `json:"episodes"`
+	CoverImage struct {
+		Large string `json:"large"`
+	} `json:"coverImage"`
+	ExternalLinks []struct {
+		Site string `json:"site"`
+		URL  string `json:"url"`
+	} `json:"externalLinks"`
+}
+
+// aniListPageResponse は Page で作品一覧を取得したときのレスポンス
+type aniListPageResponse struct {
+	Data struct {
+		Page struct {
+			PageInfo struct {
+				HasNextPage bool `json:"hasNextPage"`
+				CurrentPage int  `json:"currentPage"`
+			} `json:"pageInfo"`
+			Media []aniListMedia `json:"media"`
+		} `json:"Page"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Name は取得元の名前を返す
+func (r *AniListRepository) Name() string {
+	return models.MetadataProviderAniList
+}
+
+// SearchWorks はタイトル（ローマ字・英語・日本語のいずれでもよい）で作品を検索する
+// AniListはカーソルではなくページ番号でページングするので、カーソルにはページ番号を文字列で入れる
+func (r *AniListRepository) SearchWorks(keyword string, limit int, afterCursor string) ([]models.MetadataWork, string, error) {
+	page := 1
+	if afterCursor != "" {
+		p, err := strconv.Atoi(afterCursor)
+		if err != nil || p < 1 {
+			return nil, "", fmt.Errorf("invalid anilist cursor: %q", afterCursor)
+		}
+		page = p
+	}
+
+	query := `
+		query SearchMedia($search: String!, $page: Int!, $perPage: Int!) {
+			Page(page: $page, perPage: $perPage) {
+				pageInfo {
+					hasNextPage
+					currentPage
+				}
+				media(search: $search, type: ANIME, sort: SEARCH_MATCH) {
+					...MediaFields
+				}
+			}
+		}
+	` + aniListMediaFragment
+
+	variables := map[string]interface{}{
+		"search":  keyword,
+		"page":    page,
+		"perPage": limit,
+	}
+
+	var resp aniListPageResponse
+	if err := r.execute(query, variables, &resp); err != nil {
+		return nil, "", err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, "", &aniListError{kind: ErrAniListRequestFailed, err: fmt.Errorf("graphql error: %s", resp.Errors[0].Message)}
+	}
+
+	nextCursor := ""
+	if resp.Data.Page.PageInfo.HasNextPage {
+		nextCursor = strconv.Itoa(resp.Data.Page.PageInfo.CurrentPage + 1)
+	}
+
+	return metadataFromAniListMedia(resp.Data.Page.Media), nextCursor, nil
+}
+
+// GetWorkByID はAniListの作品IDを指定して作品を取得する
+func (r *AniListRepository) GetWorkByID(id int) (*models.MetadataWork, error) {
+	works, err := r.GetWorksByIDs([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(works) == 0 {
+		return nil, fmt.Errorf("%w (anilistId: %d)", ErrAniListNotFound, id)
+	}
+	return &works[0], nil
+}
+
+// GetWorksByIDs は複数のAniListの作品IDの作品をまとめて取得する
+func (r *AniListRepository) GetWorksByIDs(ids []int) ([]models.MetadataWork, error) {
+	query := `
+		query GetMedia($ids: [Int], $perPage: Int!) {
+			Page(page: 1, perPage: $perPage) {
+				media(id_in: $ids, type: ANIME) {
+					...MediaFields
+				}
+			}
+		}
+	` + aniListMediaFragment
+
+	works := []models.MetadataWork{}
+	for start := 0; start < len(ids); start += aniListPerPage {
+		end := min(start+aniListPerPage, len(ids))
+
+		variables := map[string]interface{}{
+			"ids":     ids[start:end],
+			"perPage": aniListPerPage,
+		}
+
+		var resp aniListPageResponse
+		if err := r.execute(query, variables, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Errors) > 0 {
+			return nil, &aniListError{kind: ErrAniListRequestFailed, err: fmt.Errorf("graphql error: %s", resp.Errors[0].Message)}
+		}
+
+		works = append(works, metadataFromAniListMedia(resp.Data.Page.Media)...)
+	}
+
+	return works, nil
+}
+
+// execute は GraphQL クエリを AniList API に送信し、レスポンスを out にデコードする
+// 5xx・タイムアウト・429 は待ち時間をランダムにずらしながら再試行し、
+// それでも失敗が続く場合はサーキットブレーカーを開いて、しばらくはAniListに問い合わせずに失敗する
+func (r *AniListRepository) execute(query string, variables map[string]interface{}, out interface{}) error {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	return r.retrier.do(func() error {
+		if err := r.send(requestBody, out); err != nil {
+			return err
+		}
+		return nil
+	}, func(wait time.Duration) error {
+		return &aniListError{kind: ErrAniListUnavailable, retryAfter: wait}
+	})
+}
+
+// send はリクエストを1回だけ送信し、レスポンスを out にデコードする
+func (r *AniListRepository) send(requestBody []byte, out interface{}) *aniListError {
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return &aniListError{kind: ErrAniListRequestFailed, err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return &aniListError{kind: ErrAniListRequestFailed, err: fmt.Errorf("failed to send request: %w", err), retryable: true, unhealthy: true}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &aniListError{
+			kind:       ErrAniListUnavailable,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			retryable:  true,
+		}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &aniListError{kind: ErrAniListRequestFailed, statusCode: resp.StatusCode, retryable: true, unhealthy: true}
+	case resp.StatusCode != http.StatusOK:
+		return &aniListError{kind: ErrAniListRequestFailed, statusCode: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &aniListError{kind: ErrAniListRequestFailed, err: fmt.Errorf("failed to decode response: %w", err), unhealthy: true}
+	}
+
+	return nil
+}
+
+// aniListMediaFormats は AniList の format を Annict の media の値にそろえる対応表
+var aniListMediaFormats = map[string]string{
+	"TV":       "TV",
+	"TV_SHORT": "TV",
+	"MOVIE":    "MOVIE",
+	"OVA":      "OVA",
+	"ONA":      "WEB",
+}
+
+// metadataFromAniListMedia はAniListの作品情報を共通の作品情報に変換する
+// 季節の FALL は AUTUMN に、format は Annict の media の値にそろえる
+func metadataFromAniListMedia(media []aniListMedia) []models.MetadataWork {
+	works := make([]models.MetadataWork, 0, len(media))
+	for _, m := range media {
+		work := models.MetadataWork{
+			Provider:   models.MetadataProviderAniList,
+			ExternalID: m.ID,
+			Title:      m.Title.Native,
+			TitleEn:    m.Title.English,
+			SeasonYear: m.SeasonYear,
+			ImageURL:   m.CoverImage.Large,
+		}
+		// 日本語タイトルがない作品はローマ字タイトルを使う
+		if work.Title == "" {
+			work.Title = m.Title.Romaji
+		}
+		if work.TitleEn == "" {
+			work.TitleEn = m.Title.Romaji
+		}
+
+		if m.Season != nil {
+			season := *m.Season
+			if season == "FALL" {
+				season = "AUTUMN"
+			}
+			work.SeasonName = &season
+		}
+
+		work.Media = "OTHER"
+		if format, ok := aniListMediaFormats[m.Format]; ok {
+			work.Media = format
+		}
+		if m.Episodes != nil {
+			work.EpisodesCount = *m.Episodes
+		}
+
+		for _, link := range m.ExternalLinks {
+			switch link.Site {
+			case "Official Site":
+				work.OfficialSiteURL = link.URL
+			case "Twitter", "X":
+				// https://twitter.com/xxx のようなURLなので、最後の部分をユーザー名にする
+				url := strings.TrimRight(link.URL, "/")
+				work.TwitterUsername = url[strings.LastIndex(url, "/")+1:]
+			}
+		}
+
+		works = append(works, work)
+	}
+	return works
+}
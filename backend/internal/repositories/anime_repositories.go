@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -18,32 +20,144 @@ func NewAnimeRepository(db *sqlx.DB) *AnimeRepository {
 	return &AnimeRepository{db: db}
 }
 
-// Create はアニメ情報をDBに保存し、生成されたIDを返する
-// ON CONFLICT (annict_id) DO NOTHING を使うことで、
-// 万が一同時に同じアニメが保存されようとしてもエラーにならず、既存のIDを返すようにすると堅牢
-func (r *AnimeRepository) Create(anime *models.Anime) error {
-	// 重複チェックも兼ねたINSERT（Upsert的な挙動）
-	// annict_idが既に存在する場合はtitleを更新するだけにする
-	// IDを返すために RETURNING id を使用
+// animeColumns は animes テーブルを a としてSELECTするときの列
+// models.Anime の db タグと対応しているので、sqlx の Get / Select でそのままマッピングできる
+const animeColumns = `
+	a.id, a.annict_id, a.title, a.title_kana, a.title_en, a.year, a.season, a.media,
+	a.episodes_count, a.image_url, a.official_site_url, a.twitter_username, a.wikipedia_url,
+	a.created_at, a.updated_at, a.synced_at`
+
+// Create は取得元（provider）の作品（externalID）をDBに保存し、生成されたIDをセットする
+// 作品は anime_external_ids の (provider, external_id) で識別し、既に保存済みなら取得元の項目をすべて最新の内容に更新する
+// 既に保存済みのアニメを取得元の最新情報で更新するときにも使う
+// 同時に同じ作品が保存されようとしても重複しないように、取得元の作品IDごとのアドバイザリーロックの中で保存する
+func (r *AnimeRepository) Create(anime *models.Anime, provider string, externalID int) error {
+	return r.WithExternalIDLock(provider, externalID, func(tx *AnimeTx) error {
+		return tx.Create(anime, provider, externalID)
+	})
+}
+
+// createAnime は Create の本体。ロックを取ったトランザクション上で呼ぶこと
+func createAnime(q sqlx.Ext, anime *models.Anime, provider string, externalID int) error {
+	// 取得元の作品IDに対応付け済みのアニメがあれば更新し、なければ追加する
+	// annict_id は Annict の作品のときだけ値が入るので、NULLなら以前の値を残す
+	// seasonだけは取得元で未設定になっても、以前の値を残す
+	// updated_at は取得元の項目のどれかが変わったときだけ、synced_at は再取得のたびに現在時刻にする
+	// 更新・追加した行のIDを返すために RETURNING id を使用
 	query := `
-		INSERT INTO animes (annict_id, title, year, image_url)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (annict_id) DO UPDATE 
-		SET title = EXCLUDED.title -- 既に存在する場合は情報を更新（念のため）
-		RETURNING id
+		WITH linked AS (
+			SELECT anime_id FROM anime_external_ids WHERE provider = $13 AND external_id = $14
+		),
+		updated AS (
+			UPDATE animes
+			SET annict_id = COALESCE($1::int, animes.annict_id),
+			    title = $2,
+			    title_kana = $3,
+			    title_en = $4,
+			    year = $5,
+			    season = COALESCE($6, animes.season),
+			    media = $7,
+			    episodes_count = $8,
+			    image_url = $9,
+			    official_site_url = $10,
+			    twitter_username = $11,
+			    wikipedia_url = $12,
+			    updated_at = CASE
+			        WHEN (animes.annict_id, animes.title, animes.title_kana, animes.title_en, animes.year, animes.season,
+			              animes.media, animes.episodes_count, animes.image_url, animes.official_site_url,
+			              animes.twitter_username, animes.wikipedia_url)
+			             IS DISTINCT FROM
+			             (COALESCE($1::int, animes.annict_id), $2, $3, $4, $5::int, COALESCE($6, animes.season),
+			              $7, $8::int, $9, $10, $11, $12)
+			        THEN CURRENT_TIMESTAMP
+			        ELSE animes.updated_at
+			    END,
+			    synced_at = CURRENT_TIMESTAMP
+			WHERE id IN (SELECT anime_id FROM linked)
+			RETURNING id, created_at, updated_at, synced_at
+		),
+		inserted AS (
+			INSERT INTO animes (
+				annict_id, title, title_kana, title_en, year, season, media, episodes_count,
+				image_url, official_site_url, twitter_username, wikipedia_url
+			)
+			SELECT $1::int, $2, $3, $4, $5::int, $6, $7, $8::int, $9, $10, $11, $12
+			WHERE NOT EXISTS (SELECT 1 FROM linked)
+			RETURNING id, created_at, updated_at, synced_at
+		)
+		SELECT id, created_at, updated_at, synced_at FROM updated
+		UNION ALL
+		SELECT id, created_at, updated_at, synced_at FROM inserted
 	`
 
-	// QueryRowを使って、INSERTされた（または更新された）行のIDを取得
-	err := r.db.QueryRow(
+	// QueryRowxを使って、追加された（または更新された）行のIDを取得
+	err := q.QueryRowx(
 		query,
 		anime.AnnictID,
 		anime.Title,
+		anime.TitleKana,
+		anime.TitleEn,
 		anime.Year,
+		anime.Season,
+		anime.Media,
+		anime.EpisodesCount,
 		anime.ImageURL,
-	).Scan(&anime.ID)
+		anime.OfficialSiteURL,
+		anime.TwitterUsername,
+		anime.WikipediaURL,
+		provider,
+		externalID,
+	).Scan(&anime.ID, &anime.CreatedAt, &anime.UpdatedAt, &anime.SyncedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to insert anime: %w", err)
+		return fmt.Errorf("failed to save anime: %w", err)
+	}
+
+	// 取得元の作品IDを外部ID対応表に登録しておく
+	if err := saveExternalID(q, anime.ID, provider, externalID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindStale は provider から取得したアニメのうち、最後に同期してから staleBefore より時間が経ったものを古い順に最大limit件取得する
+// バックグラウンドでの再取得対象を選ぶのに使う
+// Annictの作品はAnnictから再取得するので、Annict以外の取得元ではAnnictにない作品（annict_id がNULL）だけを返す
+// 戻り値の ExternalIDs には provider での作品IDを入れる
+func (r *AnimeRepository) FindStale(provider string, staleBefore time.Time, limit int) ([]models.Anime, error) {
+	query := `
+		SELECT ` + animeColumns + `, e.external_id
+		FROM animes a
+		JOIN anime_external_ids e ON e.anime_id = a.id AND e.provider = $1
+		WHERE a.synced_at < $2 AND ($1 = $4 OR a.annict_id IS NULL)
+		ORDER BY a.synced_at ASC
+		LIMIT $3
+	`
+
+	var rows []struct {
+		models.Anime
+		ExternalID int `db:"external_id"`
+	}
+	if err := r.db.Select(&rows, query, provider, staleBefore, limit, models.MetadataProviderAnnict); err != nil {
+		return nil, fmt.Errorf("failed to find stale animes: %w", err)
+	}
+
+	animes := make([]models.Anime, len(rows))
+	for i, row := range rows {
+		animes[i] = row.Anime
+		animes[i].ExternalIDs = map[string]int{provider: row.ExternalID}
+	}
+	return animes, nil
+}
+
+// TouchSynced はアニメの同期日時だけを現在時刻にする
+// Annictからの再取得に失敗した作品が、毎回の再取得対象の先頭に残り続けないようにするために使う
+func (r *AnimeRepository) TouchSynced(id int64) error {
+	query := `UPDATE animes SET synced_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to touch synced_at: %w", err)
 	}
 
 	return nil
@@ -52,18 +166,21 @@ func (r *AnimeRepository) Create(anime *models.Anime) error {
 // FindByAnnictID はAnnictID（外部ID）を使ってDBからアニメを探す
 // レビュー投稿時に「このアニメは既にDBにあるか？」を調べるのに使う
 func (r *AnimeRepository) FindByAnnictID(annictID int) (*models.Anime, error) {
-	query := `SELECT id, annict_id, title, year, image_url, created_at 
-	          FROM animes WHERE annict_id = $1`
+	return findAnimeByExternalID(r.db, models.MetadataProviderAnnict, annictID)
+}
+
+// findAnimeByExternalID は取得元での作品IDからDBのアニメを探す（見つからない場合はnil）
+// ロックを取ったトランザクション上でも使えるように q を受け取る
+func findAnimeByExternalID(q sqlx.Queryer, provider string, externalID int) (*models.Anime, error) {
+	query := `
+		SELECT ` + animeColumns + `
+		FROM animes a
+		JOIN anime_external_ids e ON e.anime_id = a.id
+		WHERE e.provider = $1 AND e.external_id = $2
+	`
 
 	var anime models.Anime
-	err := r.db.QueryRow(query, annictID).Scan(
-		&anime.ID,
-		&anime.AnnictID,
-		&anime.Title,
-		&anime.Year,
-		&anime.ImageURL,
-		&anime.CreatedAt,
-	)
+	err := sqlx.Get(q, &anime, query, provider, externalID)
 
 	// sql.ErrNoRowsは検索結果が0件の場合の特別なエラー変数
 	// errors.Isでエラーの種類を判定している
@@ -78,6 +195,35 @@ func (r *AnimeRepository) FindByAnnictID(annictID int) (*models.Anime, error) {
 	return &anime, nil
 }
 
+// FindByAnnictIDs は複数のAnnict IDのアニメをまとめてDBから探す
+// 戻り値は Annict ID をキーにしたマップで、DBに保存されていないアニメは含まれない
+func (r *AnimeRepository) FindByAnnictIDs(annictIDs []int) (map[int]models.Anime, error) {
+	animes := map[int]models.Anime{}
+	if len(annictIDs) == 0 {
+		return animes, nil
+	}
+
+	query := `
+		SELECT ` + animeColumns + `, e.external_id
+		FROM animes a
+		JOIN anime_external_ids e ON e.anime_id = a.id
+		WHERE e.provider = $1 AND e.external_id = ANY($2)
+	`
+
+	var rows []struct {
+		models.Anime
+		ExternalID int `db:"external_id"`
+	}
+	if err := r.db.Select(&rows, query, models.MetadataProviderAnnict, annictIDs); err != nil {
+		return nil, fmt.Errorf("failed to find animes: %w", err)
+	}
+
+	for _, row := range rows {
+		animes[row.ExternalID] = row.Anime
+	}
+	return animes, nil
+}
+
 // FindByIDWithStats はIDを使ってアニメ情報とその統計情報を取得する
 // FindByIDWithStats はアニメID(内部ID)を使ってDBからアニメと統計情報を一度に取得
 func (r *AnimeRepository) FindByIDWithStats(id int64) (*models.Anime, *models.AnimeStats, error) {
@@ -85,10 +231,9 @@ func (r *AnimeRepository) FindByIDWithStats(id int64) (*models.Anime, *models.An
 	// COALESCEはリストの中から、最初に『NULLではない』値を返す関数
 	// COALESCEを使って、統計情報がNULLの場合は0を返すようにしている
 	query := `
-        SELECT 
-            a.id, a.annict_id, a.title, a.year, a.image_url, a.created_at,
+        SELECT ` + animeColumns + `,
             COALESCE(s.review_count, 0) as review_count,
-            COALESCE(s.avg_score, 0) as avg_score
+            COALESCE(s.avg_score, 0)::float8 as avg_score
         FROM animes a
         LEFT JOIN anime_stats s ON a.id = s.anime_id
         WHERE a.id = $1
@@ -96,16 +241,8 @@ func (r *AnimeRepository) FindByIDWithStats(id int64) (*models.Anime, *models.An
 
 	var a models.AnimeWithStats
 
-	err := r.db.QueryRow(query, id).Scan(
-		&a.ID,
-		&a.AnnictID,
-		&a.Title,
-		&a.Year,
-		&a.ImageURL,
-		&a.CreatedAt,
-		&a.ReviewCount,
-		&a.AvgScore,
-	)
+	// sqlxのGetは埋め込み構造体(Anime)のフィールドにもマッピングしてくれる
+	err := r.db.Get(&a, query, id)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -114,66 +251,495 @@ func (r *AnimeRepository) FindByIDWithStats(id int64) (*models.Anime, *models.An
 		return nil, nil, fmt.Errorf("failed to find anime with stats: %w", err)
 	}
 
-	return &a.Anime, &models.AnimeStats{
+	stats := &models.AnimeStats{
 		AnimeID:     a.ID,
 		ReviewCount: a.ReviewCount,
 		AvgScore:    a.AvgScore,
-	}, nil
+	}
+
+	// スコアの分布を取得
+	if err := r.fillScoreDistribution(stats); err != nil {
+		return nil, nil, err
+	}
+
+	// エピソードごとの平均点を取得（キャッシュ済みのエピソードのみ）
+	stats.Episodes, err = findEpisodeStats(r.db, a.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &a.Anime, stats, nil
+}
+
+// fillScoreDistribution はアニメのスコア分布（中央値・標準偏差・パーセンタイル順位・ヒストグラム）を集計してstatsにセットする
+func (r *AnimeRepository) fillScoreDistribution(stats *models.AnimeStats) error {
+	// ヒストグラムは常に全区間を返す（レビューがない区間は0件）
+	buckets := 100 / models.ScoreBucketWidth
+	stats.Histogram = make([]models.ScoreBucket, buckets)
+	for i := range stats.Histogram {
+		stats.Histogram[i] = models.ScoreBucket{
+			Min: i * models.ScoreBucketWidth,
+			Max: (i+1)*models.ScoreBucketWidth - 1,
+		}
+	}
+	stats.Histogram[buckets-1].Max = 100
+
+	stats.PercentileRanks = []models.ScorePercentileRank{}
+
+	// レビューがなければ集計するものはない
+	if stats.ReviewCount == 0 {
+		return nil
+	}
+
+	// percentile_cont は並べたスコアの間を線形補間してパーセンタイル値を求める集計関数
+	// WITHIN GROUP (ORDER BY score) で、どの値の順序で計算するかを指定する
+	query := `
+		SELECT
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY score) AS median,
+			COALESCE(stddev_pop(score), 0) AS stddev
+		FROM reviews
+		WHERE anime_id = $1
+	`
+
+	err := r.db.QueryRow(query, stats.AnimeID).Scan(&stats.Median, &stats.StdDev)
+	if err != nil {
+		return fmt.Errorf("failed to calculate score distribution: %w", err)
+	}
+
+	// スコアごとのパーセンタイル順位
+	// GROUP BY した後のウィンドウ関数は「スコアの種類」単位で数えてしまうので、
+	// SUM(COUNT(*)) OVER で低いスコアから累積したレビュー数を求めて計算する
+	rankQuery := `
+		SELECT
+			score,
+			COUNT(*) AS count,
+			(100.0 * (SUM(COUNT(*)) OVER (ORDER BY score) - COUNT(*) / 2.0) / SUM(COUNT(*)) OVER ())::float8 AS percentile_rank
+		FROM reviews
+		WHERE anime_id = $1
+		GROUP BY score
+		ORDER BY score
+	`
+
+	if err := r.db.Select(&stats.PercentileRanks, rankQuery, stats.AnimeID); err != nil {
+		return fmt.Errorf("failed to calculate score percentile ranks: %w", err)
+	}
+
+	// 区間ごとのレビュー数
+	// 100点だけ区間が1つ増えてしまうので LEAST で最後の区間にまとめる
+	histogramQuery := `
+		SELECT LEAST(score / $2, $3) AS bucket, COUNT(*) AS count
+		FROM reviews
+		WHERE anime_id = $1
+		GROUP BY bucket
+	`
+
+	rows, err := r.db.Query(histogramQuery, stats.AnimeID, models.ScoreBucketWidth, buckets-1)
+	if err != nil {
+		return fmt.Errorf("failed to fetch score histogram: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return fmt.Errorf("failed to scan score histogram: %w", err)
+		}
+		stats.Histogram[bucket].Count = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return nil
+}
+
+// rankingScoreExpressions は並び順ごとの重み付けスコアの計算式
+// review_count, avg_score, prior_mean, min_votes, z は FindAllWithStats のクエリ内の列を参照する
+var rankingScoreExpressions = map[string]string{
+	// 単純平均
+	models.AnimeSortAverage: `avg_score`,
+	// ベイズ平均: (v×R + m×C) / (v + m)
+	// v=レビュー数, R=作品の平均点, m=最低得票数, C=全作品のレビューの平均点
+	models.AnimeSortBayesian: `COALESCE(
+		(review_count * avg_score + min_votes * prior_mean) / NULLIF(review_count + min_votes, 0),
+		0)`,
+	// Wilsonスコア区間の下限: 平均点を「100点満点中の割合 p」とみなし、
+	// レビュー数 n で得られる信頼区間の下限を100点満点に戻す
+	models.AnimeSortWilson: `CASE WHEN review_count = 0 THEN 0 ELSE
+		100 * (
+			avg_score / 100 + z * z / (2 * review_count)
+			- z * sqrt((avg_score / 100) * (1 - avg_score / 100) / review_count
+				+ z * z / (4 * review_count * review_count))
+		) / (1 + z * z / review_count)
+	END`,
+}
+
+// buildAnimeFilter は絞り込み条件からWHERE句と引数を組み立てる
+// startはプレースホルダの開始番号（先に使われている引数の数 + 1）
+// 一覧のアニメは Annict ID で詳細ページに移動するので、Annictにない作品は含めない
+func buildAnimeFilter(filter models.AnimeListFilter, start int) (string, []any) {
+	conditions := []string{"a.annict_id IS NOT NULL"}
+	var args []any
+
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, start+len(args)-1))
+	}
+
+	if filter.Year != nil {
+		add("a.year = $%d", *filter.Year)
+	}
+	if filter.Season != nil {
+		add("a.season = $%d", *filter.Season)
+	}
+	if filter.YearFrom != nil {
+		add("a.year >= $%d", *filter.YearFrom)
+	}
+	if filter.YearTo != nil {
+		add("a.year <= $%d", *filter.YearTo)
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
 }
 
 // FindAllWithStats はアニメ一覧を統計情報付きで取得する
-// 平均点順（降順）でソートし、ページネーションに対応
-func (r *AnimeRepository) FindAllWithStats(limit, offset int) ([]models.AnimeWithStats, int, error) {
-	// 総件数を取得
+// filter で放送年・シーズンを絞り込み、ranking.Sort に応じた重み付けスコアの降順でソートし、ページネーションに対応
+func (r *AnimeRepository) FindAllWithStats(limit, offset int, ranking models.RankingOptions, filter models.AnimeListFilter) ([]models.AnimeWithStats, int, error) {
+	// 総件数を取得（絞り込み後の件数）
 	var total int
-	countQuery := `SELECT COUNT(*) FROM animes`
-	if err := r.db.QueryRow(countQuery).Scan(&total); err != nil {
+	where, filterArgs := buildAnimeFilter(filter, 1)
+	countQuery := `SELECT COUNT(*) FROM animes a ` + where
+	if err := r.db.QueryRow(countQuery, filterArgs...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("failed to count animes: %w", err)
 	}
 
-	// アニメ一覧を平均点順（降順）で取得
+	scoreExpr, ok := rankingScoreExpressions[ranking.Sort]
+	if !ok {
+		scoreExpr = rankingScoreExpressions[models.AnimeSortAverage]
+	}
+
+	// 一覧取得では $1〜$4 を使うので、絞り込み条件の引数は $5 以降になるように組み立て直す
+	where, filterArgs = buildAnimeFilter(filter, 5)
+
+	// アニメ一覧を重み付けスコア順（降順）で取得
 	// レビューがないアニメは avg_score = 0 として扱う
 	// limitは何件取得するか、offsetは何件飛ばすか
+	// 計算式のパラメータは params にまとめ、どの並び順でも同じ引数で実行できるようにしている
+	query := `
+		WITH params AS (
+			SELECT
+				$3::int AS min_votes,
+				$4::float8 AS z,
+				(SELECT COALESCE(AVG(score), 0)::float8 FROM reviews) AS prior_mean
+		),
+		ranked AS (
+			SELECT ` + animeColumns + `,
+				COALESCE(s.review_count, 0) AS review_count,
+				COALESCE(s.avg_score, 0)::float8 AS avg_score,
+				p.min_votes, p.z, p.prior_mean
+			FROM animes a
+			LEFT JOIN anime_stats s ON a.id = s.anime_id
+			CROSS JOIN params p
+			` + where + `
+		)
+		SELECT
+			id, annict_id, title, title_kana, title_en, year, season, media,
+			episodes_count, image_url, official_site_url, twitter_username, wikipedia_url,
+			created_at, updated_at, synced_at, review_count, avg_score,
+			ROUND((` + scoreExpr + `)::numeric, 2)::float8 AS weighted_score
+		FROM ranked
+		ORDER BY weighted_score DESC, review_count DESC, created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	args := append([]any{limit, offset, ranking.MinVotes, ranking.Z}, filterArgs...)
+
+	// 0件の場合も null ではなく空配列を返すように初期化しておく
+	animes := []models.AnimeWithStats{}
+	if err := r.db.Select(&animes, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch animes: %w", err)
+	}
+
+	return animes, total, nil
+}
+
+// animeSearchKeyword は検索キーワードを正規化する CTE (q)
+// q.keyword は DB関数 anime_search_text で正規化したキーワード ($1)、
+// q.pattern はそれを部分一致検索用に % や _ をエスケープした LIKE パターン
+const animeSearchKeyword = `
+	WITH q AS (
+		SELECT keyword,
+			'%' || replace(replace(replace(keyword, '\', '\\'), '%', '\%'), '_', '\_') || '%' AS pattern
+		FROM (SELECT anime_search_text($1::text) AS keyword) k
+	)`
+
+// animeSearchCondition はアニメのタイトルが検索キーワードに一致するかの条件
+// 部分一致（LIKE）か、単語単位のあいまい一致（<% は word_similarity がしきい値以上）のどちらか
+// どちらも search_text のトライグラムインデックスが使われる
+const animeSearchCondition = `(a.search_text LIKE q.pattern OR q.keyword <% a.search_text)`
+
+// Search はDBに保存済みのアニメをタイトル（読み仮名・英語タイトルを含む）で検索し、統計情報付きで返す
+// カタカナ/ひらがな、全角/半角、大文字/小文字の違いは区別しない
+// 前方一致 → 部分一致 → 似ている順、同じならレビュー数の多い順に並べる
+// 検索結果はAnnictの検索結果と同じ形で返すので、Annictにない作品は含めない
+func (r *AnimeRepository) Search(keyword string, limit, offset int) ([]models.AnimeWithStats, error) {
+	query := animeSearchKeyword + `
+		SELECT ` + animeColumns + `,
+			COALESCE(s.review_count, 0) AS review_count,
+			COALESCE(s.avg_score, 0)::float8 AS avg_score
+		FROM animes a
+		CROSS JOIN q
+		LEFT JOIN anime_stats s ON a.id = s.anime_id
+		WHERE a.annict_id IS NOT NULL AND ` + animeSearchCondition + `
+		ORDER BY
+			strpos(a.search_text, q.keyword) = 1 DESC,
+			a.search_text LIKE q.pattern DESC,
+			word_similarity(q.keyword, a.search_text) DESC,
+			review_count DESC,
+			a.id ASC
+		LIMIT $2 OFFSET $3
+	`
+
+	animes := []models.AnimeWithStats{}
+	if err := r.db.Select(&animes, query, keyword, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to search animes: %w", err)
+	}
+
+	return animes, nil
+}
+
+// FindSearchMatches は annictIDs のうち、DBに保存済みで検索キーワードにも一致するアニメのAnnict IDを返す
+// Annictの検索結果から、DBの検索結果としてすでに返した作品を除くのに使う
+func (r *AnimeRepository) FindSearchMatches(keyword string, annictIDs []int) (map[int]bool, error) {
+	matches := map[int]bool{}
+	if len(annictIDs) == 0 {
+		return matches, nil
+	}
+
+	query := animeSearchKeyword + `
+		SELECT a.annict_id
+		FROM animes a
+		CROSS JOIN q
+		WHERE a.annict_id = ANY($2) AND ` + animeSearchCondition
+
+	var ids []int
+	if err := r.db.Select(&ids, query, keyword, annictIDs); err != nil {
+		return nil, fmt.Errorf("failed to find search matches: %w", err)
+	}
+
+	for _, id := range ids {
+		matches[id] = true
+	}
+	return matches, nil
+}
+
+// FindReviewSummaries は annictIDs のアニメのレビュー数・平均点と、userID のユーザー自身のスコアをまとめて取得する
+// 戻り値は Annict ID をキーにしたマップで、DBに保存されていないアニメは含まれない
+// 未ログインの場合は userID に0を渡す（MyScore は常にnilになる）
+func (r *AnimeRepository) FindReviewSummaries(annictIDs []int, userID int64) (map[int]models.AnimeReviewSummary, error) {
+	summaries := map[int]models.AnimeReviewSummary{}
+	if len(annictIDs) == 0 {
+		return summaries, nil
+	}
+
+	// reviews は (user_id, anime_id) で一意なので、自分のレビューは高々1件
 	query := `
-		SELECT 
-			a.id, a.annict_id, a.title, a.year, a.image_url, a.created_at,
-			COALESCE(s.review_count, 0) as review_count,
-			COALESCE(s.avg_score, 0) as avg_score
+		SELECT
+			a.annict_id,
+			COALESCE(s.review_count, 0) AS review_count,
+			COALESCE(s.avg_score, 0)::float8 AS avg_score,
+			my.score AS my_score
 		FROM animes a
 		LEFT JOIN anime_stats s ON a.id = s.anime_id
-		ORDER BY avg_score DESC, review_count DESC, a.created_at DESC
-		LIMIT $1 OFFSET $2
+		LEFT JOIN reviews my ON my.anime_id = a.id AND my.user_id = $2
+		WHERE a.annict_id = ANY($1)
 	`
 
-	rows, err := r.db.Query(query, limit, offset)
+	var rows []models.AnimeReviewSummary
+	if err := r.db.Select(&rows, query, annictIDs, userID); err != nil {
+		return nil, fmt.Errorf("failed to find review summaries: %w", err)
+	}
+
+	for _, row := range rows {
+		summaries[row.AnnictID] = row
+	}
+	return summaries, nil
+}
+
+// AnimeTx は WithExternalIDLock でロックを取ったトランザクション上でアニメを読み書きする
+// ロック中のDB操作をすべてこのトランザクションで行うことで、1件の取得で使う接続を1本に抑える
+// （ロック中に別の接続を取りにいくと、接続プールの上限に達したときに互いに待ち合ってしまう）
+type AnimeTx struct {
+	tx *sqlx.Tx
+}
+
+// FindByExternalID はトランザクション上で AnimeRepository.FindByExternalID と同じことを行う
+func (t *AnimeTx) FindByExternalID(provider string, externalID int) (*models.Anime, error) {
+	return findAnimeByExternalID(t.tx, provider, externalID)
+}
+
+// Create はトランザクション上で AnimeRepository.Create と同じことを行う（ロックは WithExternalIDLock で取得済み）
+func (t *AnimeTx) Create(anime *models.Anime, provider string, externalID int) error {
+	return createAnime(t.tx, anime, provider, externalID)
+}
+
+// WithExternalIDLock は取得元の作品IDごとのアドバイザリーロックを取得してから fn を実行する
+// 複数のサーバープロセスが同じアニメを同時に保存しないようにするために使う（取得元への問い合わせはロックの外で行うこと）
+// ロックのキーは (取得元の名前のハッシュ, 作品ID) で、取得元が違えば同じIDでもぶつからない
+// ロックはトランザクション単位（pg_advisory_xact_lock）なので、fn が終わってトランザクションを閉じると自動で解放される
+// 他のプロセスがロック中の場合は、解放されるまで待つ
+// fn の中のDB操作は引数の tx を使うこと（fn が成功したらコミットする）
+func (r *AnimeRepository) WithExternalIDLock(provider string, externalID int, fn func(tx *AnimeTx) error) error {
+	tx, err := r.db.Beginx()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to fetch animes: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var animes []models.AnimeWithStats
-	// 次の行がある限りループ
-	for rows.Next() {
-		var a models.AnimeWithStats
-		err := rows.Scan(
-			&a.ID,
-			&a.AnnictID,
-			&a.Title,
-			&a.Year,
-			&a.ImageURL,
-			&a.CreatedAt,
-			&a.ReviewCount,
-			&a.AvgScore,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan anime: %w", err)
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1), $2)`, provider, externalID); err != nil {
+		return fmt.Errorf("failed to acquire anime lock: %w", err)
+	}
+
+	if err := fn(&AnimeTx{tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit anime transaction: %w", err)
+	}
+	return nil
+}
+
+// SaveExternalID はアニメと取得元での作品IDの対応を保存する
+// 同じ取得元の対応が既にあれば作品IDを更新する
+// 別のアニメが既に同じ作品IDに対応付けられている場合はエラーになる
+func (r *AnimeRepository) SaveExternalID(animeID int64, provider string, externalID int) error {
+	return saveExternalID(r.db, animeID, provider, externalID)
+}
+
+// saveExternalID は SaveExternalID の本体。トランザクション上でも使えるように q を受け取る
+// Annictの作品IDは animes.annict_id にも写しておく（AniListにしかなかった作品がAnnictと対応付いたときなど）
+func saveExternalID(q sqlx.Execer, animeID int64, provider string, externalID int) error {
+	query := `
+		INSERT INTO anime_external_ids (anime_id, provider, external_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (anime_id, provider) DO UPDATE
+		SET external_id = EXCLUDED.external_id
+	`
+
+	if _, err := q.Exec(query, animeID, provider, externalID); err != nil {
+		return fmt.Errorf("failed to save external id: %w", err)
+	}
+
+	if provider == models.MetadataProviderAnnict {
+		query := `UPDATE animes SET annict_id = $2 WHERE id = $1 AND annict_id IS DISTINCT FROM $2`
+		if _, err := q.Exec(query, animeID, externalID); err != nil {
+			return fmt.Errorf("failed to save annict id: %w", err)
 		}
-		animes = append(animes, a)
 	}
+	return nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
+// ErrAnimeMergeConflict は2件のアニメが同じ取得元の別の作品に対応付いていて、同じ作品としてまとめられない場合のエラー
+var ErrAnimeMergeConflict = errors.New("animes are linked to different works of the same provider")
+
+// MergeAnimes は同じ作品が別々のアニメとして保存されていた2件（a, b）を1件にまとめ、残したアニメのIDを返す
+// AniListから先に保存した作品（annict_id がNULL）を、後からAnnictのIDで取得した場合などに起きる
+// Annictの作品IDがある方を残し（どちらにもなければIDの小さい方）、もう一方の外部ID・エピソード・レビュー・視聴ステータスを移して削除する
+// 同じユーザーのレビュー・視聴ステータスが両方にある場合は、残す方のアニメのものを優先する
+// 両方が同じ取得元の別の作品に対応付いている場合は、何もせずに ErrAnimeMergeConflict を返す
+func (r *AnimeRepository) MergeAnimes(a, b int64) (int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return animes, total, nil
+	// 1. 2件の外部IDごとのアドバイザリーロックを取り、まとめている間に取得元から保存されないようにする
+	// （ほかのまとめる処理と待ち合わないように、ロックは取得元・作品IDの順に取る）
+	var externalIDs []models.AnimeExternalID
+	query := `
+		SELECT anime_id, provider, external_id
+		FROM anime_external_ids
+		WHERE anime_id IN ($1, $2)
+		ORDER BY provider, external_id
+	`
+	if err := tx.Select(&externalIDs, query, a, b); err != nil {
+		return 0, fmt.Errorf("failed to find external ids: %w", err)
+	}
+	owners := make(map[string]int64, len(externalIDs))
+	for _, id := range externalIDs {
+		if owner, ok := owners[id.Provider]; ok && owner != id.AnimeID {
+			return 0, ErrAnimeMergeConflict
+		}
+		owners[id.Provider] = id.AnimeID
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1), $2)`, id.Provider, id.ExternalID); err != nil {
+			return 0, fmt.Errorf("failed to acquire anime lock: %w", err)
+		}
+	}
+
+	// 2. 残すアニメを決める（Annictの作品IDがある方、どちらにもなければIDの小さい方）
+	var animes []struct {
+		ID       int64  `db:"id"`
+		AnnictID *int64 `db:"annict_id"`
+	}
+	query = `SELECT id, annict_id FROM animes WHERE id IN ($1, $2) ORDER BY annict_id IS NULL, id FOR UPDATE`
+	if err := tx.Select(&animes, query, a, b); err != nil {
+		return 0, fmt.Errorf("failed to find animes: %w", err)
+	}
+	if len(animes) != 2 {
+		return 0, fmt.Errorf("failed to merge animes: anime not found (ids: %d, %d)", a, b)
+	}
+	if animes[1].AnnictID != nil {
+		return 0, ErrAnimeMergeConflict
+	}
+	keep, drop := animes[0].ID, animes[1].ID
+
+	// 3. 削除するアニメに付いていたものを、残すアニメに移す（移せなかったものは削除と一緒に消える）
+	queries := []string{
+		`UPDATE anime_external_ids SET anime_id = $1 WHERE anime_id = $2`,
+		`UPDATE episodes SET anime_id = $1 WHERE anime_id = $2`,
+		`UPDATE reviews SET anime_id = $1
+		 WHERE anime_id = $2 AND user_id NOT IN (SELECT user_id FROM reviews WHERE anime_id = $1)`,
+		`UPDATE user_anime_status SET anime_id = $1
+		 WHERE anime_id = $2 AND user_id NOT IN (SELECT user_id FROM user_anime_status WHERE anime_id = $1)`,
+		`DELETE FROM animes WHERE id = $2`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, keep, drop); err != nil {
+			return 0, fmt.Errorf("failed to merge animes: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return keep, nil
+}
+
+// FindExternalIDs はアニメの取得元ごとの作品IDを取得する（キーは取得元の名前）
+func (r *AnimeRepository) FindExternalIDs(animeID int64) (map[string]int, error) {
+	query := `
+		SELECT anime_id, provider, external_id
+		FROM anime_external_ids
+		WHERE anime_id = $1
+	`
+
+	var rows []models.AnimeExternalID
+	if err := r.db.Select(&rows, query, animeID); err != nil {
+		return nil, fmt.Errorf("failed to find external ids: %w", err)
+	}
+
+	externalIDs := make(map[string]int, len(rows))
+	for _, row := range rows {
+		externalIDs[row.Provider] = row.ExternalID
+	}
+	return externalIDs, nil
+}
+
+// FindByExternalID は取得元での作品IDからDBのアニメを探す
+// 見つからない場合は nil を返す
+func (r *AnimeRepository) FindByExternalID(provider string, externalID int) (*models.Anime, error) {
+	return findAnimeByExternalID(r.db, provider, externalID)
 }
@@ -1,40 +1,213 @@
 package repositories
 
 import (
+	"anime-score-backend/internal/cache"
 	"anime-score-backend/internal/models"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
-// Annict GraphQL API のエンドポイント
-const annictEndpoint = "https://api.annict.com/graphql"
+// Annict GraphQL API のエンドポイント（設定で変更しなかった場合に使う）
+const defaultAnnictEndpoint = "https://api.annict.com/graphql"
+
+// Annict API の呼び出しで発生するエラーの種類
+// 実際には *AnnictError に包まれて返るので、errors.Is で種類を判別する
+var (
+	// サーキットブレーカーが開いていて問い合わせを止めている（→ 503）
+	ErrAnnictUnavailable = errors.New("annict api is temporarily unavailable")
+	// Annictのレート制限にかかっている（→ 503）
+	ErrAnnictRateLimited = errors.New("annict api rate limit exceeded")
+	// 5xx・タイムアウト・不正なレスポンスなど、Annictからまともな応答が得られなかった（→ 502）
+	ErrAnnictBadGateway = errors.New("annict api request failed")
+	// 401・403・400 など、Annictは動いているがリクエストが拒否された（→ 502）
+	// トークンの失効などこちら側の問題なので、サーキットブレーカーの失敗としては数えない
+	ErrAnnictRejected = errors.New("annict api rejected the request")
+	// 指定したIDの作品がAnnictに存在しない（→ 404）
+	ErrAnnictNotFound = errors.New("anime not found on annict")
+)
+
+// AnnictError は Annict API の呼び出しに失敗したときのエラー
+// Kind に上の ErrAnnictXxx のどれかが入る
+type AnnictError struct {
+	Kind       error         // エラーの種類
+	StatusCode int           // AnnictのHTTPステータスコード（応答がなければ0）
+	RetryAfter time.Duration // 再試行まで待つべき時間（分かる場合のみ）
+	Err        error         // 元になったエラー（あれば）
+	retryable  bool          // 再試行すれば成功する可能性があるか（5xx・タイムアウト・429）
+}
+
+func (e *AnnictError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap で種類と元のエラーの両方を返し、errors.Is(err, ErrAnnictBadGateway) のように判別できるようにする
+func (e *AnnictError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func (e *AnnictError) shouldRetry() bool        { return e.retryable }
+func (e *AnnictError) retryWait() time.Duration { return e.RetryAfter }
+
+// upstreamFailure は Annict からまともな応答が得られなかったか（429 や 4xx は Annict 自体は動いているので false）
+func (e *AnnictError) upstreamFailure() bool { return errors.Is(e.Kind, ErrAnnictBadGateway) }
+
+// graphQLError は GraphQL のエラー（errors フィールド）を AnnictError にする
+func graphQLError(message string) error {
+	return &AnnictError{Kind: ErrAnnictBadGateway, Err: fmt.Errorf("graphql error: %s", message)}
+}
+
+// workNotFound は指定したIDの作品がなかったときのエラー
+func workNotFound(annictID int) error {
+	return &AnnictError{Kind: ErrAnnictNotFound, Err: fmt.Errorf("annictId: %d", annictID)}
+}
+
+// defaultRateLimitWait は429でRetry-Afterが返ってこなかったときに待つ時間
+const defaultRateLimitWait = 30 * time.Second
 
 // AnnictRepository は Annict API と通信するためのリポジトリ
 type AnnictRepository struct {
-	token  string       // Annict API の認証トークン
-	client *http.Client // HTTP リクエスト送信用クライアント
+	token    string       // Annict API の認証トークン
+	endpoint string       // GraphQL API のURL
+	client   *http.Client // HTTP リクエスト送信用クライアント
+	config   models.AnnictClientConfig
+	retrier  *graphQLRetrier // 再試行とサーキットブレーカー
+	// 検索結果のキャッシュ（nilならキャッシュしない）
+	searchCache cache.Cache
+
+	mu               sync.Mutex
+	rateLimitedUntil time.Time // レート制限が解除される時刻（それまでは問い合わせない）
 }
 
 // http.Client は Go の標準ライブラリで提供される HTTP クライアントで、
 // HTTP リクエストの送信とレスポンスの受信を行うための構造体。
 // NewAnnictRepository はリポジトリのインスタンスを作成
-func NewAnnictRepository(token string) *AnnictRepository {
+// config で再試行とサーキットブレーカーの設定を指定する
+// searchCache に同じ検索（キーワード・件数・カーソル）の結果を config.SearchCacheTTL の間保存する（nilならキャッシュしない）
+// config.Endpoint を指定すると、本物のAnnictの代わりにローカルの偽サーバー（cmd/fakeannict）などに問い合わせる
+func NewAnnictRepository(token string, config models.AnnictClientConfig, searchCache cache.Cache) *AnnictRepository {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAnnictEndpoint
+	}
+
 	return &AnnictRepository{
-		token: token,
+		token:    token,
+		endpoint: endpoint,
 		client: &http.Client{
-			Timeout: 10 * time.Second, // タイムアウト設定
+			Timeout: config.Timeout, // タイムアウト設定
 		},
+		config:      config,
+		retrier:     newGraphQLRetrier(config.RetryConfig),
+		searchCache: searchCache,
+	}
+}
+
+// searchCachePrefix は検索結果のキャッシュのキーの接頭辞
+const searchCachePrefix = "annict:search:"
+
+// cachedSearchResult はキャッシュに保存する検索結果
+type cachedSearchResult struct {
+	Works      []models.MetadataWork `json:"works"`
+	NextCursor string                `json:"nextCursor"`
+}
+
+// searchCacheKey は検索結果のキャッシュのキーを作る
+// キーワードを先頭に置くことで、InvalidateSearchCache でキーワードごとにまとめて削除できる
+func searchCacheKey(keyword string, limit int, afterCursor string) string {
+	return fmt.Sprintf("%s%d|%s", searchCacheKeywordPrefix(keyword), limit, afterCursor)
+}
+
+// searchCacheKeywordPrefix は keyword の検索結果のキャッシュのキーに共通する接頭辞を返す
+// キーワードに "|" が含まれていても別のキーワードのキーと前方一致しないように、キーワードの長さを前に付ける
+// （そのままだと "a" の接頭辞 "a|" が、キーワード "a|b" のキーにも一致してしまう）
+func searchCacheKeywordPrefix(keyword string) string {
+	return fmt.Sprintf("%s%d:%s|", searchCachePrefix, len(keyword), keyword)
+}
+
+// InvalidateSearchCache は keyword の検索結果のキャッシュをすべて（全ページ・全件数）削除し、削除した件数を返す
+// keyword が空文字なら、すべての検索結果のキャッシュを削除する
+// （SearchCacheInvalidator の実装。バックグラウンドの再取得で作品情報の変更に気づいたときに呼ばれる）
+func (r *AnnictRepository) InvalidateSearchCache(keyword string) int {
+	if r.searchCache == nil {
+		return 0
+	}
+	if keyword == "" {
+		return r.searchCache.DeletePrefix(searchCachePrefix)
+	}
+	return r.searchCache.DeletePrefix(searchCacheKeywordPrefix(keyword))
+}
+
+// annictWorkFragment は作品情報として取得する項目（models.AnnictWork に対応）
+// 検索・ID指定のどちらのクエリでも同じ項目を取得するので、GraphQLのフラグメントにまとめている
+const annictWorkFragment = `
+	fragment WorkFields on Work {
+		annictId
+		title
+		titleKana
+		titleEn
+		seasonYear
+		seasonName
+		media
+		episodesCount
+		officialSiteUrl
+		twitterUsername
+		wikipediaUrl
+		image {
+			recommendedImageUrl
+		}
+	}
+`
+
+// annictWorksPerRequest は ID指定で作品を取得するときに、1回のリクエストで指定するIDの数の上限
+// （searchWorks の first に指定できる最大値）
+const annictWorksPerRequest = 50
+
+// batchSize は ID指定で作品を取得するときに、1回のリクエストで指定するIDの数
+// 設定がない・上限を超えている場合は annictWorksPerRequest を使う
+func (r *AnnictRepository) batchSize() int {
+	if r.config.BatchSize <= 0 || r.config.BatchSize > annictWorksPerRequest {
+		return annictWorksPerRequest
 	}
+	return r.config.BatchSize
+}
+
+// Name は取得元の名前を返す（MetadataProvider の実装）
+func (r *AnnictRepository) Name() string {
+	return models.MetadataProviderAnnict
 }
 
 // SearchWorks はタイトルでアニメを検索し、結果のリストを返す
 // keyword: 検索キーワード
 // limit: 取得したい件数
 // afterCursor: "ここから後ろを取得したい"という場所のID（初回は空文字 "" でOK）
-func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor string) ([]models.AnnictWork, string, error) {
+func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor string) ([]models.MetadataWork, string, error) {
+	// 同じ検索をした直後ならキャッシュから返す
+	key := searchCacheKey(keyword, limit, afterCursor)
+	if r.searchCache != nil {
+		if b, ok := r.searchCache.Get(key); ok {
+			var cached cachedSearchResult
+			if err := json.Unmarshal(b, &cached); err == nil {
+				return cached.Works, cached.NextCursor, nil
+			}
+		}
+	}
+
 	// Annict API に送信する GraphQL クエリを定義
 	// 検索条件: 指定されたタイトル、件数制限、ページネーション
 	// 結果をシーズンの降順でソート
@@ -47,12 +220,7 @@ func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor st
 				orderBy: { field: SEASON, direction: DESC }
 			) {
 				nodes {
-					annictId
-					title
-					seasonYear
-					image {
-						recommendedImageUrl
-					}
+					...WorkFields
 				}
 				pageInfo {
 					hasNextPage
@@ -60,7 +228,7 @@ func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor st
 				}
 			}
 		}
-	`
+	` + annictWorkFragment
 
 	// GraphQL クエリに渡す変数をセット
 	variables := map[string]interface{}{
@@ -72,52 +240,21 @@ func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor st
 		variables["after"] = afterCursor
 	}
 
-	// GraphQLのリクエストはクエリと変数をJSON形式で送る必要がある
-	// GraphQL リクエストを JSON 形式(バイト列)にマーシャル
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"query":     query,
-		"variables": variables,
-	})
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request body: %w", err)
-	}
-
-	// HTTP POST リクエストを作成
-	// bytes.NewBuffer(requestBody)はただのバイト列であるreauestBodyをio.Readerインターフェースに変換する
-	// io.ReadrerインターフェースはReadメソッドを持つインターフェースのこと
-	// http.NewRequestの第3引数はio.Readerインターフェースを受け取るので、bytes.NewBufferで変換する必要がある
-	req, err := http.NewRequest("POST", annictEndpoint, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// リクエストヘッダーを設定（認証とコンテンツタイプ）
-	req.Header.Set("Authorization", "Bearer "+r.token)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Annict API にリクエストを送信
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// ステータスコードをチェック
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("annict api returned non-200 status: %d", resp.StatusCode)
-	}
-
-	// レスポンスボディをパース
-	// resp.Bodyはストリーム（バイト列が流れてくる状態）なので、そのまままでは使えない
-	// graphQLRespにレスポンスをマッピングしている
+	// graphQLRespにレスポンスをマッピングする
 	var graphQLResp models.AnnictGraphQLResponse
-	if err := json.NewDecoder(resp.Body).Decode(&graphQLResp); err != nil {
-		return nil, "", fmt.Errorf("failed to decode response: %w", err)
+	if err := r.execute(query, variables, &graphQLResp); err != nil {
+		// サーキットブレーカーが開いている・レート制限中の間は、期限切れでもキャッシュが残っていればそれを返す
+		if errors.Is(err, ErrAnnictUnavailable) || errors.Is(err, ErrAnnictRateLimited) {
+			if works, nextCursor, ok := r.staleSearchResult(key); ok {
+				return works, nextCursor, nil
+			}
+		}
+		return nil, "", err
 	}
 
 	// GraphQL エラーをチェック
 	if len(graphQLResp.Errors) > 0 {
-		return nil, "", fmt.Errorf("graphql error: %s", graphQLResp.Errors[0].Message)
+		return nil, "", graphQLError(graphQLResp.Errors[0].Message)
 	}
 
 	// 次ページ用のカーソルを取得
@@ -127,65 +264,314 @@ func (r *AnnictRepository) SearchWorks(keyword string, limit int, afterCursor st
 		nextCursor = graphQLResp.Data.SearchWorks.PageInfo.EndCursor
 	}
 
+	works := metadataFromAnnictWorks(graphQLResp.Data.SearchWorks.Nodes)
+
+	// 成功した検索結果だけをキャッシュする
+	if r.searchCache != nil && r.config.SearchCacheTTL > 0 {
+		if b, err := json.Marshal(cachedSearchResult{Works: works, NextCursor: nextCursor}); err == nil {
+			r.searchCache.Set(key, b, r.config.SearchCacheTTL)
+		}
+	}
+
 	// 検索結果とカーソルを返す
-	return graphQLResp.Data.SearchWorks.Nodes, nextCursor, nil
+	return works, nextCursor, nil
+}
+
+// staleSearchResult はAnnictに問い合わせられないとき、期限切れのキャッシュに同じ検索の結果が残っていればそれを返す
+func (r *AnnictRepository) staleSearchResult(key string) ([]models.MetadataWork, string, bool) {
+	if r.searchCache == nil {
+		return nil, "", false
+	}
+	b, ok := r.searchCache.GetStale(key)
+	if !ok {
+		return nil, "", false
+	}
+	var cached cachedSearchResult
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, "", false
+	}
+	return cached.Works, cached.NextCursor, true
 }
 
 // GetWorkByID はAnnict IDを指定してアニメ詳細を取得します
-func (r *AnnictRepository) GetWorkByID(annictID int) (*models.AnnictWork, error) {
+// 作品が存在しない場合は ErrAnnictNotFound を返す
+func (r *AnnictRepository) GetWorkByID(annictID int) (*models.MetadataWork, error) {
+	works, err := r.GetWorksByIDs([]int{annictID})
+	if err != nil {
+		return nil, err
+	}
+	if len(works) == 0 {
+		return nil, workNotFound(annictID)
+	}
+
+	// 最初の1件を返す
+	return &works[0], nil
+}
+
+// GetWorksByIDs は複数のAnnict IDの作品をまとめて取得する
+// IDが多い場合は BatchSize 件ずつに分けて問い合わせる（1件ずつ問い合わせるよりリクエスト数が大幅に減る）
+// Annictに存在しないIDは結果に含まれない（順番も指定したIDの順とは限らない）
+func (r *AnnictRepository) GetWorksByIDs(annictIDs []int) ([]models.MetadataWork, error) {
 	// annictIds 引数を使ってID指定で検索
 	query := `
-		query GetWork($annictId: Int!) {
+		query GetWorks($annictIds: [Int!]!, $limit: Int!) {
+			searchWorks(annictIds: $annictIds, first: $limit) {
+				nodes {
+					...WorkFields
+				}
+			}
+		}
+	` + annictWorkFragment
+
+	batchSize := r.batchSize()
+	works := []models.MetadataWork{}
+	for start := 0; start < len(annictIDs); start += batchSize {
+		end := min(start+batchSize, len(annictIDs))
+		ids := annictIDs[start:end]
+
+		variables := map[string]interface{}{
+			"annictIds": ids,
+			"limit":     len(ids),
+		}
+
+		var graphQLResp models.AnnictGraphQLResponse
+		if err := r.execute(query, variables, &graphQLResp); err != nil {
+			return nil, err
+		}
+		if len(graphQLResp.Errors) > 0 {
+			return nil, graphQLError(graphQLResp.Errors[0].Message)
+		}
+
+		works = append(works, metadataFromAnnictWorks(graphQLResp.Data.SearchWorks.Nodes)...)
+	}
+
+	return works, nil
+}
+
+// metadataFromAnnictWorks はAnnictの作品情報を共通の作品情報に変換する
+func metadataFromAnnictWorks(annictWorks []models.AnnictWork) []models.MetadataWork {
+	works := make([]models.MetadataWork, 0, len(annictWorks))
+	for _, w := range annictWorks {
+		works = append(works, models.MetadataWork{
+			Provider:        models.MetadataProviderAnnict,
+			ExternalID:      w.AnnictID,
+			Title:           w.Title,
+			TitleKana:       w.TitleKana,
+			TitleEn:         w.TitleEn,
+			SeasonYear:      w.SeasonYear,
+			SeasonName:      w.SeasonName,
+			Media:           w.Media,
+			EpisodesCount:   w.EpisodesCount,
+			ImageURL:        w.Image.RecommendedImageUrl,
+			OfficialSiteURL: w.OfficialSiteUrl,
+			TwitterUsername: w.TwitterUsername,
+			WikipediaURL:    w.WikipediaUrl,
+		})
+	}
+	return works
+}
+
+// annictEpisodesPerPage はエピソード一覧を1回のリクエストで取得する件数
+const annictEpisodesPerPage = 50
+
+// GetEpisodesByWorkID はAnnict IDを指定して作品のエピソード一覧を取得する
+// 話数の多い作品にも対応するため、pageInfo をたどって全ページ取得する
+func (r *AnnictRepository) GetEpisodesByWorkID(annictID int) ([]models.AnnictEpisode, error) {
+	query := `
+		query GetEpisodes($annictId: Int!, $limit: Int!, $after: String) {
 			searchWorks(annictIds: [$annictId], first: 1) {
 				nodes {
-					annictId
-					title
-					seasonYear
-					image {
-						recommendedImageUrl
+					episodes(
+						first: $limit,
+						after: $after,
+						orderBy: { field: SORT_NUMBER, direction: ASC }
+					) {
+						nodes {
+							annictId
+							number
+							numberText
+							sortNumber
+							title
+						}
+						pageInfo {
+							hasNextPage
+							endCursor
+						}
 					}
 				}
 			}
 		}
 	`
 
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"query": query,
-		"variables": map[string]interface{}{
+	var episodes []models.AnnictEpisode
+	after := ""
+	for {
+		variables := map[string]interface{}{
 			"annictId": annictID,
-		},
+			"limit":    annictEpisodesPerPage,
+		}
+		if after != "" {
+			variables["after"] = after
+		}
+
+		var graphQLResp models.AnnictEpisodesResponse
+		if err := r.execute(query, variables, &graphQLResp); err != nil {
+			return nil, err
+		}
+		if len(graphQLResp.Errors) > 0 {
+			return nil, graphQLError(graphQLResp.Errors[0].Message)
+		}
+
+		nodes := graphQLResp.Data.SearchWorks.Nodes
+		if len(nodes) == 0 {
+			return nil, workNotFound(annictID)
+		}
+
+		episodes = append(episodes, nodes[0].Episodes.Nodes...)
+
+		// 次のページがなければ終了
+		pageInfo := nodes[0].Episodes.PageInfo
+		if !pageInfo.HasNextPage || pageInfo.EndCursor == "" {
+			break
+		}
+		after = pageInfo.EndCursor
+	}
+
+	return episodes, nil
+}
+
+// execute は GraphQL クエリを Annict API に送信し、レスポンスを out にデコードする
+// GraphQL のエラー（errors フィールド）は呼び出し側で確認する
+// 5xx・タイムアウト・429 は待ち時間をランダムにずらしながら再試行し、
+// それでも失敗が続く場合はサーキットブレーカーを開いて、しばらくはAnnictに問い合わせずに失敗する
+func (r *AnnictRepository) execute(query string, variables map[string]interface{}, out interface{}) error {
+	// GraphQLのリクエストはクエリと変数をJSON形式で送る必要がある
+	// GraphQL リクエストを JSON 形式(バイト列)にマーシャル
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", annictEndpoint, bytes.NewBuffer(requestBody))
+	// レート制限中は解除されるまで問い合わせない
+	if wait := r.rateLimitWait(); wait > 0 {
+		return &AnnictError{Kind: ErrAnnictRateLimited, RetryAfter: wait}
+	}
+
+	// 5xx・タイムアウト・429 は再試行し、障害が続いたらサーキットブレーカーで問い合わせを止める
+	return r.retrier.do(func() error {
+		if err := r.send(requestBody, out); err != nil {
+			return err
+		}
+		return nil
+	}, func(wait time.Duration) error {
+		return &AnnictError{Kind: ErrAnnictUnavailable, RetryAfter: wait}
+	})
+}
+
+// send はリクエストを1回だけ送信し、レスポンスを out にデコードする
+func (r *AnnictRepository) send(requestBody []byte, out interface{}) *AnnictError {
+	// HTTP POST リクエストを作成
+	// bytes.NewBuffer(requestBody)はただのバイト列であるreauestBodyをio.Readerインターフェースに変換する
+	// io.ReadrerインターフェースはReadメソッドを持つインターフェースのこと
+	// http.NewRequestの第3引数はio.Readerインターフェースを受け取るので、bytes.NewBufferで変換する必要がある
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return &AnnictError{Kind: ErrAnnictBadGateway, Err: fmt.Errorf("failed to create request: %w", err)}
 	}
+
+	// リクエストヘッダーを設定（認証とコンテンツタイプ）
 	req.Header.Set("Authorization", "Bearer "+r.token)
 	req.Header.Set("Content-Type", "application/json")
 
+	// Annict API にリクエストを送信
+	// タイムアウトや接続エラーは一時的なものとして再試行する
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return &AnnictError{Kind: ErrAnnictBadGateway, Err: fmt.Errorf("failed to send request: %w", err), retryable: true}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("annict api error: %d", resp.StatusCode)
+	// 残りのリクエスト数が0になったら、解除されるまで問い合わせを止める
+	r.observeRateLimit(resp.Header)
+
+	// ステータスコードをチェック
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if wait <= 0 {
+			wait = defaultRateLimitWait
+		}
+		r.setRateLimitedUntil(time.Now().Add(wait))
+		return &AnnictError{Kind: ErrAnnictRateLimited, StatusCode: resp.StatusCode, RetryAfter: wait, retryable: true}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &AnnictError{
+			Kind:       ErrAnnictBadGateway,
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			retryable:  true,
+		}
+	case resp.StatusCode >= http.StatusBadRequest:
+		return &AnnictError{Kind: ErrAnnictRejected, StatusCode: resp.StatusCode}
+	case resp.StatusCode != http.StatusOK:
+		return &AnnictError{Kind: ErrAnnictBadGateway, StatusCode: resp.StatusCode}
 	}
 
-	var graphQLResp models.AnnictGraphQLResponse
-	if err := json.NewDecoder(resp.Body).Decode(&graphQLResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	// レスポンスボディをパース
+	// resp.Bodyはストリーム（バイト列が流れてくる状態）なので、そのまままでは使えない
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &AnnictError{Kind: ErrAnnictBadGateway, Err: fmt.Errorf("failed to decode response: %w", err)}
 	}
 
-	nodes := graphQLResp.Data.SearchWorks.Nodes
-	if len(nodes) == 0 {
-		return nil, fmt.Errorf("anime not found with annictId: %d", annictID)
+	return nil
+}
+
+// rateLimitWait はレート制限が解除されるまでの残り時間を返す（制限中でなければ0）
+func (r *AnnictRepository) rateLimitWait() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return time.Until(r.rateLimitedUntil)
+}
+
+// setRateLimitedUntil はレート制限が解除される時刻を記録する
+func (r *AnnictRepository) setRateLimitedUntil(until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until.After(r.rateLimitedUntil) {
+		r.rateLimitedUntil = until
 	}
+}
 
-	// 最初の1件を返す
-	return &nodes[0], nil
+// observeRateLimit はレート制限のヘッダー（X-RateLimit-Remaining / X-RateLimit-Reset）を確認し、
+// 残りが0ならリセットされる時刻まで問い合わせを止める
+func (r *AnnictRepository) observeRateLimit(header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+
+	// X-RateLimit-Reset はリセットされる時刻のUNIX時間（秒）
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	r.setRateLimitedUntil(time.Unix(reset, 0))
+}
+
+// parseRetryAfter は Retry-After ヘッダー（秒数またはHTTP日付）を待ち時間にする
+// 値がない・読めない場合は0を返す
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
 }
@@ -0,0 +1,240 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/cache"
+	"anime-score-backend/internal/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const searchResponse = `{"data":{"searchWorks":{"nodes":[{"annictId":1,"title":"テスト"}],"pageInfo":{"hasNextPage":false,"endCursor":""}}}}`
+
+// newTestAnnictServer は status を返す関数で応答を切り替えられる偽のAnnictサーバーを立てる
+// 200 のときは searchResponse を返す。呼ばれた回数を calls に数える
+func newTestAnnictServer(t *testing.T, calls *atomic.Int32, status func(call int32) int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := status(calls.Add(1))
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(searchResponse))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testAnnictConfig(endpoint string) models.AnnictClientConfig {
+	return models.AnnictClientConfig{
+		RetryConfig: testRetryConfig(),
+		Endpoint:    endpoint,
+	}
+}
+
+func TestAnnictRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := newTestAnnictServer(t, &calls, func(call int32) int {
+		if call < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	repo := NewAnnictRepository("token", testAnnictConfig(server.URL), nil)
+
+	works, _, err := repo.SearchWorks("テスト", 10, "")
+	if err != nil {
+		t.Fatalf("SearchWorks() error = %v", err)
+	}
+	if len(works) != 1 || works[0].ExternalID != 1 {
+		t.Errorf("works = %+v, want one work with id 1", works)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestAnnictBreakerOpensOnServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := newTestAnnictServer(t, &calls, func(int32) int { return http.StatusBadGateway })
+	repo := NewAnnictRepository("token", testAnnictConfig(server.URL), nil)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := repo.SearchWorks("テスト", 10, ""); !errors.Is(err, ErrAnnictBadGateway) {
+			t.Fatalf("SearchWorks() error = %v, want ErrAnnictBadGateway", err)
+		}
+	}
+
+	before := calls.Load()
+	_, _, err := repo.SearchWorks("テスト", 10, "")
+	if !errors.Is(err, ErrAnnictUnavailable) {
+		t.Fatalf("SearchWorks() error = %v, want ErrAnnictUnavailable", err)
+	}
+	if calls.Load() != before {
+		t.Error("open breaker should not send requests")
+	}
+}
+
+func TestAnnictClientErrorsDoNotTripBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := newTestAnnictServer(t, &calls, func(int32) int { return http.StatusUnauthorized })
+	repo := NewAnnictRepository("token", testAnnictConfig(server.URL), nil)
+
+	for i := 0; i < 5; i++ {
+		_, _, err := repo.SearchWorks("テスト", 10, "")
+		if !errors.Is(err, ErrAnnictRejected) {
+			t.Fatalf("SearchWorks() error = %v, want ErrAnnictRejected", err)
+		}
+		var annictErr *AnnictError
+		if !errors.As(err, &annictErr) || annictErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("SearchWorks() error = %v, want status 401", err)
+		}
+	}
+	// 4xx は再試行せず、ブレーカーも開かない
+	if got := calls.Load(); got != 5 {
+		t.Errorf("calls = %d, want 5", got)
+	}
+}
+
+func TestAnnictRateLimited(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	repo := NewAnnictRepository("token", testAnnictConfig(server.URL), nil)
+
+	_, _, err := repo.SearchWorks("テスト", 10, "")
+	var annictErr *AnnictError
+	if !errors.As(err, &annictErr) || !errors.Is(err, ErrAnnictRateLimited) {
+		t.Fatalf("SearchWorks() error = %v, want ErrAnnictRateLimited", err)
+	}
+	if annictErr.RetryAfter != time.Minute {
+		t.Errorf("RetryAfter = %v, want 1m", annictErr.RetryAfter)
+	}
+
+	// 待ち時間が MaxBackoff より長いので再試行せず、解除されるまで問い合わせない
+	if _, _, err := repo.SearchWorks("テスト", 10, ""); !errors.Is(err, ErrAnnictRateLimited) {
+		t.Fatalf("SearchWorks() error = %v, want ErrAnnictRateLimited", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestAnnictServesStaleSearchWhileBreakerOpen(t *testing.T) {
+	var calls atomic.Int32
+	server := newTestAnnictServer(t, &calls, func(call int32) int {
+		if call == 1 {
+			return http.StatusOK
+		}
+		return http.StatusServiceUnavailable
+	})
+	config := testAnnictConfig(server.URL)
+	config.SearchCacheTTL = time.Millisecond
+	config.MaxRetries = 0
+	config.FailureThreshold = 1
+	repo := NewAnnictRepository("token", config, cache.NewLRU(10))
+
+	if _, _, err := repo.SearchWorks("テスト", 10, ""); err != nil {
+		t.Fatalf("SearchWorks() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// キャッシュが期限切れなので問い合わせ、失敗してブレーカーが開く
+	if _, _, err := repo.SearchWorks("テスト", 10, ""); !errors.Is(err, ErrAnnictBadGateway) {
+		t.Fatalf("SearchWorks() error = %v, want ErrAnnictBadGateway", err)
+	}
+
+	// ブレーカーが開いている間は期限切れのキャッシュを返す
+	works, _, err := repo.SearchWorks("テスト", 10, "")
+	if err != nil {
+		t.Fatalf("SearchWorks() error = %v, want stale result", err)
+	}
+	if len(works) != 1 {
+		t.Errorf("works = %+v, want the cached work", works)
+	}
+
+	// キャッシュにない検索はそのまま失敗する
+	if _, _, err := repo.SearchWorks("別の作品", 10, ""); !errors.Is(err, ErrAnnictUnavailable) {
+		t.Fatalf("SearchWorks() error = %v, want ErrAnnictUnavailable", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-1", 0},
+		{"invalid", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidateSearchCacheMatchesKeywordExactly(t *testing.T) {
+	var calls atomic.Int32
+	server := newTestAnnictServer(t, &calls, func(int32) int { return http.StatusOK })
+	config := testAnnictConfig(server.URL)
+	config.SearchCacheTTL = time.Hour
+	repo := NewAnnictRepository("token", config, cache.NewLRU(10))
+
+	// "a" のキャッシュを消しても、"|" を含む "a|b" のキャッシュは残す
+	for _, keyword := range []string{"a", "a|b"} {
+		for _, limit := range []int{10, 20} {
+			if _, _, err := repo.SearchWorks(keyword, limit, ""); err != nil {
+				t.Fatalf("SearchWorks(%q) error = %v", keyword, err)
+			}
+		}
+	}
+	if deleted := repo.InvalidateSearchCache("a"); deleted != 2 {
+		t.Errorf("InvalidateSearchCache(a) = %d, want 2", deleted)
+	}
+	if deleted := repo.InvalidateSearchCache("a|b"); deleted != 2 {
+		t.Errorf("InvalidateSearchCache(a|b) = %d, want 2", deleted)
+	}
+}
+
+func TestAnnictGetWorkByIDReadsMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"searchWorks":{"nodes":[{
+			"annictId":7,"title":"テスト","titleKana":"てすと","titleEn":"Test",
+			"seasonYear":2024,"seasonName":"SPRING","media":"TV","episodesCount":12,
+			"officialSiteUrl":"https://example.com","twitterUsername":"test_anime",
+			"wikipediaUrl":"https://ja.wikipedia.org/wiki/test",
+			"image":{"recommendedImageUrl":"https://example.com/image.png"}
+		}],"pageInfo":{"hasNextPage":false}}}}`))
+	}))
+	t.Cleanup(server.Close)
+	repo := NewAnnictRepository("token", testAnnictConfig(server.URL), nil)
+
+	work, err := repo.GetWorkByID(7)
+	if err != nil {
+		t.Fatalf("GetWorkByID() error = %v", err)
+	}
+	if work.Provider != models.MetadataProviderAnnict || work.ExternalID != 7 || work.TitleKana != "てすと" || work.TitleEn != "Test" {
+		t.Errorf("work = %+v, want annict work 7 with its titles", work)
+	}
+	if work.SeasonYear == nil || *work.SeasonYear != 2024 || work.SeasonName == nil || *work.SeasonName != "SPRING" {
+		t.Errorf("season = %v %v, want 2024 SPRING", work.SeasonYear, work.SeasonName)
+	}
+	if work.Media != "TV" || work.EpisodesCount != 12 || work.ImageURL != "https://example.com/image.png" ||
+		work.OfficialSiteURL != "https://example.com" || work.TwitterUsername != "test_anime" ||
+		work.WikipediaURL != "https://ja.wikipedia.org/wiki/test" {
+		t.Errorf("work = %+v, want all metadata fields", work)
+	}
+}
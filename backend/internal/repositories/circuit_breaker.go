@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitState はサーキットブレーカーの状態
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // 通常どおり問い合わせる
+	circuitOpen                         // 失敗が続いたので問い合わせを止めている
+	circuitHalfOpen                     // 止めてから一定時間たったので、1件だけ試しに問い合わせている
+)
+
+// circuitBreaker は外部APIの障害が続いたときに問い合わせを一時的に止める仕組み
+// 障害中に毎回タイムアウトまで待たされたり、復旧しかけたAPIに負荷をかけ続けたりするのを防ぐ
+//
+//	closed   --(threshold回連続で失敗)--> open
+//	open     --(openTimeout経過)-------> halfOpen（1件だけ通す）
+//	halfOpen --(成功)------------------> closed
+//	halfOpen --(失敗)------------------> open
+type circuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	state       circuitState
+	failures    int       // 連続で失敗した回数
+	openedAt    time.Time // open になった時刻
+}
+
+// newCircuitBreaker はサーキットブレーカーを生成する（threshold が0以下なら常に問い合わせを許可する）
+func newCircuitBreaker(threshold int, openTimeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// allow は問い合わせてよいかを返す
+// 許可しない場合は、次に問い合わせできるようになるまでの目安の時間も返す
+func (b *circuitBreaker) allow() (bool, time.Duration) {
+	if b.threshold <= 0 {
+		return true, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if elapsed := time.Since(b.openedAt); elapsed < b.openTimeout {
+			return false, b.openTimeout - elapsed
+		}
+		// 一定時間たったので、この呼び出しだけ試しに通す
+		b.state = circuitHalfOpen
+		return true, 0
+	case circuitHalfOpen:
+		// 試しの問い合わせの結果が出るまでは他の呼び出しは止めておく
+		return false, b.openTimeout
+	}
+	return true, 0
+}
+
+// success は問い合わせが成功した（Annictが応答した）ことを記録する
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = circuitClosed
+	b.failures = 0
+}
+
+// failure は問い合わせが失敗したことを記録し、必要ならブレーカーを開く
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		b.failure()
+		if ok, _ := b.allow(); !ok {
+			t.Fatalf("breaker opened after %d failures, want 3", i+1)
+		}
+	}
+
+	b.failure()
+	ok, wait := b.allow()
+	if ok {
+		t.Fatal("breaker should be open after 3 failures")
+	}
+	if wait <= 0 || wait > time.Hour {
+		t.Errorf("wait = %v, want within (0, 1h]", wait)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+
+	b.failure()
+	b.success()
+	b.failure()
+	if ok, _ := b.allow(); !ok {
+		t.Fatal("failures should be counted consecutively")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(1, 10*time.Millisecond)
+
+	b.failure()
+	if ok, _ := b.allow(); ok {
+		t.Fatal("breaker should be open")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// open から一定時間たったら1件だけ通す
+	if ok, _ := b.allow(); !ok {
+		t.Fatal("breaker should let one trial request through")
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatal("breaker should block others while the trial request is in flight")
+	}
+
+	// 試しの問い合わせが失敗したら再び open
+	b.failure()
+	if ok, _ := b.allow(); ok {
+		t.Fatal("breaker should reopen when the trial request fails")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := b.allow(); !ok {
+		t.Fatal("breaker should let one trial request through")
+	}
+	b.success()
+	if ok, _ := b.allow(); !ok {
+		t.Fatal("breaker should close when the trial request succeeds")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		b.failure()
+	}
+	if ok, _ := b.allow(); !ok {
+		t.Fatal("breaker with threshold 0 should always allow")
+	}
+}
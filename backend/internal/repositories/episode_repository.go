@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type EpisodeRepository struct {
+	db *sqlx.DB
+}
+
+// NewEpisodeRepository はDB接続を受け取ってリポジトリを生成する
+func NewEpisodeRepository(db *sqlx.DB) *EpisodeRepository {
+	return &EpisodeRepository{db: db}
+}
+
+// SaveForAnime はAnnictから取得したエピソード一覧をDBに保存する
+// 既に保存済みのエピソードは話数やタイトルを更新する
+// 全エピソードの保存と animes.episodes_synced_at の更新を同じトランザクションで行い、
+// 途中で失敗したときに「同期済みなのにエピソードが欠けている」状態にならないようにする
+func (r *EpisodeRepository) SaveForAnime(animeID int64, episodes []models.Episode) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO episodes (anime_id, annict_id, number, number_text, sort_number, title)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (annict_id) DO UPDATE
+		SET number = EXCLUDED.number,
+		    number_text = EXCLUDED.number_text,
+		    sort_number = EXCLUDED.sort_number,
+		    title = EXCLUDED.title
+	`
+
+	for _, e := range episodes {
+		if _, err := tx.Exec(query, animeID, e.AnnictID, e.Number, e.NumberText, e.SortNumber, e.Title); err != nil {
+			return fmt.Errorf("failed to save episode: %w", err)
+		}
+	}
+
+	// エピソードが0件の作品（映画など）でも毎回Annictに問い合わせないよう、同期日時を記録する
+	if _, err := tx.Exec(`UPDATE animes SET episodes_synced_at = CURRENT_TIMESTAMP WHERE id = $1`, animeID); err != nil {
+		return fmt.Errorf("failed to update episodes_synced_at: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// IsSynced はアニメのエピソードをAnnictから取得済みか確認する
+func (r *EpisodeRepository) IsSynced(animeID int64) (bool, error) {
+	var synced bool
+	query := `SELECT episodes_synced_at IS NOT NULL FROM animes WHERE id = $1`
+
+	if err := r.db.Get(&synced, query, animeID); err != nil {
+		return false, fmt.Errorf("failed to check episodes sync: %w", err)
+	}
+
+	return synced, nil
+}
+
+// FindByID はエピソードIDでエピソードを1件取得する
+func (r *EpisodeRepository) FindByID(id int64) (*models.Episode, error) {
+	query := `
+		SELECT id, anime_id, annict_id, number, number_text, sort_number, title, created_at
+		FROM episodes
+		WHERE id = $1
+	`
+
+	var episode models.Episode
+	err := r.db.Get(&episode, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // エピソードがない場合はnilを返す
+		}
+		return nil, fmt.Errorf("failed to find episode: %w", err)
+	}
+
+	return &episode, nil
+}
+
+// FindStatsByAnimeID はアニメのエピソード一覧を視聴数・平均点付きで取得する（話数順）
+func (r *EpisodeRepository) FindStatsByAnimeID(animeID int64) ([]models.EpisodeStats, error) {
+	return findEpisodeStats(r.db, animeID)
+}
+
+// findEpisodeStats はエピソードごとの統計を取得する
+// AnimeRepository からも詳細ページの統計情報として使うため、リポジトリに依存しない関数にしている
+func findEpisodeStats(q sqlx.Queryer, animeID int64) ([]models.EpisodeStats, error) {
+	// COUNT(w.score) はNULLを数えないので、スコアを付けたユーザー数になる
+	query := `
+		SELECT
+			e.id AS episode_id,
+			e.number,
+			e.number_text,
+			e.sort_number,
+			e.title,
+			COUNT(w.user_id) AS watch_count,
+			COUNT(w.score) AS score_count,
+			ROUND(AVG(w.score), 1)::float8 AS avg_score
+		FROM episodes e
+		LEFT JOIN episode_watches w ON e.id = w.episode_id
+		WHERE e.anime_id = $1
+		GROUP BY e.id
+		ORDER BY e.sort_number ASC, e.id ASC
+	`
+
+	// 0件の場合も null ではなく空配列を返すように初期化しておく
+	stats := []models.EpisodeStats{}
+	err := sqlx.Select(q, &stats, query, animeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find episode stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// UpsertWatch はエピソードを視聴済みにする
+// 既に視聴済みの場合はスコアを上書きする（視聴日時は最初のまま）
+func (r *EpisodeRepository) UpsertWatch(watch *models.EpisodeWatch) error {
+	query := `
+		INSERT INTO episode_watches (user_id, episode_id, score)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, episode_id) DO UPDATE
+		SET score = EXCLUDED.score
+		RETURNING watched_at
+	`
+
+	err := r.db.QueryRow(query, watch.UserID, watch.EpisodeID, watch.Score).Scan(&watch.WatchedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save episode watch: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWatch はエピソードの視聴記録を削除する
+// 削除対象がなかった場合は false を返す
+func (r *EpisodeRepository) DeleteWatch(userID, episodeID int64) (bool, error) {
+	query := `DELETE FROM episode_watches WHERE user_id = $1 AND episode_id = $2`
+
+	result, err := r.db.Exec(query, userID, episodeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete episode watch: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// FindWatchesByUserAndAnime は特定のユーザーが特定のアニメで視聴済みにしたエピソードを取得する（話数順）
+func (r *EpisodeRepository) FindWatchesByUserAndAnime(userID, animeID int64) ([]models.EpisodeWatch, error) {
+	query := `
+		SELECT w.user_id, w.episode_id, w.score, w.watched_at
+		FROM episode_watches w
+		INNER JOIN episodes e ON w.episode_id = e.id
+		WHERE w.user_id = $1 AND e.anime_id = $2
+		ORDER BY e.sort_number ASC, e.id ASC
+	`
+
+	watches := []models.EpisodeWatch{}
+	err := r.db.Select(&watches, query, userID, animeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find episode watches: %w", err)
+	}
+
+	return watches, nil
+}
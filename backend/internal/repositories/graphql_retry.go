@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// retryableError は graphQLRetrier が再試行するか・障害として数えるかを判定するためのエラー
+// 取得元ごとのエラー（*AnnictError / *aniListError）が実装する
+type retryableError interface {
+	error
+	shouldRetry() bool        // 再試行すれば成功する可能性があるか（5xx・タイムアウト・429）
+	retryWait() time.Duration // 取得元が指定した再試行までの待ち時間（Retry-After。なければ0）
+	upstreamFailure() bool    // 取得元からまともな応答が得られなかったか（サーキットブレーカーの失敗として数える）
+}
+
+// graphQLRetrier は取得元の GraphQL API への問い合わせを、再試行とサーキットブレーカー付きで行う（Annict・AniList で共通）
+// 1回分の送信とエラーの分類は、取得元ごとのリポジトリが行う
+type graphQLRetrier struct {
+	config  models.RetryConfig
+	breaker *circuitBreaker
+}
+
+// newGraphQLRetrier は config の設定で graphQLRetrier を生成する
+func newGraphQLRetrier(config models.RetryConfig) *graphQLRetrier {
+	return &graphQLRetrier{
+		config:  config,
+		breaker: newCircuitBreaker(config.FailureThreshold, config.OpenTimeout),
+	}
+}
+
+// do は send を呼び出し、5xx・タイムアウト・429 は待ち時間をランダムにずらしながら再試行する
+// それでも失敗が続く場合はサーキットブレーカーを開き、しばらくは send を呼ばずに unavailable(再開までの時間) を返す
+func (g *graphQLRetrier) do(send func() error, unavailable func(wait time.Duration) error) error {
+	// 障害が続いている間は問い合わせずにすぐ失敗する
+	if ok, wait := g.breaker.allow(); !ok {
+		return unavailable(wait)
+	}
+
+	var err error
+	var retryAfter time.Duration
+	for attempt := 0; attempt <= g.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryBackoff(g.config, attempt, retryAfter))
+		}
+
+		err = send()
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || !retryable.shouldRetry() {
+			break
+		}
+		// 待ち時間が長すぎるレート制限などは再試行せずに諦める
+		if retryAfter = retryable.retryWait(); retryAfter > g.config.MaxBackoff {
+			break
+		}
+	}
+
+	// 取得元からまともな応答が得られなかった場合だけ障害として数える
+	// 429 や 4xx は取得元自体は動いているので、成功として扱う
+	var retryable retryableError
+	if err != nil && errors.As(err, &retryable) && retryable.upstreamFailure() {
+		g.breaker.failure()
+	} else {
+		g.breaker.success()
+	}
+	return err
+}
+
+// retryBackoff は attempt 回目の再試行までの待ち時間を返す
+// BaseBackoff × 2^(attempt-1) を上限 MaxBackoff で打ち切り、その半分〜全部の範囲でランダムにずらす（ジッター）
+// 複数のリクエストが同時に失敗しても、再試行のタイミングが重ならないようにするため
+// 取得元から Retry-After が返ってきていれば、それより短くはしない
+func retryBackoff(config models.RetryConfig, attempt int, retryAfter time.Duration) time.Duration {
+	wait := config.BaseBackoff << (attempt - 1)
+	if wait <= 0 || wait > config.MaxBackoff {
+		wait = config.MaxBackoff
+	}
+	if wait > 0 {
+		wait = wait/2 + rand.N(wait/2+1)
+	}
+
+	if retryAfter > wait {
+		return retryAfter
+	}
+	return wait
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRetryConfig() models.RetryConfig {
+	return models.RetryConfig{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+	}
+}
+
+func TestGraphQLRetrierOpensBreakerOnlyOnUpstreamFailures(t *testing.T) {
+	retrier := newGraphQLRetrier(testRetryConfig())
+	unavailable := func(time.Duration) error { return &aniListError{kind: ErrAniListUnavailable} }
+
+	// 4xx は再試行せず、何回続いてもブレーカーを開かない
+	calls := 0
+	for range 3 {
+		err := retrier.do(func() error {
+			calls++
+			return &aniListError{kind: ErrAniListRequestFailed, statusCode: http.StatusBadRequest}
+		}, unavailable)
+		if !errors.Is(err, ErrAniListRequestFailed) {
+			t.Fatalf("do() error = %v, want ErrAniListRequestFailed", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	// 5xx は MaxRetries 回まで再試行し、FailureThreshold 回続いたら問い合わせを止める
+	calls = 0
+	for range 2 {
+		retrier.do(func() error {
+			calls++
+			return &aniListError{kind: ErrAniListRequestFailed, retryable: true, unhealthy: true}
+		}, unavailable)
+	}
+	if calls != 6 {
+		t.Errorf("calls = %d, want 6", calls)
+	}
+	err := retrier.do(func() error {
+		t.Error("open breaker should not send requests")
+		return nil
+	}, unavailable)
+	if !errors.Is(err, ErrAniListUnavailable) {
+		t.Errorf("do() error = %v, want ErrAniListUnavailable", err)
+	}
+}
+
+func TestAniListRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"Page":{"media":[{"id":1,"title":{"native":"テスト"}}]}}}`))
+	}))
+	t.Cleanup(server.Close)
+	repo := NewAniListRepository(models.AniListClientConfig{RetryConfig: testRetryConfig(), Endpoint: server.URL})
+
+	work, err := repo.GetWorkByID(1)
+	if err != nil {
+		t.Fatalf("GetWorkByID() error = %v", err)
+	}
+	if work.ExternalID != 1 || work.Title != "テスト" {
+		t.Errorf("work = %+v, want id 1", work)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type LibraryRepository struct {
+	db *sqlx.DB
+}
+
+// NewLibraryRepository はDB接続を受け取ってリポジトリを生成する
+func NewLibraryRepository(db *sqlx.DB) *LibraryRepository {
+	return &LibraryRepository{db: db}
+}
+
+// upsertStatusQuery は視聴ステータスを保存するクエリ（設定済みなら上書き）
+const upsertStatusQuery = `
+	INSERT INTO user_anime_status (user_id, anime_id, status)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, anime_id) DO UPDATE
+	SET status = EXCLUDED.status, updated_at = CURRENT_TIMESTAMP
+	RETURNING created_at, updated_at
+`
+
+// Upsert は視聴ステータスを保存する
+// 既に設定済みの場合はステータスを上書きし、updated_at を更新する
+func (r *LibraryRepository) Upsert(entry *models.UserAnimeStatus) error {
+	return upsertStatus(r.db, entry)
+}
+
+// UpsertAll は複数の視聴ステータスを1つのトランザクションでまとめて保存する
+// 途中で失敗したときは1件も保存しない（視聴履歴の取り込みが中途半端な状態にならないようにする）
+func (r *LibraryRepository) UpsertAll(entries []*models.UserAnimeStatus) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if err := upsertStatus(tx, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// upsertStatus は Upsert の本体。トランザクション上でも使えるように q を受け取る
+func upsertStatus(q sqlx.Queryer, entry *models.UserAnimeStatus) error {
+	err := q.QueryRowx(
+		upsertStatusQuery,
+		entry.UserID,
+		entry.AnimeID,
+		entry.Status,
+	).Scan(&entry.CreatedAt, &entry.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save anime status: %w", err)
+	}
+
+	return nil
+}
+
+// Delete は視聴ステータスを削除する
+// 削除対象がなかった場合は false を返す
+func (r *LibraryRepository) Delete(userID, animeID int64) (bool, error) {
+	query := `DELETE FROM user_anime_status WHERE user_id = $1 AND anime_id = $2`
+
+	result, err := r.db.Exec(query, userID, animeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete anime status: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// FindByUserID は特定のユーザーのライブラリをアニメ情報と共に取得する（更新が新しい順）
+// status が空文字の場合はすべてのステータスを返す
+func (r *LibraryRepository) FindByUserID(userID int64, status string) ([]models.LibraryEntry, error) {
+	query := `
+		SELECT
+			s.anime_id,
+			s.status,
+			s.created_at,
+			s.updated_at,
+			a.annict_id AS anime_annict_id,
+			a.title AS anime_title,
+			a.year AS anime_year,
+			a.season AS anime_season,
+			a.image_url AS anime_image_url
+		FROM user_anime_status s
+		INNER JOIN animes a ON s.anime_id = a.id
+		WHERE s.user_id = $1
+		  AND ($2 = '' OR s.status = $2)
+		ORDER BY s.updated_at DESC
+	`
+
+	// 0件の場合も null ではなく空配列を返すように初期化しておく
+	entries := []models.LibraryEntry{}
+	err := r.db.Select(&entries, query, userID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find library: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ログインの試行のアドバイザリーロックのキーの1つ目の値（2つ目はメールアドレス・IPアドレスのハッシュ）
+// 署名鍵のロック（2, 0）と重ならないようにする
+const (
+	loginAttemptEmailLockNamespace = 3
+	loginAttemptIPLockNamespace    = 4
+)
+
+type LoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+// NewLoginAttemptRepository はDB接続を受け取ってリポジトリを生成する
+func NewLoginAttemptRepository(db *sqlx.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// Create はログインの試行を記録し、採番されたIDと作成日時を attempt にセットする
+func (r *LoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	return createLoginAttempt(r.db, attempt)
+}
+
+func createLoginAttempt(q sqlx.Queryer, attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, user_id, ip_address, user_agent, result)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := q.QueryRowx(query, attempt.Email, attempt.UserID, attempt.IPAddress, attempt.UserAgent, attempt.Result).
+		Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create login attempt: %w", err)
+	}
+	return nil
+}
+
+// UpdateResult は記録した試行の結果とユーザーを更新する（パスワードを確認した後に呼ぶ）
+func (r *LoginAttemptRepository) UpdateResult(id int64, userID *int, result string) error {
+	query := `UPDATE login_attempts SET user_id = $2, result = $3 WHERE id = $1`
+
+	if _, err := r.db.Exec(query, id, userID, result); err != nil {
+		return fmt.Errorf("failed to update login attempt: %w", err)
+	}
+	return nil
+}
+
+// AccountFailures は since 以降のメールアドレスへのログインの失敗回数を返す
+// 最後にログインに成功（またはパスワードを再設定）してからの失敗だけを数える（本人がログインできたらリセットする）
+func (r *LoginAttemptRepository) AccountFailures(email string, since time.Time) (models.LoginFailureStats, error) {
+	return accountLoginFailures(r.db, email, since)
+}
+
+func accountLoginFailures(q sqlx.Queryer, email string, since time.Time) (models.LoginFailureStats, error) {
+	query := `
+		SELECT COUNT(*) AS count, MAX(created_at) AS last_failed_at
+		FROM login_attempts
+		WHERE email = $1
+		  AND result = $2
+		  AND created_at > $3
+		  AND created_at > COALESCE(
+			(SELECT MAX(created_at) FROM login_attempts WHERE email = $1 AND result IN ($4, $5) AND created_at > $3),
+			$3
+		  )
+	`
+
+	var stats models.LoginFailureStats
+	err := sqlx.Get(q, &stats, query,
+		email, models.LoginResultInvalidCredentials, since, models.LoginResultSuccess, models.LoginResultPasswordReset)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return stats, nil
+}
+
+// IPFailures は since 以降のIPアドレスからのログインの失敗回数を返す
+// 攻撃者が自分のアカウントでログインしてリセットできないように、成功してもリセットしない
+func (r *LoginAttemptRepository) IPFailures(ipAddress string, since time.Time) (models.LoginFailureStats, error) {
+	return ipLoginFailures(r.db, ipAddress, since)
+}
+
+func ipLoginFailures(q sqlx.Queryer, ipAddress string, since time.Time) (models.LoginFailureStats, error) {
+	query := `
+		SELECT COUNT(*) AS count, MAX(created_at) AS last_failed_at
+		FROM login_attempts
+		WHERE ip_address = $1 AND result = $2 AND created_at > $3
+	`
+
+	var stats models.LoginFailureStats
+	if err := sqlx.Get(q, &stats, query, ipAddress, models.LoginResultInvalidCredentials, since); err != nil {
+		return stats, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return stats, nil
+}
+
+// List はログインの試行履歴を新しい順に条件付きで取得する（管理者用）
+func (r *LoginAttemptRepository) List(opts models.LoginAttemptListOptions) ([]models.LoginAttempt, error) {
+	query := `SELECT * FROM login_attempts WHERE 1 = 1`
+	args := []any{}
+
+	// プレースホルダの番号は引数を追加した後の件数に合わせる
+	if opts.Email != "" {
+		args = append(args, opts.Email)
+		query += fmt.Sprintf(" AND email = $%d", len(args))
+	}
+	if opts.IP != "" {
+		args = append(args, opts.IP)
+		query += fmt.Sprintf(" AND ip_address = $%d", len(args))
+	}
+	if opts.UserID != nil {
+		args = append(args, *opts.UserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+	if opts.Result != "" {
+		args = append(args, opts.Result)
+		query += fmt.Sprintf(" AND result = $%d", len(args))
+	}
+	if opts.BeforeID > 0 {
+		args = append(args, opts.BeforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, opts.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	// 0件の場合も null ではなく空配列を返すように初期化しておく
+	attempts := []models.LoginAttempt{}
+	if err := r.db.Select(&attempts, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to find login attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteBefore は before より前のログインの試行履歴を削除し、削除した件数を返す
+func (r *LoginAttemptRepository) DeleteBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM login_attempts WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login attempts: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return deleted, nil
+}
+
+// WithLock はメールアドレスとIPアドレスのアドバイザリーロックを取得してから fn を実行する
+// 同時に届いたログインが、どれも失敗回数を数えてから記録するまでの間に割り込めないようにするために使う
+// （ロックがないと、同時に送った分だけ待ち時間やロックを超えてパスワードを試せてしまう）
+// ロックはいつもメールアドレス、IPアドレスの順に取るので、デッドロックにはならない
+// ロックはトランザクション単位なので、fn が終わってトランザクションを閉じると自動で解放される
+func (r *LoginAttemptRepository) WithLock(email, ipAddress string, fn func(tx *LoginAttemptTx) error) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, loginAttemptEmailLockNamespace, email); err != nil {
+		return fmt.Errorf("failed to acquire login attempt lock: %w", err)
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, loginAttemptIPLockNamespace, ipAddress); err != nil {
+		return fmt.Errorf("failed to acquire login attempt lock: %w", err)
+	}
+
+	if err := fn(&LoginAttemptTx{tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit login attempt transaction: %w", err)
+	}
+	return nil
+}
+
+// LoginAttemptTx は WithLock でロックを取ったトランザクション上でログインの試行を読み書きする
+type LoginAttemptTx struct {
+	tx *sqlx.Tx
+}
+
+// AccountFailures はトランザクション上で LoginAttemptRepository.AccountFailures と同じことを行う
+func (t *LoginAttemptTx) AccountFailures(email string, since time.Time) (models.LoginFailureStats, error) {
+	return accountLoginFailures(t.tx, email, since)
+}
+
+// IPFailures はトランザクション上で LoginAttemptRepository.IPFailures と同じことを行う
+func (t *LoginAttemptTx) IPFailures(ipAddress string, since time.Time) (models.LoginFailureStats, error) {
+	return ipLoginFailures(t.tx, ipAddress, since)
+}
+
+// Create はトランザクション上でログインの試行を記録する
+func (t *LoginAttemptTx) Create(attempt *models.LoginAttempt) error {
+	return createLoginAttempt(t.tx, attempt)
+}
+
+// RecentlyThrottled は after より後に、同じメールアドレス・IPアドレスからの試行を拒否した記録があるかを返す
+// 拒否し続けている間に届いた試行をすべて記録すると、攻撃されたときに行が増え続けるので、間引くために使う
+func (t *LoginAttemptTx) RecentlyThrottled(email, ipAddress string, after time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM login_attempts
+			WHERE email = $1 AND ip_address = $2 AND result = $3 AND created_at > $4
+		)
+	`
+
+	var exists bool
+	if err := t.tx.Get(&exists, query, email, ipAddress, models.LoginResultThrottled, after); err != nil {
+		return false, fmt.Errorf("failed to find throttled login attempts: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,23 @@
+package repositories
+
+import "anime-score-backend/internal/models"
+
+// MetadataProvider はアニメのメタデータを外部サービスから取得するためのインターフェース
+// Annict 以外のサービス（AniList など）も同じ形で扱えるようにする
+type MetadataProvider interface {
+	// Name は取得元の名前を返す（models.MetadataProviderAnnict など）
+	Name() string
+	// SearchWorks はタイトルで作品を検索し、結果と次ページのカーソルを返す（次ページがなければ空文字）
+	SearchWorks(keyword string, limit int, afterCursor string) ([]models.MetadataWork, string, error)
+	// GetWorkByID は取得元での作品IDを指定して作品を取得する
+	GetWorkByID(id int) (*models.MetadataWork, error)
+	// GetWorksByIDs は複数の作品をまとめて取得する（存在しないIDは結果に含まれない）
+	GetWorksByIDs(ids []int) ([]models.MetadataWork, error)
+}
+
+// SearchCacheInvalidator は検索結果をキャッシュする取得元が実装するインターフェース
+// 作品情報の更新に気づいたときに、古い作品情報を含む検索結果のキャッシュを消すために使う
+type SearchCacheInvalidator interface {
+	// InvalidateSearchCache は keyword の検索結果のキャッシュを削除する（空文字ならすべて）
+	InvalidateSearchCache(keyword string) int
+}
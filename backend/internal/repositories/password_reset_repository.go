@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type PasswordResetRepository struct {
+	db *sqlx.DB
+}
+
+// NewPasswordResetRepository はDB接続を受け取ってリポジトリを生成する
+func NewPasswordResetRepository(db *sqlx.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create はパスワード再設定用のトークンを保存し、採番されたIDと作成日時を token にセットする
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(query, token.UserID, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// FindValid はまだ使っておらず有効期限内のトークンを返す（見つからなければ sql.ErrNoRows）
+// パスワードのハッシュ化は重いので、その前に無効なトークンを弾くために使う
+func (r *PasswordResetRepository) FindValid(tokenHash string, now time.Time) (*models.PasswordResetToken, error) {
+	query := `
+		SELECT * FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`
+
+	var token models.PasswordResetToken
+	if err := r.db.Get(&token, query, tokenHash, now); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ResetPassword はトークンを使用済みにし、同じトランザクションで updatePassword を呼んでユーザーIDを返す
+// トークンが見つからない・使用済み・期限切れの場合は何もせずに false を返す
+// 使用済みにするのとパスワードの変更は1つのトランザクションで行うので、同じトークンで2回再設定されることはない
+// 再設定したら、そのユーザーの未使用のトークン（以前に送ったメールのリンク）もすべて無効にする
+func (r *PasswordResetRepository) ResetPassword(tokenHash string, now time.Time, updatePassword func(tx *sqlx.Tx, userID int) error) (int, bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE password_reset_tokens
+		SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id
+	`, tokenHash, now).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to use password reset token: %w", err)
+	}
+
+	if err := updatePassword(tx, userID); err != nil {
+		return 0, false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE password_reset_tokens
+		SET used_at = $2
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID, now); err != nil {
+		return 0, false, fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return userID, true, nil
+}
+
+// DeleteExpired は now より前に有効期限が切れたトークンを削除し、削除した件数を返す
+func (r *PasswordResetRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired password reset tokens: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RefreshTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewRefreshTokenRepository はDB接続を受け取ってリポジトリを生成する
+func NewRefreshTokenRepository(db *sqlx.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create はリフレッシュトークンを保存し、採番されたIDと作成日時を token にセットする
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	return createRefreshToken(r.db, token)
+}
+
+// createRefreshToken は Create の本体。トランザクション上でも使えるように q を受け取る
+func createRefreshToken(q sqlx.Queryer, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := q.QueryRowx(query, token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// FindByHash はトークンのハッシュからリフレッシュトークンを探す（見つからなければnil）
+func (r *RefreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+
+	var token models.RefreshToken
+	if err := r.db.Get(&token, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// Rotate は使われたリフレッシュトークン（id）を交換済みにし、新しいトークン next を保存する
+// 同時に同じトークンで交換されていた場合など、交換済み・失効済みだったときは何もせずに false を返す
+func (r *RefreshTokenRepository) Rotate(id int64, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens
+		SET rotated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := createRefreshToken(tx, next); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// MarkReissued は交換済みのリフレッシュトークン（id）が交換から grace 以内なら、1回だけ reissued_at を記録して true を返す
+// 2回目以降や猶予を過ぎた場合・失効済みの場合は何もせずに false を返す
+// 条件付きの UPDATE なので、同時に呼ばれても true になるのは1つだけ
+func (r *RefreshTokenRepository) MarkReissued(id int64, grace time.Duration) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE refresh_tokens
+		SET reissued_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		  AND rotated_at > CURRENT_TIMESTAMP - make_interval(secs => $2)
+		  AND reissued_at IS NULL AND revoked_at IS NULL
+	`, id, grace.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token as reissued: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// RevokeFamily は同じファミリー（同じログインから交換され続けたもの）のリフレッシュトークンをすべて失効させる
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(query, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForUser はユーザーのリフレッシュトークンをすべて失効させる
+func (r *RefreshTokenRepository) RevokeAllForUser(userID int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired は now より前に有効期限が切れたリフレッシュトークンを削除し、削除した件数を返す
+// 期限切れのトークンは再利用されても検証で弾かれるので、交換済みの記録を残しておく必要はない
+func (r *RefreshTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return deleted, nil
+}
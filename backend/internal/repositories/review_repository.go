@@ -41,11 +41,144 @@ func (r *ReviewRepository) Create(review *models.Review) error {
 	return nil
 }
 
+// FindByID はレビューIDでレビューを1件取得する
+// 編集・削除時に存在確認と所有者チェックを行うのに使用
+func (r *ReviewRepository) FindByID(id int64) (*models.Review, error) {
+	query := `
+		SELECT id, user_id, anime_id, score, comment, created_at, updated_at
+		FROM reviews
+		WHERE id = $1
+	`
+
+	var review models.Review
+	err := r.db.Get(&review, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // レビューがない場合はnilを返す
+		}
+		return nil, fmt.Errorf("failed to find review: %w", err)
+	}
+
+	return &review, nil
+}
+
+// Update はレビューのスコアとコメントを更新する
+// 更新前の内容は review_revisions に履歴として保存する
+// 履歴の追加と本体の更新は同じトランザクションで行い、片方だけが反映されることを防ぐ
+// WHERE句に user_id を含めることで、他のユーザーのレビューは更新されないようにしている
+// 更新対象がなかった場合は false を返す
+// anime_stats はビューなので、reviews を更新すれば統計情報も自動的に反映される
+func (r *ReviewRepository) Update(review *models.Review) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Commit前にreturnした場合はロールバックする（Commit後のRollbackは何もしない）
+	defer tx.Rollback()
+
+	// 1. 更新前のレビューを取得
+	// FOR UPDATE で行ロックをかけ、同時編集で履歴が欠けないようにする
+	var current models.Review
+	err = tx.Get(&current, `
+		SELECT id, user_id, anime_id, score, comment, created_at, updated_at
+		FROM reviews
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, review.ID, review.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find review: %w", err)
+	}
+
+	// 2. 内容が変わる場合のみ、更新前の内容を履歴として保存
+	if current.Score != review.Score || !sameComment(current.Comment, review.Comment) {
+		// 更新前の内容が書かれた日時（未編集なら投稿日時）
+		writtenAt := current.CreatedAt
+		if current.UpdatedAt != nil {
+			writtenAt = *current.UpdatedAt
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO review_revisions (review_id, score, comment, written_at)
+			VALUES ($1, $2, $3, $4)
+		`, current.ID, current.Score, current.Comment, writtenAt)
+		if err != nil {
+			return false, fmt.Errorf("failed to create review revision: %w", err)
+		}
+	}
+
+	// 3. レビュー本体を更新
+	err = tx.QueryRow(`
+		UPDATE reviews
+		SET score = $1, comment = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING anime_id, created_at, updated_at
+	`, review.Score, review.Comment, review.ID).Scan(&review.AnimeID, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to update review: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// sameComment はコメント（NULL許容）が同じ内容か比較する
+func sameComment(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// FindRevisionsByReviewID はレビューの編集履歴を古い順に取得する
+// 古い順にすることで、そのままスコアの推移として使える
+func (r *ReviewRepository) FindRevisionsByReviewID(reviewID int64) ([]models.ReviewRevision, error) {
+	query := `
+		SELECT id, review_id, score, comment, written_at, replaced_at
+		FROM review_revisions
+		WHERE review_id = $1
+		ORDER BY written_at ASC, id ASC
+	`
+
+	// 履歴がない場合も null ではなく空配列を返すように初期化しておく
+	revisions := []models.ReviewRevision{}
+	err := r.db.Select(&revisions, query, reviewID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find review revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// Delete はレビューを削除する
+// Update と同様に user_id で所有者を絞り込み、削除できなかった場合は false を返す
+func (r *ReviewRepository) Delete(id, userID int64) (bool, error) {
+	query := `DELETE FROM reviews WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete review: %w", err)
+	}
+
+	// RowsAffected で実際に削除された行数を確認する
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
 // FindByUserAndAnime は特定のユーザーが特定のアニメに対して既にレビューしているか確認する
 // 1ユーザー1作品1レビューの制約チェックに使用
 func (r *ReviewRepository) FindByUserAndAnime(userID, animeID int64) (*models.Review, error) {
 	query := `
-		SELECT id, user_id, anime_id, score, comment, created_at
+		SELECT id, user_id, anime_id, score, comment, created_at, updated_at
 		FROM reviews
 		WHERE user_id = $1 AND anime_id = $2
 	`
@@ -58,6 +191,7 @@ func (r *ReviewRepository) FindByUserAndAnime(userID, animeID int64) (*models.Re
 		&review.Score,
 		&review.Comment,
 		&review.CreatedAt,
+		&review.UpdatedAt,
 	)
 
 	if err != nil {
@@ -70,17 +204,90 @@ func (r *ReviewRepository) FindByUserAndAnime(userID, animeID int64) (*models.Re
 	return &review, nil
 }
 
-// FindByAnimeID は特定のアニメのレビュー一覧を取得する（新着順）
-func (r *ReviewRepository) FindByAnimeID(animeID int64) ([]models.Review, error) {
+// reviewSortColumns は並び順ごとの「並び替えキー」「カーソル値の型」「方向」
+// ORDER BY とカーソル条件を同じ定義から組み立てることで、両者がずれないようにする
+var reviewSortColumns = map[string]struct {
+	column string
+	cast   string
+	desc   bool
+}{
+	models.ReviewSortNewest:    {column: "created_at", cast: "timestamptz", desc: true},
+	models.ReviewSortOldest:    {column: "created_at", cast: "timestamptz", desc: false},
+	models.ReviewSortScoreDesc: {column: "score", cast: "int", desc: true},
+	models.ReviewSortScoreAsc:  {column: "score", cast: "int", desc: false},
+	models.ReviewSortHelpful:   {column: "helpful_count", cast: "int", desc: true},
+}
+
+// reviewAuthorColumns は投稿者情報を取得するSELECT句の断片
+// users を u としてJOINしたクエリで使う。email と password_hash は絶対に含めないこと
+// レビュー数は idx_reviews_user_id を使う相関サブクエリで数える
+const reviewAuthorColumns = `
+			u.id AS "author.id",
+			u.username AS "author.username",
+			(SELECT COUNT(*) FROM reviews ur WHERE ur.user_id = u.id) AS "author.review_count",
+			u.created_at AS "author.joined_at"`
+
+// FindByAnimeID は特定のアニメのレビュー一覧を条件付きで取得する
+// (並び替えキー, id) の組でカーソル位置より後ろを取得するキーセットページネーション
+// OFFSETと違い、ページが進んでも読み飛ばす行が増えず、途中で投稿があってもずれない
+func (r *ReviewRepository) FindByAnimeID(animeID int64, opts models.ReviewListOptions) ([]models.Review, error) {
+	sort, ok := reviewSortColumns[opts.Sort]
+	if !ok {
+		sort = reviewSortColumns[models.ReviewSortNewest]
+	}
+
+	// helpful_count は集計値なので、サブクエリで列として扱えるようにしてから絞り込む
+	// users と列名が重なるため、以降の条件は rv. を付けて書く
 	query := `
-		SELECT id, user_id, anime_id, score, comment, created_at
-		FROM reviews
-		WHERE anime_id = $1
-		ORDER BY created_at DESC
+		SELECT
+			rv.id, rv.user_id, rv.anime_id, rv.score, rv.comment,
+			rv.created_at, rv.updated_at, rv.helpful_count,` + reviewAuthorColumns + `
+		FROM (
+			SELECT r.*, COALESCE(h.helpful_count, 0) AS helpful_count
+			FROM reviews r
+			LEFT JOIN (
+				SELECT review_id, COUNT(*) AS helpful_count
+				FROM review_helpful_votes
+				GROUP BY review_id
+			) h ON r.id = h.review_id
+			WHERE r.anime_id = $1
+		) rv
+		INNER JOIN users u ON rv.user_id = u.id
+		WHERE 1 = 1
 	`
+	args := []any{animeID}
 
-	var reviews []models.Review
-	err := r.db.Select(&reviews, query, animeID)
+	// プレースホルダの番号は引数を追加した後の件数に合わせる
+	if opts.HasComment {
+		query += ` AND rv.comment IS NOT NULL AND rv.comment <> ''`
+	}
+	if opts.MinScore != nil {
+		args = append(args, *opts.MinScore)
+		query += fmt.Sprintf(" AND rv.score >= $%d", len(args))
+	}
+	if opts.MaxScore != nil {
+		args = append(args, *opts.MaxScore)
+		query += fmt.Sprintf(" AND rv.score <= $%d", len(args))
+	}
+
+	direction, operator := "ASC", ">"
+	if sort.desc {
+		direction, operator = "DESC", "<"
+	}
+
+	// 行値比較 (a, b) < (x, y) で「並び替えキーが同じならidで比較」を1つの条件で表せる
+	if opts.Cursor != nil {
+		args = append(args, opts.Cursor.Value, opts.Cursor.ID)
+		query += fmt.Sprintf(" AND (rv.%s, rv.id) %s ($%d::%s, $%d)",
+			sort.column, operator, len(args)-1, sort.cast, len(args))
+	}
+
+	args = append(args, opts.Limit)
+	query += fmt.Sprintf(" ORDER BY rv.%s %s, rv.id %s LIMIT $%d", sort.column, direction, direction, len(args))
+
+	// 0件の場合も null ではなく空配列を返すように初期化しておく
+	reviews := []models.Review{}
+	err := r.db.Select(&reviews, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find reviews: %w", err)
 	}
@@ -88,10 +295,37 @@ func (r *ReviewRepository) FindByAnimeID(animeID int64) ([]models.Review, error)
 	return reviews, nil
 }
 
+// AddHelpfulVote はレビューに「参考になった」を付ける
+// 同じユーザーが何度送っても1票になるように、重複時は何もしない
+func (r *ReviewRepository) AddHelpfulVote(reviewID, userID int64) error {
+	query := `
+		INSERT INTO review_helpful_votes (review_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (review_id, user_id) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(query, reviewID, userID); err != nil {
+		return fmt.Errorf("failed to add helpful vote: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveHelpfulVote はレビューの「参考になった」を取り消す
+func (r *ReviewRepository) RemoveHelpfulVote(reviewID, userID int64) error {
+	query := `DELETE FROM review_helpful_votes WHERE review_id = $1 AND user_id = $2`
+
+	if _, err := r.db.Exec(query, reviewID, userID); err != nil {
+		return fmt.Errorf("failed to remove helpful vote: %w", err)
+	}
+
+	return nil
+}
+
 // FindByUserID は特定のユーザーのレビュー一覧を取得する（新着順）
 func (r *ReviewRepository) FindByUserID(userID int64) ([]models.Review, error) {
 	query := `
-		SELECT id, user_id, anime_id, score, comment, created_at
+		SELECT id, user_id, anime_id, score, comment, created_at, updated_at
 		FROM reviews
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -116,6 +350,7 @@ func (r *ReviewRepository) FindByUserIDWithAnime(userID int64) ([]models.ReviewW
 			r.score,
 			r.comment,
 			r.created_at,
+			r.updated_at,
 			a.annict_id AS anime_annict_id,
 			a.title AS anime_title,
 			a.year AS anime_year,
@@ -135,7 +370,7 @@ func (r *ReviewRepository) FindByUserIDWithAnime(userID int64) ([]models.ReviewW
 	return reviews, nil
 }
 
-// レビューをアニメ情報とともに20件新着順に取得する
+// レビューをアニメ情報・投稿者情報とともに20件新着順に取得する
 func (r *ReviewRepository) FindAllWithAnime() ([]models.ReviewWithAnime, error) {
 	query := `
 		SELECT
@@ -145,12 +380,14 @@ func (r *ReviewRepository) FindAllWithAnime() ([]models.ReviewWithAnime, error)
 			r.score,
 			r.comment,
 			r.created_at,
+			r.updated_at,
 			a.annict_id AS anime_annict_id,
 			a.title AS anime_title,
 			a.year AS anime_year,
-			a.image_url AS anime_image_url
+			a.image_url AS anime_image_url,` + reviewAuthorColumns + `
 		FROM reviews r
 		INNER JOIN animes a ON r.anime_id = a.id
+		INNER JOIN users u ON r.user_id = u.id
 		ORDER BY r.created_at DESC
 		LIMIT 20
 	`
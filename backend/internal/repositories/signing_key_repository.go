@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"anime-score-backend/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// signingKeyLockNamespace は署名鍵の交換用のアドバイザリーロックのキーの1つ目の値
+// アニメのロック（1つ目は取得元の名前のハッシュ）と重ならないように、2つ目は0にする
+const signingKeyLockNamespace = 2
+
+type SigningKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewSigningKeyRepository はDB接続を受け取ってリポジトリを生成する
+func NewSigningKeyRepository(db *sqlx.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// FindUsable は now の時点で検証に使える署名鍵を新しい順に取得する
+// 署名に使う鍵（retired_at が NULL）と、交換済みで検証用に残している鍵の両方を含む
+func (r *SigningKeyRepository) FindUsable(now time.Time) ([]models.SigningKey, error) {
+	return findUsableSigningKeys(r.db, now)
+}
+
+// findUsableSigningKeys は FindUsable の本体。トランザクション上でも使えるように q を受け取る
+func findUsableSigningKeys(q sqlx.Queryer, now time.Time) ([]models.SigningKey, error) {
+	query := `
+		SELECT * FROM signing_keys
+		WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY created_at DESC
+	`
+
+	keys := []models.SigningKey{}
+	if err := sqlx.Select(q, &keys, query, now); err != nil {
+		return nil, fmt.Errorf("failed to find signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// DeleteExpired は検証にも使わなくなった署名鍵を削除し、削除した件数を返す
+func (r *SigningKeyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM signing_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return deleted, nil
+}
+
+// SigningKeyTx は WithRotationLock でロックを取ったトランザクション上で署名鍵を読み書きする
+type SigningKeyTx struct {
+	tx *sqlx.Tx
+}
+
+// FindUsable はトランザクション上で SigningKeyRepository.FindUsable と同じことを行う
+func (t *SigningKeyTx) FindUsable(now time.Time) ([]models.SigningKey, error) {
+	return findUsableSigningKeys(t.tx, now)
+}
+
+// Create は署名鍵を保存し、作成日時を key にセットする
+func (t *SigningKeyTx) Create(key *models.SigningKey) error {
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+
+	if err := t.tx.QueryRow(query, key.KID, key.Algorithm, key.PrivateKey).Scan(&key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+	return nil
+}
+
+// RetireOthers は kid 以外の署名中の鍵を署名に使わないようにし、expiresAt まで検証用に残す
+func (t *SigningKeyTx) RetireOthers(kid string, expiresAt time.Time) error {
+	query := `
+		UPDATE signing_keys
+		SET retired_at = CURRENT_TIMESTAMP, expires_at = $2
+		WHERE kid <> $1 AND retired_at IS NULL
+	`
+
+	if _, err := t.tx.Exec(query, kid, expiresAt); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+	return nil
+}
+
+// UpdatePrivateKey は署名鍵の秘密鍵を保存し直す（暗号化していない秘密鍵を暗号化するときに使う）
+func (t *SigningKeyTx) UpdatePrivateKey(kid, privateKey string) error {
+	if _, err := t.tx.Exec(`UPDATE signing_keys SET private_key = $2 WHERE kid = $1`, kid, privateKey); err != nil {
+		return fmt.Errorf("failed to update signing key: %w", err)
+	}
+	return nil
+}
+
+// WithRotationLock は署名鍵の交換用のアドバイザリーロックを取得してから fn を実行する
+// 複数のサーバープロセスが同時に新しい鍵を作らないようにするために使う
+// ロックはトランザクション単位なので、fn が終わってトランザクションを閉じると自動で解放される
+// fn の中のDB操作は引数の tx を使うこと（fn が成功したらコミットするので、鍵の作成と古い鍵の交換は同時に反映される）
+func (r *SigningKeyRepository) WithRotationLock(fn func(tx *SigningKeyTx) error) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, 0)`, signingKeyLockNamespace); err != nil {
+		return fmt.Errorf("failed to acquire signing key lock: %w", err)
+	}
+
+	if err := fn(&SigningKeyTx{tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit signing key transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenRevocationRepository はログアウトなどで失効させたJWTを記録する
+type TokenRevocationRepository struct {
+	db *sqlx.DB
+}
+
+// NewTokenRevocationRepository はDB接続を受け取ってリポジトリを生成する
+func NewTokenRevocationRepository(db *sqlx.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+// Revoke はトークンID（jti）を失効させる
+// expiresAt はトークンの有効期限で、それを過ぎたら DeleteExpired で削除される
+func (r *TokenRevocationRepository) Revoke(tokenID string, userID int, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(query, tokenID, userID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForUser はユーザーが before までに発行したトークンをすべて失効させる
+func (r *TokenRevocationRepository) RevokeAllForUser(userID int, before time.Time) error {
+	query := `
+		INSERT INTO user_token_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before)
+	`
+
+	if _, err := r.db.Exec(query, userID, before); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked はトークンが失効済みかを調べる
+// トークンID自体が失効させられているか、発行日時がユーザーの「すべてログアウト」以前なら失効済み
+func (r *TokenRevocationRepository) IsRevoked(tokenID string, userID int, issuedAt time.Time) (bool, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (
+				SELECT 1 FROM user_token_revocations
+				WHERE user_id = $2 AND revoked_before >= $3
+			)
+	`
+
+	var revoked bool
+	if err := r.db.Get(&revoked, query, tokenID, userID, issuedAt); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpired は有効期限を過ぎて記録しておく必要がなくなった失効情報を削除し、削除した件数を返す
+// revoked_tokens は now より前に期限切れになったもの、
+// user_token_revocations は issuedBefore（これより前に発行されたトークンはすべて期限切れ）より前のものを削除する
+func (r *TokenRevocationRepository) DeleteExpired(now, issuedBefore time.Time) (int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deleted int64
+	result, err := tx.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil {
+		deleted += n
+	}
+
+	result, err = tx.Exec(`DELETE FROM user_token_revocations WHERE revoked_before < $1`, issuedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired user revocations: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil {
+		deleted += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
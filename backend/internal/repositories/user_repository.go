@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"anime-score-backend/internal/models"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -72,6 +73,106 @@ func (r *UserRepository) ExistsByUsername(username string) (bool, error) {
 	return count > 0, nil
 }
 
+// GetByID: IDからユーザーを取得
+func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	var user models.User
+	query := `SELECT * FROM users WHERE id = $1`
+
+	err := r.db.Get(&user, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MarkEmailVerified: メールアドレスを確認済みにする（確認済みなら何もしない）
+func (r *UserRepository) MarkEmailVerified(id int) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND email_verified_at IS NULL`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// UpdatePassword: パスワードのハッシュを変更する
+// パスワード再設定ではトークンを使用済みにするのと同じトランザクションで変更するので、tx を受け取る
+func (r *UserRepository) UpdatePassword(tx *sqlx.Tx, id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2 WHERE id = $1`
+
+	_, err := tx.Exec(query, id, passwordHash)
+	return err
+}
+
+// ClaimVerificationMail: 確認メールを送ってよいか確認し、送信日時として sentAt を記録する
+// 確認済み、または sentBefore より後に送ったばかりの場合は何もせずに false を返す
+// 確認と記録を1つのUPDATEで行うので、同時に再送を頼まれても送るのは1通だけになる
+func (r *UserRepository) ClaimVerificationMail(id int, sentAt time.Time, sentBefore time.Time) (bool, error) {
+	query := `
+		UPDATE users
+		SET email_verification_sent_at = $2
+		WHERE id = $1
+		  AND email_verified_at IS NULL
+		  AND (email_verification_sent_at IS NULL OR email_verification_sent_at <= $3)`
+
+	result, err := r.db.Exec(query, id, sentAt, sentBefore)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleaseVerificationMail: 確認メールを送れなかったときに、ClaimVerificationMail で記録した送信日時を消す
+// 消さないと、届いていないのに ResendInterval の間は再送できなくなる
+// その後にほかのリクエストが記録し直していたら（送信日時が sentAt と違えば）何もしない
+func (r *UserRepository) ReleaseVerificationMail(id int, sentAt time.Time) error {
+	query := `
+		UPDATE users
+		SET email_verification_sent_at = NULL
+		WHERE id = $1 AND email_verification_sent_at = $2`
+
+	_, err := r.db.Exec(query, id, sentAt)
+	return err
+}
+
+// ClaimPasswordResetMail: パスワード再設定メールを送ってよいか確認し、送信日時として sentAt を記録する
+// sentBefore より後に送ったばかりの場合は何もせずに false を返す
+// 確認と記録を1つのUPDATEで行うので、同時に再設定を頼まれても送るのは1通だけになる
+func (r *UserRepository) ClaimPasswordResetMail(id int, sentAt time.Time, sentBefore time.Time) (bool, error) {
+	query := `
+		UPDATE users
+		SET password_reset_sent_at = $2
+		WHERE id = $1
+		  AND (password_reset_sent_at IS NULL OR password_reset_sent_at <= $3)`
+
+	result, err := r.db.Exec(query, id, sentAt, sentBefore)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleasePasswordResetMail: パスワード再設定メールを送れなかったときに、ClaimPasswordResetMail で記録した送信日時を消す
+// その後にほかのリクエストが記録し直していたら（送信日時が sentAt と違えば）何もしない
+func (r *UserRepository) ReleasePasswordResetMail(id int, sentAt time.Time) error {
+	query := `
+		UPDATE users
+		SET password_reset_sent_at = NULL
+		WHERE id = $1 AND password_reset_sent_at = $2`
+
+	_, err := r.db.Exec(query, id, sentAt)
+	return err
+}
+
 // sqlxの主なメソッドは以下の通り:
 // Get: 単一行を構造体にマッピング
 // Select: 複数行をスライスにマッピング
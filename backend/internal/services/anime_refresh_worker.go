@@ -0,0 +1,162 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// AnimeRefreshWorker はDBにキャッシュしたアニメ情報を定期的に取得元（Annict / AniList）から再取得するバックグラウンド処理
+// FindOrCreateAnime は一度保存したアニメをそのまま使い続けるため、
+// タイトルや画像が取得元で変わっても反映されない。それをここで補う
+type AnimeRefreshWorker struct {
+	animeService *AnimeService
+	config       models.AnimeRefreshConfig
+}
+
+// NewAnimeRefreshWorker はAnimeRefreshWorkerのインスタンスを生成
+func NewAnimeRefreshWorker(animeService *AnimeService, config models.AnimeRefreshConfig) *AnimeRefreshWorker {
+	return &AnimeRefreshWorker{
+		animeService: animeService,
+		config:       config,
+	}
+}
+
+// Run は ctx がキャンセルされるまで、一定間隔で古くなったアニメを再取得する
+// goroutine で呼び出すこと（例: go worker.Run(ctx)）
+func (w *AnimeRefreshWorker) Run(ctx context.Context) {
+	if w.config.Interval <= 0 {
+		log.Println("Anime refresh worker is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		// 起動直後に1回実行し、その後は Interval ごとに実行する
+		refreshed, err := w.RefreshStale(ctx)
+		if err != nil {
+			log.Println("Failed to refresh stale animes:", err)
+		} else if refreshed > 0 {
+			log.Printf("Refreshed %d stale animes", refreshed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshStale は古くなったアニメを取得元ごとに最大 BatchSize 件まとめて再取得し、更新できた件数を返す
+// Annictの作品はAnnictから、Annictにない作品（AniListにしかない作品など）はその取得元から再取得する
+// ある取得元で失敗してもほかの取得元の再取得は続け、エラーはまとめて返す
+func (w *AnimeRefreshWorker) RefreshStale(ctx context.Context) (int, error) {
+	staleBefore := time.Now().Add(-w.config.StaleAfter)
+
+	refreshed := 0
+	changed := false
+	var errs []error
+	for _, provider := range w.animeService.allProviders() {
+		n, providerChanged, err := w.refreshStaleFrom(ctx, provider, staleBefore)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		}
+		refreshed += n
+		changed = changed || providerChanged
+	}
+
+	// 作品情報が変わっていたら、古い情報を含む検索結果をキャッシュから返さないようにする
+	// どの検索キーワードの結果に含まれていたかは分からないので、検索結果のキャッシュをすべて削除する
+	if changed {
+		w.animeService.invalidateSearchCache()
+	}
+	return refreshed, errors.Join(errs...)
+}
+
+// refreshStaleFrom は provider から取得した古いアニメを再取得し、更新できた件数と作品情報が変わったかを返す
+// 取得元へは1件ずつではなく GetWorksByIDs でまとめて問い合わせ、
+// DBへの保存とほかの取得元との対応付けを Concurrency 件まで並行して行う
+func (w *AnimeRefreshWorker) refreshStaleFrom(ctx context.Context, provider repositories.MetadataProvider, staleBefore time.Time) (int, bool, error) {
+	animes, err := w.animeService.animeRepo.FindStale(provider.Name(), staleBefore, w.config.BatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(animes) == 0 {
+		return 0, false, nil
+	}
+
+	externalIDs := make([]int, len(animes))
+	for i, anime := range animes {
+		externalIDs[i] = anime.ExternalIDs[provider.Name()]
+	}
+
+	// 取得元の障害などで取得できなかった場合は、同期日時を更新せずに次回また試す
+	works, err := provider.GetWorksByIDs(externalIDs)
+	if err != nil {
+		return 0, false, err
+	}
+	worksByID := make(map[int]*models.MetadataWork, len(works))
+	for i := range works {
+		worksByID[works[i].ExternalID] = &works[i]
+	}
+
+	concurrency := w.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// バッファ付きチャネルをセマフォとして使い、同時実行数を制限する
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	refreshed := 0
+	changed := false
+
+	for i, anime := range animes {
+		// キャンセルされたら新しい処理は始めない
+		if ctx.Err() != nil {
+			break
+		}
+
+		externalID := externalIDs[i]
+		work, ok := worksByID[externalID]
+		if !ok {
+			// 取得元から削除された作品は、同期日時だけ更新して次の再取得まで間隔を空ける
+			log.Printf("Failed to refresh anime (%s: %d): not found", provider.Name(), externalID)
+			if err := w.animeService.animeRepo.TouchSynced(anime.ID); err != nil {
+				log.Printf("Failed to update synced_at (%s: %d): %v", provider.Name(), externalID, err)
+			}
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := w.animeService.saveRefreshedWork(work); err != nil {
+				log.Printf("Failed to refresh anime (%s: %d): %v", provider.Name(), externalID, err)
+				return
+			}
+
+			mu.Lock()
+			refreshed++
+			if animeMetadataChanged(&anime, animeFromWork(work)) {
+				changed = true
+			}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return refreshed, changed, nil
+}
@@ -3,25 +3,90 @@ package services
 import (
 	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/repositories"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/text/unicode/norm"
+)
+
+// アニメ一覧の絞り込み条件が不正な場合のエラー
+var ErrInvalidFilter = errors.New("絞り込み条件の指定が不正です")
+
+// 外部IDの取得元の指定が不正な場合のエラー
+var ErrUnknownProvider = errors.New("取得元の指定が不正です")
+
+// Annict API の呼び出しで発生するエラー
+// ハンドラーでステータスコード（502 / 503 / 404）を判別できるように、リポジトリ層のものをここでも公開する
+var (
+	ErrAnnictUnavailable = repositories.ErrAnnictUnavailable
+	ErrAnnictRateLimited = repositories.ErrAnnictRateLimited
+	ErrAnnictBadGateway  = repositories.ErrAnnictBadGateway
+	ErrAnnictRejected    = repositories.ErrAnnictRejected
+	ErrAnnictNotFound    = repositories.ErrAnnictNotFound
 )
 
+// AniList API の呼び出しで発生するエラー（AniListの作品IDでアニメを探すときに使う）
+var (
+	ErrAniListUnavailable   = repositories.ErrAniListUnavailable
+	ErrAniListRequestFailed = repositories.ErrAniListRequestFailed
+	ErrAniListNotFound      = repositories.ErrAniListNotFound
+)
+
+// AnnictError は Annict API の呼び出しに失敗したときのエラー（Retry-After などを持つ）
+type AnnictError = repositories.AnnictError
+
 type AnimeService struct {
-	annictRepo *repositories.AnnictRepository
-	animeRepo  *repositories.AnimeRepository
+	// アニメ情報の取得元
+	// DBのアニメやURLは Annict ID で識別しているので、現状は Annict の実装を渡す
+	provider repositories.MetadataProvider
+	// 作品を対応付けるほかの取得元（AniListなど）。対応は anime_external_ids に保存する
+	crossProviders []repositories.MetadataProvider
+	animeRepo      *repositories.AnimeRepository
+	ranking        models.RankingOptions // ランキングのデフォルト設定
+	// 同じアニメを取得元から取得・保存する処理が同時に走ったときに1回にまとめる（取得元・作品IDごと。1件ずつの取得とまとめての取得で共通）
+	inflight singleflight.Group
+	// ほかの取得元との対応付けを待っているアニメ（RunExternalIDLinker が1件ずつ処理する）
+	linkQueue chan models.Anime
 }
 
+// externalIDLinkQueueSize は対応付けを待てるアニメの最大件数
+// あふれた分は捨てるが、バックグラウンドの再取得のときにまた対応付けを試みる
+const externalIDLinkQueueSize = 1000
+
 // NewAnimeService はAnimeServiceのインスタンスを生成
-// 依存関係（ここではAnnictRepository）を注入
-func NewAnimeService(annictRepo *repositories.AnnictRepository, animeRepo *repositories.AnimeRepository) *AnimeService {
+// 依存関係（ここではメタデータの取得元とAnimeRepository）を注入
+// crossProviders は作品を対応付けるほかの取得元（不要なら nil）
+// ranking はアニメ一覧のデフォルトの並び順と重み付けスコアのパラメータ
+func NewAnimeService(
+	provider repositories.MetadataProvider,
+	crossProviders []repositories.MetadataProvider,
+	animeRepo *repositories.AnimeRepository,
+	ranking models.RankingOptions,
+) *AnimeService {
 	return &AnimeService{
-		annictRepo: annictRepo,
-		animeRepo:  animeRepo,
+		provider:       provider,
+		crossProviders: crossProviders,
+		animeRepo:      animeRepo,
+		ranking:        ranking,
+		linkQueue:      make(chan models.Anime, externalIDLinkQueueSize),
 	}
 }
 
 // SearchAnimes はキーワードに基づいてアニメを検索
-// 将来的にはここで「DB検索」と「外部API検索」を併用するロジックに拡張予定
-func (s *AnimeService) SearchAnimes(keyword string, limit int, cursor string) ([]models.AnnictWork, string, error) {
+// まずDBに保存済みのアニメ（読み仮名・英語タイトルやカナの揺れも考慮）から探し、
+// limit件に足りない分だけ Annict API の検索結果で補う
+// cursor には前回の検索結果の nextCursor を渡す（初回は空文字）
+// 各結果にはDB上のレビュー数・平均点と、userID のユーザー自身のスコアを付ける（未ログインなら userID は0）
+func (s *AnimeService) SearchAnimes(keyword string, limit int, cursor string, userID int64) ([]models.AnimeSearchResult, string, error) {
 	// 1. バリデーション（安全対策）
 	// 極端に大きなリクエストが来ないように制限をかける
 	if limit <= 0 {
@@ -31,22 +96,205 @@ func (s *AnimeService) SearchAnimes(keyword string, limit int, cursor string) ([
 		limit = 50 // 上限値
 	}
 
-	// 2. Annict APIへの問い合わせ
-	// Repository層を呼び出してデータを取得
-	works, nextCursor, err := s.annictRepo.SearchWorks(keyword, limit, cursor)
+	page, err := decodeSearchCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 0件の場合も null ではなく空配列を返す
+	results := []models.AnimeSearchResult{}
+
+	// 空白や中黒だけのキーワードは正規化すると空になり、DBではすべてのアニメに一致してしまうので検索しない
+	if normalizeSearchKeyword(keyword) == "" {
+		return results, "", nil
+	}
+
+	// 2. DBの検索
+	// DBの検索結果を返し終えていなければ、続きから取得する
+	if !page.Annict {
+		// 1件多く取得して、DBにまだ続きがあるかを判定する
+		animes, err := s.animeRepo.Search(keyword, limit+1, page.Offset)
+		if err != nil {
+			return nil, "", err
+		}
+
+		hasMore := len(animes) > limit
+		if hasMore {
+			animes = animes[:limit]
+		}
+		for _, anime := range animes {
+			results = append(results, searchResultFromLocal(anime))
+		}
+
+		if hasMore {
+			return s.annotateSearchResults(results, userID, encodeSearchCursor(models.AnimeSearchCursor{Offset: page.Offset + limit}))
+		}
+		// ちょうどlimit件でDBの結果を返し切った場合は、次のページからAnnictを検索する
+		if len(results) == limit {
+			return s.annotateSearchResults(results, userID, encodeSearchCursor(models.AnimeSearchCursor{Annict: true}))
+		}
+	}
+
+	// 3. 足りない分をAnnict APIに問い合わせる
+	works, annictCursor, err := s.provider.SearchWorks(keyword, limit-len(results), page.AnnictCursor)
 	if err != nil {
+		// DBの検索結果があれば、Annictに失敗してもそれだけを返す
+		if len(results) > 0 {
+			log.Println("Failed to search Annict, returning local results only:", err)
+			return s.annotateSearchResults(results, userID, "")
+		}
 		return nil, "", err
 	}
 
-	// 3. 必要に応じたデータ加工（あればここに記述）
-	// 現状は取得したデータをそのまま返しているが、
-	// 将来的には「画像がない場合はデフォルト画像を入れる」などの処理をここに書く
+	// DBの検索にも一致した作品は、DBの検索結果として返しているので除く
+	annictIDs := make([]int, len(works))
+	for i, work := range works {
+		annictIDs[i] = work.ExternalID
+	}
+	matches, err := s.animeRepo.FindSearchMatches(keyword, annictIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, work := range works {
+		if matches[work.ExternalID] {
+			continue
+		}
+		results = append(results, models.AnimeSearchResult{
+			AnnictWork: annictWorkFromMetadata(work),
+			Source:     models.AnimeSearchSourceAnnict,
+		})
+	}
+
+	nextCursor := ""
+	if annictCursor != "" {
+		nextCursor = encodeSearchCursor(models.AnimeSearchCursor{Annict: true, AnnictCursor: annictCursor})
+	}
+
+	return s.annotateSearchResults(results, userID, nextCursor)
+}
+
+// annotateSearchResults は検索結果にレビュー数・平均点・自分のスコアを付けて、nextCursor と一緒に返す
+// Annictの検索結果にもDBに保存済み（レビューがある）作品が含まれるので、取得元にかかわらずまとめて調べる
+func (s *AnimeService) annotateSearchResults(results []models.AnimeSearchResult, userID int64, nextCursor string) ([]models.AnimeSearchResult, string, error) {
+	annictIDs := make([]int, len(results))
+	for i, result := range results {
+		annictIDs[i] = result.AnnictID
+	}
+
+	summaries, err := s.animeRepo.FindReviewSummaries(annictIDs, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range results {
+		summary, ok := summaries[results[i].AnnictID]
+		if !ok {
+			continue
+		}
+		results[i].ReviewCount = summary.ReviewCount
+		results[i].AvgScore = summary.AvgScore
+		results[i].MyScore = summary.MyScore
+	}
+
+	return results, nextCursor, nil
+}
+
+// normalizeSearchKeyword はキーワードをDBの anime_search_text 関数と同じように正規化する
+// NFKC でそろえ（全角空白・半角中黒も半角空白・中黒になる）、空白と中黒を取り除く
+// 小文字化やカタカナ→ひらがなは空かどうかの判定には関係ないので省く
+func normalizeSearchKeyword(keyword string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '・' {
+			return -1
+		}
+		return r
+	}, norm.NFKC.String(keyword))
+}
+
+// encodeSearchCursor は検索の続きの位置をクライアントに返すカーソル文字列にする
+func encodeSearchCursor(cursor models.AnimeSearchCursor) string {
+	// 数値・真偽値・文字列だけの構造体なのでMarshalは失敗しない
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeSearchCursor はカーソル文字列を復元する（空文字なら最初から）
+func decodeSearchCursor(cursor string) (models.AnimeSearchCursor, error) {
+	var decoded models.AnimeSearchCursor
+	if cursor == "" {
+		return decoded, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return decoded, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded.Offset < 0 {
+		return decoded, ErrInvalidCursor
+	}
+
+	return decoded, nil
+}
+
+// annictWorkFromMetadata は取得元の作品情報を検索結果の形（AnnictWorkと同じJSON）に変換する
+func annictWorkFromMetadata(work models.MetadataWork) models.AnnictWork {
+	annictWork := models.AnnictWork{
+		AnnictID:        work.ExternalID,
+		Title:           work.Title,
+		TitleKana:       work.TitleKana,
+		TitleEn:         work.TitleEn,
+		SeasonYear:      work.SeasonYear,
+		SeasonName:      work.SeasonName,
+		Media:           work.Media,
+		EpisodesCount:   work.EpisodesCount,
+		OfficialSiteUrl: work.OfficialSiteURL,
+		TwitterUsername: work.TwitterUsername,
+		WikipediaUrl:    work.WikipediaURL,
+	}
+	annictWork.Image.RecommendedImageUrl = work.ImageURL
+	return annictWork
+}
+
+// searchResultFromLocal はDBに保存済みのアニメを検索結果の形に変換する
+// Annictの検索結果と同じ形で返せるように、DB保存時の変換（animeFromWork）と逆の変換をする
+func searchResultFromLocal(anime models.AnimeWithStats) models.AnimeSearchResult {
+	// Search はAnnictにある作品（annict_id がNULLでないもの）だけを返す
+	work := models.AnnictWork{
+		AnnictID:        int(*anime.AnnictID),
+		Title:           anime.Title,
+		TitleKana:       valueOrEmpty(anime.TitleKana),
+		TitleEn:         valueOrEmpty(anime.TitleEn),
+		Media:           strings.ToUpper(valueOrEmpty(anime.Media)),
+		EpisodesCount:   anime.EpisodesCount,
+		OfficialSiteUrl: valueOrEmpty(anime.OfficialSiteURL),
+		TwitterUsername: valueOrEmpty(anime.TwitterUsername),
+		WikipediaUrl:    valueOrEmpty(anime.WikipediaURL),
+	}
+	// 放送年が不明な作品は0で保存しているのでnullに戻す
+	if anime.Year != 0 {
+		year := anime.Year
+		work.SeasonYear = &year
+	}
+	if anime.Season != nil {
+		name := strings.ToUpper(*anime.Season)
+		work.SeasonName = &name
+	}
+	work.Image.RecommendedImageUrl = valueOrEmpty(anime.ImageURL)
 
-	return works, nextCursor, nil
+	return models.AnimeSearchResult{
+		AnnictWork:  work,
+		Source:      models.AnimeSearchSourceLocal,
+		ReviewCount: anime.ReviewCount,
+		AvgScore:    anime.AvgScore,
+	}
 }
 
 // FindOrCreateAnime は指定されたAnnict IDのアニメを取得します。
 // DBに存在しない場合はAnnict APIから取得してDBに保存します。
+// 話題になったばかりのアニメに同時にアクセスが来ても、Annictへの問い合わせは1回で済むようにしている
+//   - 同じプロセス内の同時リクエストは singleflight で1回にまとめる
+//   - 複数のサーバープロセス間ではDBのアドバイザリーロックで順番に処理させ、
+//     ロックを取れたら先にDBを確認する（他のプロセスが保存済みならAnnictには問い合わせない）
 func (s *AnimeService) FindOrCreateAnime(annictID int) (*models.Anime, error) {
 	// 1. まず自分のDBを探す (キャッシュチェック)
 	localAnime, err := s.animeRepo.FindByAnnictID(annictID)
@@ -58,40 +306,364 @@ func (s *AnimeService) FindOrCreateAnime(annictID int) (*models.Anime, error) {
 		return localAnime, nil
 	}
 
-	// 2. DBになければ、Annict APIから情報を取得
-	annictWork, err := s.annictRepo.GetWorkByID(annictID)
+	// 2. DBになければ、Annict APIから取得して保存する
+	return s.createAnimeOnce(s.provider, annictID)
+}
+
+// createAnimeOnce は取得元から作品を取得してDBに保存する
+// 同じ取得元・作品IDの処理が実行中なら、新しく始めずにその結果を待つ
+func (s *AnimeService) createAnimeOnce(provider repositories.MetadataProvider, externalID int) (*models.Anime, error) {
+	return s.saveWorkOnce(provider.Name(), externalID, func() (*models.MetadataWork, error) {
+		return provider.GetWorkByID(externalID)
+	})
+}
+
+// saveWorkOnce は fetch で取得元から取得した作品をDBに保存する
+// 1件ずつの取得（createAnimeOnce）とまとめての取得（FindOrCreateAnimes）で同じキーを使うので、
+// どちらの経路でも、同じ取得元・作品IDの処理が実行中なら新しく始めずにその結果を待つ
+func (s *AnimeService) saveWorkOnce(provider string, externalID int, fetch func() (*models.MetadataWork, error)) (*models.Anime, error) {
+	v, err, _ := s.inflight.Do(provider+":"+strconv.Itoa(externalID), func() (interface{}, error) {
+		return s.createAnime(provider, externalID, fetch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 結果は同時に待っていた全員で共有されるので、呼び出し側で書き換えても影響しないようにコピーして返す
+	anime := *v.(*models.Anime)
+	return &anime, nil
+}
+
+// createAnime は取得元（Annict / AniList）から作品を取得してDBに保存する
+// 取得元への問い合わせはロックの外で行い、応答を待つ間はDBの接続もロックも使わない
+// 保存は取得元の作品IDごとのアドバイザリーロックの中で、もう一度DBを確認してから行う
+// （他のプロセスが同時に取得していても、保存されるのは1件だけ）
+func (s *AnimeService) createAnime(provider string, externalID int, fetch func() (*models.MetadataWork, error)) (*models.Anime, error) {
+	// 1. 取得元のAPIから情報を取得
+	work, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. ロックを取ってから保存する
+	var anime *models.Anime
+	created := false
+	err = s.animeRepo.WithExternalIDLock(provider, externalID, func(tx *repositories.AnimeTx) error {
+		// 取得している間・ロックを待っている間に他のプロセスが保存しているかもしれないので、もう一度DBを確認する
+		localAnime, err := tx.FindByExternalID(provider, externalID)
+		if err != nil {
+			return err
+		}
+		if localAnime != nil {
+			anime = localAnime
+			return nil
+		}
+
+		// 取得した情報をDB保存用のモデルに変換して保存する
+		// Createメソッド内でIDが採番され、anime.IDにセットされます
+		anime = animeFromWork(work)
+		created = true
+		return tx.Create(anime, work.Provider, work.ExternalID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// 3. 取得した情報をDB保存用のモデルに変換
-	// 画像URLが空なら nil を入れる
-	var imageURL *string
-	if annictWork.Image.RecommendedImageUrl != "" {
-		url := annictWork.Image.RecommendedImageUrl
-		imageURL = &url
+	// ほかの取得元との対応付けは時間がかかるので、レスポンスを待たせないようにバックグラウンドで行う
+	if created {
+		s.linkExternalIDsInBackground([]*models.Anime{anime})
+	}
+
+	return anime, nil
+}
+
+// FindOrCreateAnimes は複数のアニメをまとめてDBから探し、DBにないものはAnnict APIからまとめて取得して保存する
+// FindOrCreateAnime を1件ずつ呼ぶとAnnictへのリクエストがアニメの数だけ発生するので、
+// 視聴履歴の取り込みなど多数のアニメを扱う場合はこちらを使う（Annictには BatchSize 件ずつ問い合わせる）
+// 保存は FindOrCreateAnime と同じく1件ずつロックの中で行い、同じアニメを取得中の処理があればその結果を使う
+// 戻り値は Annict ID をキーにしたマップで、Annictに存在しないアニメは含まれない
+func (s *AnimeService) FindOrCreateAnimes(annictIDs []int) (map[int]*models.Anime, error) {
+	// 1. まずDBをまとめて探す
+	localAnimes, err := s.animeRepo.FindByAnnictIDs(annictIDs)
+	if err != nil {
+		return nil, err
 	}
 
+	animes := make(map[int]*models.Anime, len(annictIDs))
+	var missing []int
+	for _, annictID := range annictIDs {
+		if _, ok := animes[annictID]; ok {
+			continue
+		}
+		if anime, ok := localAnimes[annictID]; ok {
+			animes[annictID] = &anime
+			continue
+		}
+		if !slices.Contains(missing, annictID) {
+			missing = append(missing, annictID)
+		}
+	}
+	if len(missing) == 0 {
+		return animes, nil
+	}
+
+	// 2. DBになかったものはAnnict APIからまとめて取得する（最初に必要になったときに1回だけ）
+	fetchAll := sync.OnceValues(func() (map[int]*models.MetadataWork, error) {
+		works, err := s.provider.GetWorksByIDs(missing)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int]*models.MetadataWork, len(works))
+		for i := range works {
+			byID[works[i].ExternalID] = &works[i]
+		}
+		return byID, nil
+	})
+
+	// 3. 1件ずつロックの中で保存する
+	// 同じアニメを1件ずつ取得中の処理があれば、まとめて取得した結果ではなくその結果を待つ
+	// （1件ずつ順番に保存するので、同時に使うDBの接続は1本だけ）
+	for _, annictID := range missing {
+		anime, err := s.saveWorkOnce(s.provider.Name(), annictID, func() (*models.MetadataWork, error) {
+			works, err := fetchAll()
+			if err != nil {
+				return nil, err
+			}
+			work, ok := works[annictID]
+			if !ok {
+				return nil, fmt.Errorf("%w (annictId: %d)", ErrAnnictNotFound, annictID)
+			}
+			return work, nil
+		})
+		if errors.Is(err, ErrAnnictNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		animes[annictID] = anime
+	}
+
+	return animes, nil
+}
+
+// linkExternalIDsInBackground はアニメとほかの取得元の対応付けを RunExternalIDLinker に任せる
+// 待ち行列がいっぱいなら諦める（バックグラウンドの再取得のときにまた対応付けを試みる）
+func (s *AnimeService) linkExternalIDsInBackground(animes []*models.Anime) {
+	if len(s.crossProviders) == 0 {
+		return
+	}
+
+	for _, anime := range animes {
+		// 呼び出し側で書き換えても影響しないようにコピーを渡す
+		select {
+		case s.linkQueue <- *anime:
+		default:
+			log.Printf("External id link queue is full, skipping (animeId: %d)", anime.ID)
+		}
+	}
+}
+
+// RunExternalIDLinker は ctx がキャンセルされるまで、対応付けを待っているアニメを1件ずつほかの取得元と対応付ける
+// 取得元へのリクエストが一度に集中しないよう、1つのgoroutineで順番に処理する
+// goroutine で呼び出すこと（例: go animeService.RunExternalIDLinker(ctx)）
+func (s *AnimeService) RunExternalIDLinker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case anime := <-s.linkQueue:
+			if err := s.LinkExternalIDs(&anime); err != nil {
+				log.Printf("Failed to link external ids (animeId: %d): %v", anime.ID, err)
+			}
+		}
+	}
+}
+
+// saveRefreshedWork は再取得した作品情報でDBのアニメを更新し、ほかの取得元との対応付けを試みる
+func (s *AnimeService) saveRefreshedWork(work *models.MetadataWork) error {
+	// Createは作品が既にあれば全項目を更新する（Upsert。内容が変わったときだけ updated_at を進める）
+	refreshed := animeFromWork(work)
+	if err := s.animeRepo.Create(refreshed, work.Provider, work.ExternalID); err != nil {
+		return err
+	}
+
+	// 対応付けに失敗しても再取得自体は成功しているので、ログだけ出す
+	if err := s.LinkExternalIDs(refreshed); err != nil {
+		log.Printf("Failed to link external ids (%s: %d): %v", work.Provider, work.ExternalID, err)
+	}
+	return nil
+}
+
+// invalidateSearchCache は取得元が検索結果をキャッシュしていれば、すべて削除する
+func (s *AnimeService) invalidateSearchCache() {
+	if invalidator, ok := s.provider.(repositories.SearchCacheInvalidator); ok {
+		if deleted := invalidator.InvalidateSearchCache(""); deleted > 0 {
+			log.Printf("Invalidated %d cached search results", deleted)
+		}
+	}
+}
+
+// animeMetadataChanged は取得元から再取得した作品情報が、DBに保存済みの内容と違うかを返す
+func animeMetadataChanged(stored, refreshed *models.Anime) bool {
+	return stored.Title != refreshed.Title ||
+		valueOrEmpty(stored.TitleKana) != valueOrEmpty(refreshed.TitleKana) ||
+		valueOrEmpty(stored.TitleEn) != valueOrEmpty(refreshed.TitleEn) ||
+		stored.Year != refreshed.Year ||
+		valueOrEmpty(stored.Season) != valueOrEmpty(refreshed.Season) ||
+		valueOrEmpty(stored.Media) != valueOrEmpty(refreshed.Media) ||
+		stored.EpisodesCount != refreshed.EpisodesCount ||
+		valueOrEmpty(stored.ImageURL) != valueOrEmpty(refreshed.ImageURL) ||
+		valueOrEmpty(stored.OfficialSiteURL) != valueOrEmpty(refreshed.OfficialSiteURL) ||
+		valueOrEmpty(stored.TwitterUsername) != valueOrEmpty(refreshed.TwitterUsername) ||
+		valueOrEmpty(stored.WikipediaURL) != valueOrEmpty(refreshed.WikipediaURL)
+}
+
+// LinkExternalIDs はアニメをほかの取得元の作品と対応付け、anime_external_ids に保存する
+// まだ対応付けていない取得元（AniListにしかなかった作品ならAnnictも）でタイトル検索し、
+// 放送年とタイトルが一致する作品を同じ作品とみなす
+// 一致する作品が見つからなければ何もしない（次の再取得のときにまた試す）
+// 一致する作品が別のアニメとして保存済みなら、2件を1件にまとめる（MergeAnimes）
+func (s *AnimeService) LinkExternalIDs(anime *models.Anime) error {
+	if len(s.crossProviders) == 0 {
+		return nil
+	}
+
+	linked, err := s.animeRepo.FindExternalIDs(anime.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range s.allProviders() {
+		if _, ok := linked[provider.Name()]; ok {
+			continue
+		}
+
+		works, _, err := provider.SearchWorks(anime.Title, 5, "")
+		if err != nil {
+			return fmt.Errorf("failed to search %s: %w", provider.Name(), err)
+		}
+
+		match := findMatchingWork(anime, works)
+		if match == nil {
+			continue
+		}
+
+		// 同じ作品が別のアニメとして保存済みなら、対応付ける代わりに1件にまとめる
+		// （AniListから先に保存した作品を、後からAnnictのIDで取得した場合など）
+		owner, err := s.animeRepo.FindByExternalID(provider.Name(), match.ExternalID)
+		if err != nil {
+			return err
+		}
+		if owner != nil && owner.ID != anime.ID {
+			keptID, err := s.animeRepo.MergeAnimes(anime.ID, owner.ID)
+			if errors.Is(err, repositories.ErrAnimeMergeConflict) {
+				// 同じ取得元の別の作品に対応付いているので、タイトルが同じだけの別の作品とみなす
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			log.Printf("Merged duplicate animes (%s: %d, kept animeId: %d)", provider.Name(), match.ExternalID, keptID)
+
+			// 残りの取得元は、まとめた後のアニメで対応付ける
+			anime.ID = keptID
+			if linked, err = s.animeRepo.FindExternalIDs(keptID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.animeRepo.SaveExternalID(anime.ID, provider.Name(), match.ExternalID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findMatchingWork は検索結果の中から anime と同じ作品を探す
+// 放送年が一致し、日本語タイトルか英語タイトルが一致するものを同じ作品とみなす（見つからなければnil）
+func findMatchingWork(anime *models.Anime, works []models.MetadataWork) *models.MetadataWork {
+	for i, work := range works {
+		// 放送年が分かっているのに一致しなければ別の作品（続編・リメイクなど）
+		if anime.Year != 0 && (work.SeasonYear == nil || *work.SeasonYear != anime.Year) {
+			continue
+		}
+		if work.Title == anime.Title {
+			return &works[i]
+		}
+		if anime.TitleEn != nil && work.TitleEn != "" && strings.EqualFold(work.TitleEn, *anime.TitleEn) {
+			return &works[i]
+		}
+	}
+	return nil
+}
+
+// animeFromWork は取得元の作品情報をDB保存用のモデルに変換する
+// 取得元では未設定の項目が空文字で返ってくるので、DBにはNULLとして保存する
+func animeFromWork(work *models.MetadataWork) *models.Anime {
 	// SeasonYearはポインタなのでnilチェックを行う（nilなら0を入れる）
 	year := 0
-	if annictWork.SeasonYear != nil {
-		year = *annictWork.SeasonYear
+	if work.SeasonYear != nil {
+		year = *work.SeasonYear
 	}
 
-	newAnime := &models.Anime{
-		AnnictID: int64(annictWork.AnnictID),
-		Title:    annictWork.Title,
-		Year:     year,
-		ImageURL: imageURL,
+	// SeasonNameは "SPRING" のような大文字なので小文字にそろえる
+	var season *string
+	if work.SeasonName != nil {
+		name := strings.ToLower(*work.SeasonName)
+		if models.ValidSeason(name) {
+			season = &name
+		}
 	}
 
-	// 4. DBに保存
-	// Createメソッド内でIDが採番され、newAnime.IDにセットされます
-	if err := s.animeRepo.Create(newAnime); err != nil {
-		return nil, err
+	// Mediaも "TV" のような大文字なので小文字にそろえる
+	media := strings.ToLower(work.Media)
+
+	// Annict ID はAnnictの作品のときだけ入れる（ほかの取得元の作品IDは anime_external_ids に保存する）
+	var annictID *int64
+	if work.Provider == models.MetadataProviderAnnict {
+		id := int64(work.ExternalID)
+		annictID = &id
+	}
+
+	return &models.Anime{
+		AnnictID:        annictID,
+		Title:           work.Title,
+		TitleKana:       nullIfEmpty(work.TitleKana),
+		TitleEn:         nullIfEmpty(work.TitleEn),
+		Year:            year,
+		Season:          season,
+		Media:           nullIfEmpty(media),
+		EpisodesCount:   work.EpisodesCount,
+		ImageURL:        nullIfEmpty(work.ImageURL), // 画像URLが空なら nil を入れる
+		OfficialSiteURL: nullIfEmpty(work.OfficialSiteURL),
+		TwitterUsername: nullIfEmpty(work.TwitterUsername),
+		WikipediaURL:    nullIfEmpty(work.WikipediaURL),
+	}
+}
+
+// nullIfEmpty は空文字ならnil、それ以外なら文字列へのポインタを返す
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
 	}
+	return &s
+}
 
-	return newAnime, nil
+// valueOrEmpty はnilなら空文字、それ以外なら文字列の値を返す（nullIfEmptyの逆）
+func valueOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// FindLocalAnime はDBに保存済みのアニメだけを探す（Annict APIには問い合わせない）
+// 見つからない場合は nil を返す
+func (s *AnimeService) FindLocalAnime(annictID int) (*models.Anime, error) {
+	return s.animeRepo.FindByAnnictID(annictID)
 }
 
 // アニメ情報と統計情報を取得する関数
@@ -109,11 +681,57 @@ func (s *AnimeService) GetAnimeDetail(annictID int64) (*models.Anime, *models.An
 		return nil, nil, err
 	}
 
+	// 3. ほかの取得元での作品ID
+	anime.ExternalIDs, err = s.animeRepo.FindExternalIDs(anime.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return anime, stats, nil
 }
 
-// GetAnimeList はアニメ一覧を取得する（平均点順）
-func (s *AnimeService) GetAnimeList(page, pageSize int) (*models.AnimeListResponse, error) {
+// FindAnimeByExternalID は取得元（annict / anilist）での作品IDからアニメを取得する
+// FindOrCreateAnime と同じく、DBになければその取得元から取得して保存する
+// （AniListにしかない作品は annict_id がnullのまま保存し、Annictとの対応付けはバックグラウンドで試みる）
+func (s *AnimeService) FindAnimeByExternalID(provider string, externalID int) (*models.Anime, error) {
+	var source repositories.MetadataProvider
+	for _, p := range s.allProviders() {
+		if p.Name() == provider {
+			source = p
+		}
+	}
+	if source == nil {
+		return nil, ErrUnknownProvider
+	}
+
+	anime, err := s.animeRepo.FindByExternalID(provider, externalID)
+	if err != nil {
+		return nil, err
+	}
+	if anime == nil {
+		anime, err = s.createAnimeOnce(source, externalID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	anime.ExternalIDs, err = s.animeRepo.FindExternalIDs(anime.ID)
+	if err != nil {
+		return nil, err
+	}
+	return anime, nil
+}
+
+// allProviders はメインの取得元と、対応付けに使っているほかの取得元をまとめて返す
+func (s *AnimeService) allProviders() []repositories.MetadataProvider {
+	return append([]repositories.MetadataProvider{s.provider}, s.crossProviders...)
+}
+
+// GetAnimeList はアニメ一覧を取得する
+// sort は average(平均点順), bayesian(ベイズ平均順), wilson(Wilsonスコア順) のいずれか
+// 空文字の場合はデフォルトの並び順を使う
+// filter で放送年・シーズン・年の範囲を絞り込める（「2024年春アニメ」のランキングなど）
+func (s *AnimeService) GetAnimeList(page, pageSize int, sort string, filter models.AnimeListFilter) (*models.AnimeListResponse, error) {
 	// バリデーション
 	if page < 1 {
 		page = 1
@@ -125,11 +743,28 @@ func (s *AnimeService) GetAnimeList(page, pageSize int) (*models.AnimeListRespon
 		pageSize = 50 // 上限
 	}
 
+	ranking := s.ranking
+	if sort != "" {
+		ranking.Sort = sort
+	}
+	switch ranking.Sort {
+	case models.AnimeSortAverage, models.AnimeSortBayesian, models.AnimeSortWilson:
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	if filter.Season != nil && !models.ValidSeason(*filter.Season) {
+		return nil, ErrInvalidFilter
+	}
+	if filter.YearFrom != nil && filter.YearTo != nil && *filter.YearFrom > *filter.YearTo {
+		return nil, ErrInvalidFilter
+	}
+
 	// offset計算
 	offset := (page - 1) * pageSize
 
 	// Repository呼び出し
-	animes, total, err := s.animeRepo.FindAllWithStats(pageSize, offset)
+	animes, total, err := s.animeRepo.FindAllWithStats(pageSize, offset, ranking, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeProvider は works の作品を返す repositories.MetadataProvider
+// 検索はタイトルの完全一致で、作品を取得した回数を fetches に数える
+// gate を設定すると、作品の取得は gate が閉じられるまで待つ（取得元の応答が遅い場合）
+type fakeProvider struct {
+	name    string
+	works   []models.MetadataWork
+	fetches atomic.Int32
+	gate    chan struct{}
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) SearchWorks(keyword string, limit int, afterCursor string) ([]models.MetadataWork, string, error) {
+	var works []models.MetadataWork
+	for _, work := range p.works {
+		if work.Title == keyword {
+			works = append(works, work)
+		}
+	}
+	return works, "", nil
+}
+
+func (p *fakeProvider) GetWorkByID(id int) (*models.MetadataWork, error) {
+	works, err := p.GetWorksByIDs([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(works) == 0 {
+		return nil, fmt.Errorf("%w (id: %d)", ErrAnnictNotFound, id)
+	}
+	return &works[0], nil
+}
+
+func (p *fakeProvider) GetWorksByIDs(ids []int) ([]models.MetadataWork, error) {
+	p.fetches.Add(1)
+	if p.gate != nil {
+		<-p.gate
+	}
+	var works []models.MetadataWork
+	for _, work := range p.works {
+		for _, id := range ids {
+			if work.ExternalID == id {
+				works = append(works, work)
+			}
+		}
+	}
+	return works, nil
+}
+
+// newTestWork は provider の作品を作る（作品IDはほかのテストと重ならないように時刻から決める）
+func newTestWork(provider, title string) models.MetadataWork {
+	year := 2026
+	return models.MetadataWork{
+		Provider:   provider,
+		ExternalID: int(time.Now().UnixNano() % 1_000_000_000),
+		Title:      title,
+		SeasonYear: &year,
+		Media:      "TV",
+	}
+}
+
+// cleanupTestAnime はテストの終わりに、取得元の作品IDに対応付いたアニメを削除する
+func cleanupTestAnime(t *testing.T, db *sqlx.DB, work models.MetadataWork) {
+	t.Helper()
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM animes WHERE id IN (
+			SELECT anime_id FROM anime_external_ids WHERE provider = $1 AND external_id = $2
+		)`, work.Provider, work.ExternalID)
+	})
+}
+
+func TestLinkExternalIDsMergesDuplicateAnime(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewAnimeRepository(db)
+	title := fmt.Sprintf("重複テスト %d", time.Now().UnixNano())
+	annictWork := newTestWork(models.MetadataProviderAnnict, title)
+	aniListWork := newTestWork(models.MetadataProviderAniList, title)
+	cleanupTestAnime(t, db, annictWork)
+	cleanupTestAnime(t, db, aniListWork)
+
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{annictWork}}
+	aniList := &fakeProvider{name: models.MetadataProviderAniList, works: []models.MetadataWork{aniListWork}}
+	s := NewAnimeService(annict, []repositories.MetadataProvider{aniList}, repo, models.RankingOptions{})
+
+	// AniListから先に保存した作品を、後からAnnictのIDで取得すると別々のアニメになる
+	fromAniList, err := s.FindAnimeByExternalID(models.MetadataProviderAniList, aniListWork.ExternalID)
+	if err != nil {
+		t.Fatalf("FindAnimeByExternalID() error = %v", err)
+	}
+	fromAnnict, err := s.FindOrCreateAnime(annictWork.ExternalID)
+	if err != nil {
+		t.Fatalf("FindOrCreateAnime() error = %v", err)
+	}
+	if fromAniList.ID == fromAnnict.ID {
+		t.Fatal("animes should be saved separately before linking")
+	}
+
+	user := createTestUser(t, db, "hash")
+	if _, err := db.Exec(`INSERT INTO reviews (user_id, anime_id, score) VALUES ($1, $2, 80)`, user.ID, fromAniList.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 対応付けでは、Annictの作品IDがある方に1件にまとめる
+	if err := s.LinkExternalIDs(fromAniList); err != nil {
+		t.Fatalf("LinkExternalIDs() error = %v", err)
+	}
+	merged, err := repo.FindByExternalID(models.MetadataProviderAniList, aniListWork.ExternalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged == nil || merged.ID != fromAnnict.ID {
+		t.Fatalf("anilist work is linked to %+v, want anime %d", merged, fromAnnict.ID)
+	}
+	var remaining, reviews int
+	if err := db.Get(&remaining, `SELECT COUNT(*) FROM animes WHERE id = $1`, fromAniList.ID); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Error("duplicate anime should be deleted")
+	}
+	if err := db.Get(&reviews, `SELECT COUNT(*) FROM reviews WHERE anime_id = $1 AND user_id = $2`, fromAnnict.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reviews != 1 {
+		t.Errorf("reviews moved = %d, want 1", reviews)
+	}
+
+	// まとめた後は対応付け済みなので、もう一度実行しても何もしない
+	if err := s.LinkExternalIDs(fromAnnict); err != nil {
+		t.Errorf("LinkExternalIDs() after merge error = %v", err)
+	}
+}
+
+func TestCreateAnimeFetchesOutsideLockAndCoalesces(t *testing.T) {
+	db := openTestDB(t)
+	work := newTestWork(models.MetadataProviderAnnict, "取得テスト")
+	cleanupTestAnime(t, db, work)
+
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{work}, gate: make(chan struct{})}
+	s := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+
+	var wg sync.WaitGroup
+	var single *models.Anime
+	var batch map[int]*models.Anime
+	var singleErr, batchErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		single, singleErr = s.FindOrCreateAnime(work.ExternalID)
+	}()
+	for annict.fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 取得元の応答を待っている間は、作品IDのロックを取っていない
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locked bool
+	err = tx.Get(&locked, `SELECT pg_try_advisory_xact_lock(hashtext($1), $2)`, work.Provider, work.ExternalID)
+	tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked {
+		t.Error("lock should not be held while fetching from the provider")
+	}
+
+	// まとめての取得も、取得中の同じアニメはその結果を待つ（取得元には問い合わせない）
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		batch, batchErr = s.FindOrCreateAnimes([]int{work.ExternalID})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(annict.gate)
+	wg.Wait()
+
+	if singleErr != nil || batchErr != nil {
+		t.Fatalf("FindOrCreateAnime() error = %v, FindOrCreateAnimes() error = %v", singleErr, batchErr)
+	}
+	if got := annict.fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+	if anime, ok := batch[work.ExternalID]; !ok || anime.ID != single.ID {
+		t.Errorf("FindOrCreateAnimes() = %+v, want anime %d", batch, single.ID)
+	}
+}
+
+func TestSearchAnimesIgnoresBlankKeyword(t *testing.T) {
+	annict := &fakeProvider{name: models.MetadataProviderAnnict}
+	// DBも取得元も使わずに返すので、リポジトリは nil でよい
+	s := NewAnimeService(annict, nil, nil, models.RankingOptions{})
+
+	for _, keyword := range []string{" ", "　　", "・", "･ ・　"} {
+		results, next, err := s.SearchAnimes(keyword, 10, "", 0)
+		if err != nil {
+			t.Fatalf("SearchAnimes(%q) error = %v", keyword, err)
+		}
+		if len(results) != 0 || next != "" {
+			t.Errorf("SearchAnimes(%q) = %v, %q, want no results", keyword, results, next)
+		}
+	}
+	if normalizeSearchKeyword("ＲＥ：ゼロ") == "" {
+		t.Error("normalizeSearchKeyword() should keep non-blank keywords")
+	}
+}
+
+func TestCreateAnimeBumpsUpdatedAtOnlyWhenChanged(t *testing.T) {
+	db := openTestDB(t)
+	repo := repositories.NewAnimeRepository(db)
+	work := newTestWork(models.MetadataProviderAnnict, "更新日時テスト")
+	cleanupTestAnime(t, db, work)
+
+	anime := animeFromWork(&work)
+	if err := repo.Create(anime, work.Provider, work.ExternalID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	created := *anime
+
+	// 同じ内容で再取得したときは、同期日時だけを進める
+	time.Sleep(10 * time.Millisecond)
+	resynced := animeFromWork(&work)
+	if err := repo.Create(resynced, work.Provider, work.ExternalID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !resynced.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("updated_at = %v, want unchanged %v", resynced.UpdatedAt, created.UpdatedAt)
+	}
+	if !resynced.SyncedAt.After(created.SyncedAt) {
+		t.Errorf("synced_at = %v, want after %v", resynced.SyncedAt, created.SyncedAt)
+	}
+
+	// 取得元の情報が変わったときは、更新日時も進める
+	work.Title += "（改題）"
+	changed := animeFromWork(&work)
+	if err := repo.Create(changed, work.Provider, work.ExternalID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !changed.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("updated_at = %v, want after %v", changed.UpdatedAt, created.UpdatedAt)
+	}
+}
+
+func TestGetAnimeDetailScoreDistribution(t *testing.T) {
+	db := openTestDB(t)
+	work := newTestWork(models.MetadataProviderAnnict, "分布テスト")
+	cleanupTestAnime(t, db, work)
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{work}}
+	s := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+
+	anime, err := s.FindOrCreateAnime(work.ExternalID)
+	if err != nil {
+		t.Fatalf("FindOrCreateAnime() error = %v", err)
+	}
+	for _, score := range []int{0, 9, 10, 55, 100, 100} {
+		createTestReview(t, db, anime.ID, score)
+	}
+
+	_, stats, err := s.GetAnimeDetail(int64(work.ExternalID))
+	if err != nil {
+		t.Fatalf("GetAnimeDetail() error = %v", err)
+	}
+	if stats.ReviewCount != 6 || stats.Median != 32.5 {
+		t.Errorf("stats = %+v, want 6 reviews with median 32.5", stats)
+	}
+
+	// ヒストグラムはレビューのない区間も含めて10区間で、100点は最後の区間に入る
+	wantCounts := []int{2, 1, 0, 0, 0, 1, 0, 0, 0, 2}
+	if len(stats.Histogram) != len(wantCounts) {
+		t.Fatalf("histogram = %+v, want %d buckets", stats.Histogram, len(wantCounts))
+	}
+	for i, bucket := range stats.Histogram {
+		if bucket.Count != wantCounts[i] {
+			t.Errorf("bucket %d-%d count = %d, want %d", bucket.Min, bucket.Max, bucket.Count, wantCounts[i])
+		}
+	}
+	if last := stats.Histogram[len(stats.Histogram)-1]; last.Min != 90 || last.Max != 100 {
+		t.Errorf("last bucket = %d-%d, want 90-100", last.Min, last.Max)
+	}
+
+	// 100点は6件中の上位2件なので、パーセンタイル順位は (6 - 2/2) / 6
+	top := stats.PercentileRanks[len(stats.PercentileRanks)-1]
+	if top.Score != 100 || top.Count != 2 || math.Abs(top.PercentileRank-500.0/6) > 0.01 {
+		t.Errorf("percentile rank of 100 = %+v, want %.2f", top, 500.0/6)
+	}
+}
+
+// saveTestWork は取得元の作品をDBに保存し、テストの終わりに削除する
+func saveTestWork(t *testing.T, db *sqlx.DB, work models.MetadataWork) *models.Anime {
+	t.Helper()
+	cleanupTestAnime(t, db, work)
+	anime := animeFromWork(&work)
+	if err := repositories.NewAnimeRepository(db).Create(anime, work.Provider, work.ExternalID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return anime
+}
+
+// newTestRankingYear はほかのアニメと重ならない放送年を返す（一覧をその年に絞り込んでテストする）
+func newTestRankingYear() int {
+	return 3000 + int(time.Now().UnixNano()%5000)
+}
+
+func TestGetAnimeListRanksByWeightedScore(t *testing.T) {
+	db := openTestDB(t)
+	s := NewAnimeService(nil, nil, repositories.NewAnimeRepository(db), models.RankingOptions{
+		Sort:     models.AnimeSortAverage,
+		MinVotes: 20,
+		Z:        1.96,
+	})
+	year := newTestRankingYear()
+
+	// 1件だけ100点の作品と、20件とも80点の作品
+	few := newTestWork(models.MetadataProviderAnnict, "ランキングテスト（少数）")
+	few.SeasonYear = &year
+	many := newTestWork(models.MetadataProviderAnnict, "ランキングテスト（多数）")
+	many.SeasonYear = &year
+	many.ExternalID = few.ExternalID + 1
+	fewAnime := saveTestWork(t, db, few)
+	manyAnime := saveTestWork(t, db, many)
+	createTestReview(t, db, fewAnime.ID, 100)
+	for range 20 {
+		createTestReview(t, db, manyAnime.ID, 80)
+	}
+	var priorMean float64
+	if err := db.Get(&priorMean, `SELECT AVG(score)::float8 FROM reviews`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sort  string
+		first int64
+		score float64 // 1件だけの作品の重み付けスコア
+	}{
+		// 平均点ではレビュー数によらず100点の作品が上
+		{models.AnimeSortAverage, fewAnime.ID, 100},
+		// ベイズ平均はレビューが少ないほど全体平均に寄せる
+		{models.AnimeSortBayesian, 0, (100 + 20*priorMean) / 21},
+		// Wilsonスコアはレビューが少ないほど低めに見積もり、20件の作品が上になる
+		{models.AnimeSortWilson, manyAnime.ID, 20.65},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			list, err := s.GetAnimeList(1, 10, tt.sort, models.AnimeListFilter{Year: &year})
+			if err != nil {
+				t.Fatalf("GetAnimeList() error = %v", err)
+			}
+			if len(list.Data) != 2 || list.Pagination.Total != 2 {
+				t.Fatalf("list = %+v, want the 2 test animes", list)
+			}
+			if tt.first != 0 && list.Data[0].ID != tt.first {
+				t.Errorf("first anime = %d, want %d", list.Data[0].ID, tt.first)
+			}
+			for _, anime := range list.Data {
+				if anime.ID == fewAnime.ID && math.Abs(anime.WeightedScore-tt.score) > 0.01 {
+					t.Errorf("weighted score = %v, want %.2f", anime.WeightedScore, tt.score)
+				}
+			}
+		})
+	}
+
+	if _, err := s.GetAnimeList(1, 10, "unknown", models.AnimeListFilter{}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("GetAnimeList() with an unknown sort error = %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestGetAnimeListFiltersBySeasonAndYear(t *testing.T) {
+	// 不正な絞り込み条件はDBに問い合わせる前に弾く
+	s := NewAnimeService(nil, nil, nil, models.RankingOptions{Sort: models.AnimeSortAverage})
+	invalidSeason := "rainy"
+	from, to := 2024, 2023
+	for _, filter := range []models.AnimeListFilter{
+		{Season: &invalidSeason},
+		{YearFrom: &from, YearTo: &to},
+	} {
+		if _, err := s.GetAnimeList(1, 10, "", filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("GetAnimeList(%+v) error = %v, want ErrInvalidFilter", filter, err)
+		}
+	}
+
+	db := openTestDB(t)
+	s = NewAnimeService(nil, nil, repositories.NewAnimeRepository(db), models.RankingOptions{Sort: models.AnimeSortAverage})
+	year := newTestRankingYear()
+	nextYear := year + 1
+	works := map[string]models.MetadataWork{}
+	base := newTestWork(models.MetadataProviderAnnict, "")
+	for i, tt := range []struct {
+		key    string
+		year   int
+		season string
+	}{
+		{"spring", year, "SPRING"},
+		{"autumn", year, "AUTUMN"},
+		{"next spring", nextYear, "SPRING"},
+	} {
+		work := base
+		work.ExternalID = base.ExternalID + i
+		work.Title = "シーズンテスト " + tt.key
+		work.SeasonYear = &tt.year
+		work.SeasonName = &tt.season
+		saveTestWork(t, db, work)
+		works[tt.key] = work
+	}
+
+	spring := models.SeasonSpring
+	tests := []struct {
+		name   string
+		filter models.AnimeListFilter
+		want   []string
+	}{
+		{"year", models.AnimeListFilter{Year: &year}, []string{"spring", "autumn"}},
+		{"year and season", models.AnimeListFilter{Year: &year, Season: &spring}, []string{"spring"}},
+		{"year range and season", models.AnimeListFilter{YearFrom: &year, YearTo: &nextYear, Season: &spring}, []string{"spring", "next spring"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.GetAnimeList(1, 10, "", tt.filter)
+			if err != nil {
+				t.Fatalf("GetAnimeList() error = %v", err)
+			}
+			var got []string
+			for _, anime := range list.Data {
+				for key, work := range works {
+					if anime.AnnictID != nil && int(*anime.AnnictID) == work.ExternalID {
+						got = append(got, key)
+					}
+				}
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) || list.Pagination.Total != len(want) {
+				t.Errorf("animes = %v (total %d), want %v", got, list.Pagination.Total, want)
+			}
+		})
+	}
+}
+
+func TestAnimeFromWorkNormalizesMetadata(t *testing.T) {
+	year := 2024
+	spring, unknown := "SPRING", "RAINY"
+	work := &models.MetadataWork{
+		Provider:        models.MetadataProviderAnnict,
+		ExternalID:      7,
+		Title:           "テスト",
+		TitleEn:         "Test",
+		SeasonYear:      &year,
+		SeasonName:      &spring,
+		Media:           "TV",
+		EpisodesCount:   12,
+		OfficialSiteURL: "https://example.com",
+	}
+
+	// シーズン・放送形態は小文字にそろえ、空の項目はNULLにする
+	anime := animeFromWork(work)
+	if anime.AnnictID == nil || *anime.AnnictID != 7 || anime.Year != 2024 || anime.EpisodesCount != 12 {
+		t.Errorf("anime = %+v, want annict id 7 in 2024 with 12 episodes", anime)
+	}
+	if valueOrEmpty(anime.Season) != models.SeasonSpring || valueOrEmpty(anime.Media) != "tv" {
+		t.Errorf("season = %q, media = %q, want spring and tv", valueOrEmpty(anime.Season), valueOrEmpty(anime.Media))
+	}
+	if anime.TitleKana != nil || anime.ImageURL != nil || anime.TwitterUsername != nil || anime.WikipediaURL != nil {
+		t.Errorf("anime = %+v, want empty fields stored as NULL", anime)
+	}
+	if valueOrEmpty(anime.OfficialSiteURL) != "https://example.com" {
+		t.Errorf("official site = %q, want https://example.com", valueOrEmpty(anime.OfficialSiteURL))
+	}
+
+	// 知らないシーズン名・放送年の不明な作品、Annict以外の取得元の作品
+	work.Provider = models.MetadataProviderAniList
+	work.SeasonName = &unknown
+	work.SeasonYear = nil
+	anime = animeFromWork(work)
+	if anime.AnnictID != nil || anime.Season != nil || anime.Year != 0 {
+		t.Errorf("anime = %+v, want no annict id, season or year", anime)
+	}
+}
+
+func TestSearchAnimesAnnotatesReviewStats(t *testing.T) {
+	db := openTestDB(t)
+	title := fmt.Sprintf("注釈テスト %d", time.Now().UnixNano())
+	local := newTestWork(models.MetadataProviderAnnict, title)
+	remote := newTestWork(models.MetadataProviderAnnict, title)
+	remote.ExternalID = local.ExternalID + 1
+	cleanupTestAnime(t, db, remote)
+	anime := saveTestWork(t, db, local)
+	user, _ := createTestReview(t, db, anime.ID, 70)
+	createTestReview(t, db, anime.ID, 90)
+
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{local, remote}}
+	s := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+
+	results, _, err := s.SearchAnimes(title, 10, "", int64(user.ID))
+	if err != nil {
+		t.Fatalf("SearchAnimes() error = %v", err)
+	}
+	// DBにある作品はAnnictの検索結果に重ねて出さない
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want the local work and the remote work", results)
+	}
+	got, other := results[0], results[1]
+	if got.Source != models.AnimeSearchSourceLocal || got.AnnictID != local.ExternalID {
+		t.Fatalf("first result = %+v, want the local work", got)
+	}
+	if got.ReviewCount != 2 || got.AvgScore != 80 || got.MyScore == nil || *got.MyScore != 70 {
+		t.Errorf("local result = %+v, want 2 reviews averaging 80 and my score 70", got)
+	}
+	if other.Source != models.AnimeSearchSourceAnnict || other.AnnictID != remote.ExternalID || other.ReviewCount != 0 || other.MyScore != nil {
+		t.Errorf("annict result = %+v, want the remote work without reviews", other)
+	}
+
+	// 未ログインなら自分のスコアは付けない
+	results, _, err = s.SearchAnimes(title, 10, "", 0)
+	if err != nil {
+		t.Fatalf("SearchAnimes() error = %v", err)
+	}
+	if results[0].MyScore != nil {
+		t.Errorf("my score = %v, want nil without login", *results[0].MyScore)
+	}
+}
@@ -3,42 +3,90 @@ package services
 import (
 	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/repositories"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"os"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// refreshTokenReuseGrace は交換済みのリフレッシュトークンを再利用とみなさない猶予
+// ブラウザから同時に複数のリクエストが来ると、BFF が同じリフレッシュトークンで同時に再発行することがあるため
+// 猶予内でもアクセストークンを発行し直すのは1回だけで、それ以降の使用は再利用とみなす
+// リフレッシュトークンは発行しない（もう1つ発行するとファミリーが2つに分かれ、片方を盗まれても気づけない）
+const refreshTokenReuseGrace = 10 * time.Second
+
+// ErrInvalidCredentials はメールアドレスかパスワードが違う場合のエラー
+// どちらが違うのかは返さない（メールアドレスが登録されているかを知られないようにする）
+var ErrInvalidCredentials = errors.New("メールアドレスまたはパスワードが間違っています")
+
+// dummyPasswordHash は登録されていないメールアドレスでログインされたときに照合するダミーのハッシュ
+// 登録済みの場合と同じだけ bcrypt の計算をして、応答時間の差から登録の有無を知られないようにする
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// リフレッシュトークンの検証で発生するエラー
+var (
+	ErrInvalidRefreshToken = errors.New("リフレッシュトークンが無効です")
+	// 交換済みのリフレッシュトークンが再び使われた（盗まれた可能性がある）
+	ErrRefreshTokenReused = errors.New("リフレッシュトークンが再利用されました")
+)
+
 type AuthService struct {
-	repo *repositories.UserRepository
+	repo          *repositories.UserRepository
+	revocations   *repositories.TokenRevocationRepository
+	refreshTokens *repositories.RefreshTokenRepository
+	keys          *SigningKeyManager
+	verification  *EmailVerificationService
+	loginAttempts *LoginAttemptService
+	config        models.TokenConfig
 }
 
-func NewAuthService(repo *repositories.UserRepository) *AuthService {
-	return &AuthService{repo: repo}
+func NewAuthService(
+	repo *repositories.UserRepository,
+	revocations *repositories.TokenRevocationRepository,
+	refreshTokens *repositories.RefreshTokenRepository,
+	keys *SigningKeyManager,
+	verification *EmailVerificationService,
+	loginAttempts *LoginAttemptService,
+	config models.TokenConfig,
+) *AuthService {
+	return &AuthService{
+		repo:          repo,
+		revocations:   revocations,
+		refreshTokens: refreshTokens,
+		keys:          keys,
+		verification:  verification,
+		loginAttempts: loginAttempts,
+		config:        config,
+	}
 }
 
 // Signup: ユーザー登録ロジック
-func (s *AuthService) Signup(input models.SignUpInput) (*models.User, string, error) {
+func (s *AuthService) Signup(input models.SignUpInput) (*models.User, *models.AuthTokens, error) {
 	// 1. ユーザー名のバリデーション（文字数チェック）
 	if !models.ValidateUsername(input.Username) {
-		return nil, "", errors.New("ユーザー名は1〜50文字で入力してください")
+		return nil, nil, errors.New("ユーザー名は1〜50文字で入力してください")
 	}
 
 	// 2. ユーザー名の重複チェック
 	exists, err := s.repo.ExistsByUsername(input.Username)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	if exists {
-		return nil, "", errors.New("このユーザー名は既に使用されています")
+		return nil, nil, errors.New("このユーザー名は既に使用されています")
 	}
 
 	// 3. パスワードをハッシュ化
 	hashedPass, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// 4. ユーザーモデル作成
@@ -50,56 +98,284 @@ func (s *AuthService) Signup(input models.SignUpInput) (*models.User, string, er
 
 	// 5. DBに保存
 	if err := s.repo.Create(user); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	// 6. ユーザー登録時にJWTトークンを生成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // 72時間有効
-	})
+	// 6. メールアドレスの確認用のリンクを送る（確認するまではレビューを投稿できない設定にできる）
+	s.verification.SendVerificationMailInBackground(user)
 
-	// 秘密鍵で署名（環境変数から読み込む）
-	secret_key := os.Getenv("JWT_SECRET_KEY")
-	tokenString, err := token.SignedString([]byte(secret_key))
+	// 7. ユーザー登録時にトークンを発行
+	tokens, err := s.issueTokens(user.ID, "")
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, tokenString, nil
+	return user, tokens, nil
 }
 
-// Login: ログインロジック（JWTトークンを返す）
+// Login: ログインロジック（アクセストークン（JWT）とリフレッシュトークンを返す）
 // JWTは
 // Header（ヘッダー）: 暗号化方式などの情報
 // Payload（ペイロード）: ユーザーIDや有効期限などのデータ(暗号化されていないので機密情報は入れないこと)
 // Signature（署名）: シークレットキーを使って生成された暗号データ
 // で構成される
-func (s *AuthService) Login(input models.LoginInput) (*models.User, string, error) {
-	// 1. Emailでユーザー検索
+// 総当たり攻撃への対策として、失敗が続いたメールアドレス・IPアドレスからのログインは
+// パスワードを確認せずに LoginThrottledError で拒否する
+func (s *AuthService) Login(input models.LoginInput, client models.LoginClient) (*models.User, *models.AuthTokens, error) {
+	// 1. 失敗が続いていれば、待ち時間が過ぎるまで拒否する
+	// 受け付ける場合は、同時に送られた試行もすべて数えられるように、パスワードを確認する前に失敗として記録しておく
+	attempt, err := s.loginAttempts.Reserve(input.Email, client, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2. Emailでユーザー検索
 	user, err := s.repo.GetByEmail(input.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, err
+	}
+
+	// 3. パスワード照合 (ハッシュ同士を比較)
+	// ユーザーが見つからない場合もダミーのハッシュと照合して結果を記録し、登録済みの場合と同じだけ時間をかける
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(input.Password))
+		if err := s.loginAttempts.Complete(attempt, nil, models.LoginResultInvalidCredentials); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		if err := s.loginAttempts.Complete(attempt, &user.ID, models.LoginResultInvalidCredentials); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	// 4. 成功を記録する（メールアドレスの失敗回数がリセットされる）
+	// 記録できなくてもログイン自体は続ける
+	if err := s.loginAttempts.Complete(attempt, &user.ID, models.LoginResultSuccess); err != nil {
+		log.Println("Failed to record login attempt:", err)
+	}
+
+	// 5. トークンの発行
+	tokens, err := s.issueTokens(user.ID, "")
 	if err != nil {
-		return nil, "", errors.New("ユーザーが見つかりません")
+		return nil, nil, err
 	}
 
-	// 2. パスワード照合 (ハッシュ同士を比較)
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
+	return user, tokens, nil
+}
+
+// IsAdmin はユーザーが管理者かを返す（AdminMiddleware から呼ばれる）
+func (s *AuthService) IsAdmin(userID int) (bool, error) {
+	user, err := s.repo.GetByID(userID)
 	if err != nil {
-		return nil, "", errors.New("パスワードが間違っています")
+		return false, err
 	}
+	return user.IsAdmin, nil
+}
 
-	// 3. JWTトークンの生成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // 72時間有効
+// Refresh はリフレッシュトークンを新しいトークンの組に交換する
+// 使ったリフレッシュトークンは交換済みになり、二度と使えない（ローテーション）
+// 交換済みのトークンが再び使われた場合は盗まれたとみなし、同じログインから続くトークンをすべて失効させる
+// ただし交換から refreshTokenReuseGrace 以内の最初の1回だけは、同時リクエストとみなしてアクセストークンだけを発行し直す
+func (s *AuthService) Refresh(refreshToken string) (*models.AuthTokens, error) {
+	// 1. ハッシュでリフレッシュトークンを探す
+	current, err := s.refreshTokens.FindByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 2. 交換済みのトークンが使われたら、同じファミリーのトークンをすべて失効させる
+	// ただし交換した直後の最初の1回だけは、同じ画面からの同時リクエストとみなしてアクセストークンだけを発行し直す
+	// （発行し直したことはDBに記録するので、同時に何回使われても発行し直すのは1回だけ）
+	// 新しいリフレッシュトークンは、先に交換したリクエストのレスポンスで Cookie に保存される
+	if current.RotatedAt != nil {
+		reissued, err := s.refreshTokens.MarkReissued(current.ID, refreshTokenReuseGrace)
+		if err != nil {
+			return nil, err
+		}
+		if reissued {
+			now := time.Now()
+			accessToken, err := s.generateToken(current.UserID, now)
+			if err != nil {
+				return nil, err
+			}
+			return &models.AuthTokens{
+				AccessToken:          accessToken,
+				AccessTokenExpiresAt: now.Add(s.config.AccessTTL),
+			}, nil
+		}
+		if err := s.refreshTokens.RevokeFamily(current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// 3. 同じファミリーの新しいトークンを発行する
+	tokens, next, err := s.newTokens(current.UserID, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 使ったトークンを交換済みにして、新しいトークンを保存する
+	// 同時に交換・失効されていた場合は、最新の状態でもう一度判定する（交換済みになっているので再帰は1回で終わる）
+	rotated, err := s.refreshTokens.Rotate(current.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return s.Refresh(refreshToken)
+	}
+
+	return tokens, nil
+}
+
+// issueTokens はアクセストークンとリフレッシュトークンを発行し、リフレッシュトークンを保存する
+// familyID が空なら新しいファミリー（新しいログイン）として発行する
+func (s *AuthService) issueTokens(userID int, familyID string) (*models.AuthTokens, error) {
+	tokens, refreshToken, err := s.newTokens(userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokens.Create(refreshToken); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// newTokens はアクセストークンとリフレッシュトークンを生成する（リフレッシュトークンの保存は呼び出し側で行う）
+func (s *AuthService) newTokens(userID int, familyID string) (*models.AuthTokens, *models.RefreshToken, error) {
+	now := time.Now()
+
+	accessToken, err := s.generateToken(userID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if familyID == "" {
+		if familyID, err = newTokenID(); err != nil {
+			return nil, nil, err
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(b)
+
+	tokens := &models.AuthTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  now.Add(s.config.AccessTTL),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: now.Add(s.config.RefreshTTL),
+	}
+	record := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: tokens.RefreshTokenExpiresAt,
+	}
+	return tokens, record, nil
+}
+
+// generateToken はユーザーのアクセストークン（JWT）を生成する
+// jti（トークンID）はログアウト時にこのトークンだけを失効させるため、
+// iat（発行日時）は「すべての端末からログアウト」でそれ以前のトークンをまとめて失効させるために使う
+// iat を秒単位にすると、ログアウトした直後（同じ秒）にログインし直したトークンまで失効扱いになるので、
+// マイクロ秒まで小数で入れる（JWTの NumericDate は小数も使える）
+func (s *AuthService) generateToken(userID int, now time.Time) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	// 現在の署名鍵で署名する（ヘッダーの kid で検証に使う鍵が分かる）
+	return s.keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"jti":     tokenID,
+		"iat":     float64(now.UnixMicro()) / 1e6,
+		"exp":     now.Add(s.config.AccessTTL).Unix(),
 	})
+}
+
+// VerificationKey はトークンの検証に使う鍵を返す（AuthMiddleware から呼ばれる）
+func (s *AuthService) VerificationKey(token *jwt.Token) (any, error) {
+	return s.keys.VerificationKey(token)
+}
+
+// JWKS はトークンの検証に使える公開鍵の一覧を返す
+func (s *AuthService) JWKS() models.JWKS {
+	return s.keys.JWKS()
+}
+
+// hashToken はDBに保存するトークン（リフレッシュトークン・パスワード再設定用のトークン）のハッシュを返す
+// DBが漏れてもトークンとして使えないように、トークン自体は保存しない
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenID は推測できないランダムなトークンIDを生成する
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RevokeToken はアクセストークンを失効させる（ログアウト）
+// 有効期限までの間、AuthMiddleware はこのアクセストークンを受け付けなくなる
+func (s *AuthService) RevokeToken(tokenID string, userID int, expiresAt time.Time) error {
+	return s.revocations.Revoke(tokenID, userID, expiresAt)
+}
 
-	// 秘密鍵で署名（環境変数から読み込む）
-	secret_key := os.Getenv("JWT_SECRET_KEY")
-	tokenString, err := token.SignedString([]byte(secret_key))
+// RevokeRefreshToken はリフレッシュトークンと、同じログインから続くリフレッシュトークンをすべて失効させる（ログアウト）
+// アクセストークンの期限が切れていてもログアウトできるように、リフレッシュトークンだけで失効させられる
+// （トークンを持っていること自体が本人である証明になる）
+// userID はアクセストークンのユーザー（なければ0）で、0でなければ他人のリフレッシュトークンは失効させない
+func (s *AuthService) RevokeRefreshToken(refreshToken string, userID int) error {
+	current, err := s.refreshTokens.FindByHash(hashToken(refreshToken))
 	if err != nil {
-		return nil, "", err
+		return err
 	}
+	if current == nil || (userID != 0 && current.UserID != userID) {
+		return nil
+	}
+	return s.refreshTokens.RevokeFamily(current.FamilyID)
+}
 
-	return user, tokenString, nil
+// RevokeAllTokens はユーザーがこれまでに発行したトークンをすべて失効させる（すべての端末からログアウト）
+// リフレッシュトークンもすべて失効させるので、どの端末でもアクセストークンを再発行できなくなる
+func (s *AuthService) RevokeAllTokens(userID int) error {
+	if err := s.refreshTokens.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	// iat と同じくマイクロ秒にそろえ、この時点までに発行されたトークンを失効させる
+	return s.revocations.RevokeAllForUser(userID, time.Now().Truncate(time.Microsecond))
+}
+
+// IsTokenRevoked はトークンが失効済みかを調べる（AuthMiddleware から呼ばれる）
+// iat はマイクロ秒まであるので、「すべての端末からログアウト」より後に発行したトークンは失効しない
+// （マイクロ秒まで同じ時刻に発行されたものは失効済みとして扱う。古い秒単位の iat のトークンも同じ比較で失効する）
+func (s *AuthService) IsTokenRevoked(tokenID string, userID int, issuedAt time.Time) (bool, error) {
+	return s.revocations.IsRevoked(tokenID, userID, issuedAt)
+}
+
+// DeleteExpiredTokens は有効期限が過ぎて不要になった失効情報とリフレッシュトークンを削除し、削除した件数を返す
+func (s *AuthService) DeleteExpiredTokens() (int64, error) {
+	now := time.Now()
+	revocations, err := s.revocations.DeleteExpired(now, now.Add(-s.config.AccessTTL))
+	if err != nil {
+		return 0, err
+	}
+	refreshTokens, err := s.refreshTokens.DeleteExpired(now)
+	if err != nil {
+		return revocations, err
+	}
+	return revocations + refreshTokens, nil
 }
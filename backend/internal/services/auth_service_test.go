@@ -0,0 +1,120 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRefreshReissuesAccessTokenOnceWithinGrace(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestAuthService(t, db)
+	user := createTestUser(t, db, "hash")
+
+	first, err := s.issueTokens(user.ID, "")
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	rotated, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// 交換した直後の同時リクエストには1回だけ、アクセストークンだけを発行し直す（ファミリーを分けない）
+	reissued, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() within grace error = %v, want reissue", err)
+	}
+	if reissued.AccessToken == "" || reissued.RefreshToken != "" {
+		t.Errorf("Refresh() within grace = %+v, want an access token only", reissued)
+	}
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM refresh_tokens WHERE user_id = $1`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("refresh tokens = %d, want 2", count)
+	}
+
+	// 先に交換したトークンはそのまま使える
+	next, err := s.Refresh(rotated.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh(rotated) error = %v", err)
+	}
+
+	// 2回目以降は再利用とみなし、同じファミリーのトークンをすべて失効させる
+	if _, err := s.Refresh(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.Refresh(next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh(next) error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeRefreshTokenWithoutAccessToken(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestAuthService(t, db)
+	user := createTestUser(t, db, "hash")
+	other := createTestUser(t, db, "hash")
+
+	tokens, err := s.issueTokens(user.ID, "")
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	// 他人のアクセストークンでは失効させない
+	if err := s.RevokeRefreshToken(tokens.RefreshToken, other.ID); err != nil {
+		t.Fatalf("RevokeRefreshToken() error = %v", err)
+	}
+	if _, err := s.Refresh(tokens.RefreshToken); err != nil {
+		t.Fatalf("Refresh() error = %v, want token to be still valid", err)
+	}
+
+	// アクセストークンがなくても（userID が0）、リフレッシュトークンだけでファミリーごと失効させられる
+	next, err := s.issueTokens(user.ID, "")
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+	if err := s.RevokeRefreshToken(next.RefreshToken, 0); err != nil {
+		t.Fatalf("RevokeRefreshToken() error = %v", err)
+	}
+	if _, err := s.Refresh(next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLoginIsThrottledAfterFailures(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestAuthService(t, db)
+	s.loginAttempts = newTestLoginAttemptService(t, db)
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, db, string(hash))
+
+	for range 2 {
+		_, _, err := s.Login(models.LoginInput{Email: user.Email, Password: "wrong"}, testLoginClient)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	// 失敗が続いたら、正しいパスワードでもパスワードを確認せずに拒否する
+	_, _, err = s.Login(models.LoginInput{Email: user.Email, Password: "correct-password"}, testLoginClient)
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Fatalf("Login() error = %v, want LoginThrottledError", err)
+	}
+
+	// 本人がパスワードを再設定すればログインできる
+	if err := s.loginAttempts.RecordPasswordReset(user.Email, user.ID, testLoginClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Login(models.LoginInput{Email: user.Email, Password: "correct-password"}, testLoginClient); err != nil {
+		t.Errorf("Login() after password reset error = %v", err)
+	}
+}
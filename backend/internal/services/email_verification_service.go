@@ -0,0 +1,176 @@
+package services
+
+import (
+	"anime-score-backend/internal/mail"
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// メールアドレスの確認で発生するエラー
+var (
+	ErrInvalidVerificationToken = errors.New("確認用のリンクが無効か、有効期限が切れています")
+	ErrEmailAlreadyVerified     = errors.New("メールアドレスは確認済みです")
+	// 前回確認メールを送ってから ResendInterval が経っていない
+	ErrVerificationMailThrottled = errors.New("確認メールは送信済みです。しばらく待ってから再送してください")
+)
+
+// EmailVerificationService は登録したメールアドレスの確認を行う
+// 確認用のリンクには「ユーザーID・有効期限・署名」を含め、DBにはトークンを保存しない
+// 署名にはメールアドレスも含めるので、メールアドレスが変わると以前のリンクは使えなくなる
+type EmailVerificationService struct {
+	users  *repositories.UserRepository
+	mailer mail.Sender
+	config models.EmailVerificationConfig
+}
+
+// NewEmailVerificationService はEmailVerificationServiceのインスタンスを生成
+func NewEmailVerificationService(
+	users *repositories.UserRepository,
+	mailer mail.Sender,
+	config models.EmailVerificationConfig,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		users:  users,
+		mailer: mailer,
+		config: config,
+	}
+}
+
+// SendVerificationMailInBackground は確認メールをバックグラウンドで送る（ユーザー登録時に呼ばれる）
+// メールの送信に時間がかかっても、登録のレスポンスを待たせないようにする
+func (s *EmailVerificationService) SendVerificationMailInBackground(user *models.User) {
+	go func() {
+		if err := s.SendVerificationMail(user); err != nil && !errors.Is(err, ErrVerificationMailThrottled) {
+			log.Println("Failed to send verification mail:", err)
+		}
+	}()
+}
+
+// SendVerificationMail はユーザーに確認用のリンクをメールで送る
+// 前回送ってから ResendInterval が経っていなければ ErrVerificationMailThrottled を返す
+func (s *EmailVerificationService) SendVerificationMail(user *models.User) error {
+	// 1. 確認済みでないこと・前回から間隔が空いていることを確認し、送信日時を記録する
+	// 送れなかったときに同じ値で消せるように、DBと同じマイクロ秒にそろえる
+	now := time.Now().Truncate(time.Microsecond)
+	claimed, err := s.users.ClaimVerificationMail(user.ID, now, now.Add(-s.config.ResendInterval))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		if user.EmailVerifiedAt != nil {
+			return ErrEmailAlreadyVerified
+		}
+		return ErrVerificationMailThrottled
+	}
+
+	// 2. 署名付きのリンクをメールで送る
+	// 送れなかったら送信日時を消して、待たずに再送できるようにする
+	if err := s.send(user, now); err != nil {
+		if releaseErr := s.users.ReleaseVerificationMail(user.ID, now); releaseErr != nil {
+			log.Println("Failed to release verification mail:", releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// send は確認用のリンクをメールで送る
+func (s *EmailVerificationService) send(user *models.User, now time.Time) error {
+	link, err := linkWithToken(s.config.VerifyURL, s.sign(user.ID, user.Email, now.Add(s.config.TokenTTL)))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "【AnimeScore】メールアドレスの確認",
+		Body: strings.Join([]string{
+			user.Username + " 様",
+			"",
+			"AnimeScore にご登録いただきありがとうございます。",
+			"以下のリンクを開いて、メールアドレスの確認を完了してください。",
+			"",
+			link,
+			"",
+			fmt.Sprintf("リンクの有効期限は%sです。", formatDuration(s.config.TokenTTL)),
+			"お心当たりがない場合は、このメールを破棄してください。",
+		}, "\n"),
+	})
+}
+
+// Resend はログイン中のユーザーに確認メールを送り直す
+func (s *EmailVerificationService) Resend(userID int) error {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.SendVerificationMail(user)
+}
+
+// Verify はリンクのトークンの署名と有効期限を確認し、メールアドレスを確認済みにする
+// 確認済みのユーザーが同じリンクを開き直してもエラーにはしない
+func (s *EmailVerificationService) Verify(token string) error {
+	// 1. 「ユーザーID.有効期限.署名」に分ける
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidVerificationToken
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	expiresUnix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	expiresAt := time.Unix(expiresUnix, 0)
+	if time.Now().After(expiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	// 2. 現在のメールアドレスで署名し直して比較する（比較にかかる時間から署名を推測されないように hmac.Equal を使う）
+	user, err := s.users.GetByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(token), []byte(s.sign(user.ID, user.Email, expiresAt))) {
+		return ErrInvalidVerificationToken
+	}
+
+	// 3. 確認済みにする
+	return s.users.MarkEmailVerified(user.ID)
+}
+
+// IsEmailVerified はユーザーがメールアドレスを確認済みかを返す（VerifiedEmailMiddleware から呼ばれる）
+func (s *EmailVerificationService) IsEmailVerified(userID int) (bool, error) {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+// sign は確認用のリンクのトークン「ユーザーID.有効期限（UNIX秒）.署名」を作る
+// 署名は HMAC-SHA256 で、ほかの用途の署名と取り違えないように用途の文字列も含める
+func (s *EmailVerificationService) sign(userID int, email string, expiresAt time.Time) string {
+	payload := strconv.Itoa(userID) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte("email-verification." + payload + "." + email))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"anime-score-backend/internal/mail"
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSender は送ったメールを記録する mail.Sender（err を設定したら送信に失敗する）
+type fakeSender struct {
+	mu   sync.Mutex
+	err  error
+	sent []mail.Message
+}
+
+func (s *fakeSender) Send(msg mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestSendVerificationMailReleasesClaimOnFailure(t *testing.T) {
+	db := openTestDB(t)
+	users := repositories.NewUserRepository(db)
+	sender := &fakeSender{err: errors.New("smtp unavailable")}
+	s := NewEmailVerificationService(users, sender, models.EmailVerificationConfig{
+		Secret:         []byte("test-secret"),
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Hour,
+		VerifyURL:      "http://localhost:3000/email/verify",
+	})
+	user := createTestUser(t, db, "hash")
+
+	if err := s.SendVerificationMail(user); err == nil {
+		t.Fatal("SendVerificationMail() should fail when the mail cannot be sent")
+	}
+	stored, err := users.GetByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.EmailVerificationSentAt != nil {
+		t.Errorf("email_verification_sent_at = %v, want NULL after a failed send", stored.EmailVerificationSentAt)
+	}
+
+	// 届いていないので、ResendInterval を待たずに再送できる
+	sender.err = nil
+	if err := s.SendVerificationMail(user); err != nil {
+		t.Fatalf("SendVerificationMail() error = %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent = %d, want 1", len(sender.sent))
+	}
+
+	// 届いた後は ResendInterval の間は再送しない
+	if err := s.SendVerificationMail(user); !errors.Is(err, ErrVerificationMailThrottled) {
+		t.Errorf("SendVerificationMail() error = %v, want ErrVerificationMailThrottled", err)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+)
+
+// エピソード操作で発生するエラー
+var (
+	ErrEpisodeNotFound      = errors.New("エピソードが見つかりません")
+	ErrEpisodeWatchNotFound = errors.New("このエピソードは視聴済みになっていません")
+)
+
+type EpisodeService struct {
+	episodeRepo  *repositories.EpisodeRepository
+	annictRepo   *repositories.AnnictRepository
+	animeService *AnimeService
+}
+
+// NewEpisodeService はEpisodeServiceのインスタンスを生成
+func NewEpisodeService(
+	episodeRepo *repositories.EpisodeRepository,
+	annictRepo *repositories.AnnictRepository,
+	animeService *AnimeService,
+) *EpisodeService {
+	return &EpisodeService{
+		episodeRepo:  episodeRepo,
+		annictRepo:   annictRepo,
+		animeService: animeService,
+	}
+}
+
+// GetEpisodes はアニメのエピソード一覧を視聴数・平均点付きで取得する
+// 1. アニメをDBから探す（なければAnnict APIから取得して保存）
+// 2. エピソードが未取得ならAnnict APIから取得してDBに保存
+// 3. DBからエピソードごとの統計を取得
+func (s *EpisodeService) GetEpisodes(annictID int) (*models.Anime, []models.EpisodeStats, error) {
+	// 1. アニメをDBから探す
+	anime, err := s.animeService.FindOrCreateAnime(annictID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2. エピソードが未取得ならAnnictから取得
+	if err := s.syncEpisodes(anime); err != nil {
+		return nil, nil, err
+	}
+
+	// 3. エピソードごとの統計を取得
+	stats, err := s.episodeRepo.FindStatsByAnimeID(anime.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return anime, stats, nil
+}
+
+// syncEpisodes はアニメのエピソードが未取得の場合にAnnictから取得してDBに保存する
+// 一度取得した作品はDBのキャッシュを使う
+func (s *EpisodeService) syncEpisodes(anime *models.Anime) error {
+	synced, err := s.episodeRepo.IsSynced(anime.ID)
+	if err != nil {
+		return err
+	}
+	// エピソードはAnnictからしか取得できないので、Annictにない作品は空のままにする
+	if synced || anime.AnnictID == nil {
+		return nil
+	}
+
+	annictEpisodes, err := s.annictRepo.GetEpisodesByWorkID(int(*anime.AnnictID))
+	if err != nil {
+		return err
+	}
+
+	// DB保存用のモデルに変換
+	episodes := make([]models.Episode, 0, len(annictEpisodes))
+	for _, e := range annictEpisodes {
+		episodes = append(episodes, models.Episode{
+			AnnictID:   int64(e.AnnictID),
+			Number:     e.Number,
+			NumberText: e.NumberText,
+			SortNumber: e.SortNumber,
+			Title:      e.Title,
+		})
+	}
+
+	return s.episodeRepo.SaveForAnime(anime.ID, episodes)
+}
+
+// RecordWatch はエピソードを視聴済みにする（スコアは任意）
+func (s *EpisodeService) RecordWatch(userID, episodeID int64, input models.EpisodeWatchInput) (*models.EpisodeWatch, error) {
+	// 1. スコアのバリデーション
+	if input.Score != nil && (*input.Score < 0 || *input.Score > 100) {
+		return nil, errors.New("スコアは0〜100の範囲で入力してください")
+	}
+
+	// 2. エピソードが存在するか確認
+	episode, err := s.episodeRepo.FindByID(episodeID)
+	if err != nil {
+		return nil, err
+	}
+	if episode == nil {
+		return nil, ErrEpisodeNotFound
+	}
+
+	// 3. 視聴記録を保存
+	watch := &models.EpisodeWatch{
+		UserID:    userID,
+		EpisodeID: episodeID,
+		Score:     input.Score,
+	}
+	if err := s.episodeRepo.UpsertWatch(watch); err != nil {
+		return nil, err
+	}
+
+	return watch, nil
+}
+
+// RemoveWatch はエピソードの視聴記録を削除する
+func (s *EpisodeService) RemoveWatch(userID, episodeID int64) error {
+	deleted, err := s.episodeRepo.DeleteWatch(userID, episodeID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEpisodeWatchNotFound
+	}
+	return nil
+}
+
+// GetMyWatches は自分が特定のアニメで視聴済みにしたエピソードを取得する
+// アニメがDBにない場合は視聴記録もないので空を返す
+func (s *EpisodeService) GetMyWatches(userID int64, annictID int) ([]models.EpisodeWatch, error) {
+	anime, err := s.animeService.FindLocalAnime(annictID)
+	if err != nil {
+		return nil, err
+	}
+	if anime == nil {
+		return []models.EpisodeWatch{}, nil
+	}
+
+	return s.episodeRepo.FindWatchesByUserAndAnime(userID, anime.ID)
+}
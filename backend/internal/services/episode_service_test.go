@@ -0,0 +1,101 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestEpisodeAnnict は作品のエピソードとして episodeIDs を返す偽のAnnictサーバーにつなぐリポジトリを作る
+// 問い合わせた回数を calls に数える
+func newTestEpisodeAnnict(t *testing.T, calls *atomic.Int32, episodeIDs ...int) *repositories.AnnictRepository {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		nodes := ""
+		for i, id := range episodeIDs {
+			if i > 0 {
+				nodes += ","
+			}
+			nodes += fmt.Sprintf(`{"annictId":%d,"number":%d,"numberText":"第%d話","sortNumber":%d}`, id, i+1, i+1, (i+1)*10)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"searchWorks":{"nodes":[{"episodes":{"nodes":[%s],"pageInfo":{"hasNextPage":false}}}]}}}`, nodes)
+	}))
+	t.Cleanup(server.Close)
+	return repositories.NewAnnictRepository("token", models.AnnictClientConfig{
+		RetryConfig: models.RetryConfig{Timeout: time.Second},
+		Endpoint:    server.URL,
+	}, nil)
+}
+
+func TestEpisodeWatchesAndStats(t *testing.T) {
+	db := openTestDB(t)
+	work := newTestWork(models.MetadataProviderAnnict, "エピソードテスト")
+	cleanupTestAnime(t, db, work)
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{work}}
+	animeService := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+	var calls atomic.Int32
+	s := NewEpisodeService(
+		repositories.NewEpisodeRepository(db),
+		newTestEpisodeAnnict(t, &calls, work.ExternalID, work.ExternalID+1),
+		animeService,
+	)
+
+	// エピソードは初回だけAnnictから取得し、以降はDBのキャッシュを使う
+	_, episodes, err := s.GetEpisodes(work.ExternalID)
+	if err != nil {
+		t.Fatalf("GetEpisodes() error = %v", err)
+	}
+	if _, _, err := s.GetEpisodes(work.ExternalID); err != nil {
+		t.Fatalf("GetEpisodes() error = %v", err)
+	}
+	if len(episodes) != 2 || calls.Load() != 1 {
+		t.Fatalf("episodes = %+v (calls %d), want 2 episodes fetched once", episodes, calls.Load())
+	}
+	first := episodes[0].EpisodeID
+
+	// スコアは任意で、視聴済みにするだけでもよい
+	scorer := createTestUser(t, db, "hash")
+	watcher := createTestUser(t, db, "hash")
+	score := 80
+	if _, err := s.RecordWatch(int64(scorer.ID), first, models.EpisodeWatchInput{Score: &score}); err != nil {
+		t.Fatalf("RecordWatch() error = %v", err)
+	}
+	if _, err := s.RecordWatch(int64(watcher.ID), first, models.EpisodeWatchInput{}); err != nil {
+		t.Fatalf("RecordWatch() without score error = %v", err)
+	}
+	if _, err := s.RecordWatch(int64(watcher.ID), first+1_000_000, models.EpisodeWatchInput{}); !errors.Is(err, ErrEpisodeNotFound) {
+		t.Errorf("RecordWatch() for a missing episode error = %v, want ErrEpisodeNotFound", err)
+	}
+
+	_, episodes, err = s.GetEpisodes(work.ExternalID)
+	if err != nil {
+		t.Fatalf("GetEpisodes() error = %v", err)
+	}
+	stats := episodes[0]
+	if stats.WatchCount != 2 || stats.ScoreCount != 1 || stats.AvgScore == nil || *stats.AvgScore != 80 {
+		t.Errorf("stats = %+v, want 2 watches and 1 score of 80", stats)
+	}
+
+	// 視聴記録を外すと自分の視聴済みから消え、もう一度外そうとすると見つからない
+	if err := s.RemoveWatch(int64(watcher.ID), first); err != nil {
+		t.Fatalf("RemoveWatch() error = %v", err)
+	}
+	if err := s.RemoveWatch(int64(watcher.ID), first); !errors.Is(err, ErrEpisodeWatchNotFound) {
+		t.Errorf("RemoveWatch() twice error = %v, want ErrEpisodeWatchNotFound", err)
+	}
+	watches, err := s.GetMyWatches(int64(scorer.ID), work.ExternalID)
+	if err != nil {
+		t.Fatalf("GetMyWatches() error = %v", err)
+	}
+	if len(watches) != 1 || watches[0].EpisodeID != first || watches[0].Score == nil || *watches[0].Score != 80 {
+		t.Errorf("watches = %+v, want the scored first episode", watches)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+)
+
+// ライブラリ操作で発生するエラー
+var (
+	ErrInvalidWatchStatus = errors.New("視聴ステータスの指定が不正です")
+	ErrLibraryNotFound    = errors.New("ライブラリにこのアニメは登録されていません")
+)
+
+type LibraryService struct {
+	libraryRepo  *repositories.LibraryRepository
+	animeService *AnimeService
+}
+
+// NewLibraryService はLibraryServiceのインスタンスを生成
+func NewLibraryService(
+	libraryRepo *repositories.LibraryRepository,
+	animeService *AnimeService,
+) *LibraryService {
+	return &LibraryService{
+		libraryRepo:  libraryRepo,
+		animeService: animeService,
+	}
+}
+
+// SetStatus はアニメの視聴ステータスを設定する
+// 1. ステータスのバリデーション
+// 2. アニメをDBから探す（なければAnnict APIから取得して保存）
+// 3. 視聴ステータスを保存（設定済みなら上書き）
+func (s *LibraryService) SetStatus(userID int64, annictID int, status string) (*models.UserAnimeStatus, error) {
+	// 1. ステータスのバリデーション
+	if !models.ValidWatchStatus(status) {
+		return nil, ErrInvalidWatchStatus
+	}
+
+	// 2. アニメをDBから探す（レビュー投稿時と同じく、なければAnnictから取得）
+	anime, err := s.animeService.FindOrCreateAnime(annictID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 視聴ステータスを保存
+	entry := &models.UserAnimeStatus{
+		UserID:  userID,
+		AnimeID: anime.ID,
+		Status:  status,
+	}
+	if err := s.libraryRepo.Upsert(entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// ImportStatuses は視聴履歴をまとめて取り込む（ほかのサービスからの移行など）
+// 1. すべてのステータスのバリデーション（1件でも不正なら何も保存しない）
+// 2. アニメをまとめて探す（DBにないものはAnnict APIからまとめて取得して保存）
+// 3. 視聴ステータスを保存（設定済みなら上書き）
+// Annictに存在しないアニメは取り込まずに結果の NotFound に入れる
+func (s *LibraryService) ImportStatuses(userID int64, entries []models.LibraryImportEntry) (*models.LibraryImportResult, error) {
+	// 1. ステータスのバリデーション
+	annictIDs := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if !models.ValidWatchStatus(e.Status) {
+			return nil, ErrInvalidWatchStatus
+		}
+		annictIDs = append(annictIDs, e.AnnictID)
+	}
+
+	// 2. 1件ずつ FindOrCreateAnime を呼ぶとAnnictへのリクエストが件数分発生するので、まとめて取得する
+	animes, err := s.animeService.FindOrCreateAnimes(annictIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 視聴ステータスを保存
+	// 途中で失敗しても一部だけ取り込まれた状態にならないよう、1つのトランザクションで保存する
+	result := &models.LibraryImportResult{NotFound: []int{}}
+	statuses := make([]*models.UserAnimeStatus, 0, len(entries))
+	for _, e := range entries {
+		anime, ok := animes[e.AnnictID]
+		if !ok {
+			result.NotFound = append(result.NotFound, e.AnnictID)
+			continue
+		}
+
+		statuses = append(statuses, &models.UserAnimeStatus{
+			UserID:  userID,
+			AnimeID: anime.ID,
+			Status:  e.Status,
+		})
+	}
+	if err := s.libraryRepo.UpsertAll(statuses); err != nil {
+		return nil, err
+	}
+	result.Imported = len(statuses)
+
+	return result, nil
+}
+
+// RemoveStatus はアニメをライブラリから外す
+func (s *LibraryService) RemoveStatus(userID int64, annictID int) error {
+	// ライブラリに登録済みならアニメはDBに保存されているので、Annictには問い合わせない
+	anime, err := s.animeService.FindLocalAnime(annictID)
+	if err != nil {
+		return err
+	}
+	if anime == nil {
+		return ErrLibraryNotFound
+	}
+
+	deleted, err := s.libraryRepo.Delete(userID, anime.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrLibraryNotFound
+	}
+
+	return nil
+}
+
+// GetLibrary はユーザーのライブラリを取得する
+// status を指定するとそのステータスのアニメのみ返す（空文字ならすべて）
+func (s *LibraryService) GetLibrary(userID int64, status string) ([]models.LibraryEntry, error) {
+	if status != "" && !models.ValidWatchStatus(status) {
+		return nil, ErrInvalidWatchStatus
+	}
+
+	return s.libraryRepo.FindByUserID(userID, status)
+}
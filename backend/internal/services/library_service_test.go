@@ -0,0 +1,108 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestImportStatusesFetchesMissingAnimesAtOnce(t *testing.T) {
+	db := openTestDB(t)
+	first := newTestWork(models.MetadataProviderAnnict, "取り込みテスト1")
+	second := newTestWork(models.MetadataProviderAnnict, "取り込みテスト2")
+	second.ExternalID = first.ExternalID + 1
+	cleanupTestAnime(t, db, first)
+	cleanupTestAnime(t, db, second)
+
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{first, second}}
+	animeService := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+	s := NewLibraryService(repositories.NewLibraryRepository(db), animeService)
+	user := createTestUser(t, db, "hash")
+
+	unknown := second.ExternalID + 1
+	result, err := s.ImportStatuses(int64(user.ID), []models.LibraryImportEntry{
+		{AnnictID: first.ExternalID, Status: "completed"},
+		{AnnictID: second.ExternalID, Status: "watching"},
+		{AnnictID: first.ExternalID, Status: "completed"},
+		{AnnictID: unknown, Status: "dropped"},
+	})
+	if err != nil {
+		t.Fatalf("ImportStatuses() error = %v", err)
+	}
+
+	// DBになかったアニメは、件数によらず1回の問い合わせでまとめて取得する
+	if got := annict.fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+	if result.Imported != 3 || !slices.Equal(result.NotFound, []int{unknown}) {
+		t.Errorf("result = %+v, want 3 imported and %d not found", result, unknown)
+	}
+	var statuses int
+	if err := db.Get(&statuses, `SELECT COUNT(*) FROM user_anime_status WHERE user_id = $1`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if statuses != 2 {
+		t.Errorf("statuses = %d, want 2", statuses)
+	}
+}
+
+func TestLibraryStatusLifecycle(t *testing.T) {
+	db := openTestDB(t)
+	watching := newTestWork(models.MetadataProviderAnnict, "ライブラリテスト1")
+	planned := newTestWork(models.MetadataProviderAnnict, "ライブラリテスト2")
+	planned.ExternalID = watching.ExternalID + 1
+	cleanupTestAnime(t, db, watching)
+	cleanupTestAnime(t, db, planned)
+
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{watching, planned}}
+	animeService := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+	s := NewLibraryService(repositories.NewLibraryRepository(db), animeService)
+	user := createTestUser(t, db, "hash")
+	userID := int64(user.ID)
+
+	if _, err := s.SetStatus(userID, watching.ExternalID, "finished"); !errors.Is(err, ErrInvalidWatchStatus) {
+		t.Fatalf("SetStatus() with an unknown status error = %v, want ErrInvalidWatchStatus", err)
+	}
+
+	// 同じアニメにもう一度設定したら上書きする
+	for _, status := range []string{models.WatchStatusWatching, models.WatchStatusCompleted} {
+		if _, err := s.SetStatus(userID, watching.ExternalID, status); err != nil {
+			t.Fatalf("SetStatus(%s) error = %v", status, err)
+		}
+	}
+	if _, err := s.SetStatus(userID, planned.ExternalID, models.WatchStatusPlanToWatch); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+
+	library, err := s.GetLibrary(userID, "")
+	if err != nil {
+		t.Fatalf("GetLibrary() error = %v", err)
+	}
+	if len(library) != 2 {
+		t.Fatalf("library = %+v, want 2 entries", library)
+	}
+	completed, err := s.GetLibrary(userID, models.WatchStatusCompleted)
+	if err != nil {
+		t.Fatalf("GetLibrary(completed) error = %v", err)
+	}
+	if len(completed) != 1 || completed[0].AnimeAnnictID != int64(watching.ExternalID) || completed[0].AnimeTitle != watching.Title {
+		t.Errorf("completed = %+v, want only %s", completed, watching.Title)
+	}
+
+	// 外したアニメはライブラリに残らず、もう一度外そうとすると見つからない
+	if err := s.RemoveStatus(userID, planned.ExternalID); err != nil {
+		t.Fatalf("RemoveStatus() error = %v", err)
+	}
+	if err := s.RemoveStatus(userID, planned.ExternalID); !errors.Is(err, ErrLibraryNotFound) {
+		t.Errorf("RemoveStatus() twice error = %v, want ErrLibraryNotFound", err)
+	}
+	planToWatch, err := s.GetLibrary(userID, models.WatchStatusPlanToWatch)
+	if err != nil {
+		t.Fatalf("GetLibrary(plan_to_watch) error = %v", err)
+	}
+	if len(planToWatch) != 0 {
+		t.Errorf("plan_to_watch = %+v, want none", planToWatch)
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLoginThrottled はログインの失敗が続いたため、しばらくログインできない場合のエラー
+// 待ち時間は LoginThrottledError.RetryAfter で分かる（errors.As で取り出す）
+var ErrLoginThrottled = errors.New("ログインの試行回数が多すぎます")
+
+// ErrInvalidLoginResult はログインの試行履歴の result に不明な値が指定された場合のエラー
+var ErrInvalidLoginResult = errors.New("result が不正です")
+
+// userAgentMaxLength はDBに保存するUser-Agentの最大の長さ
+const userAgentMaxLength = 255
+
+// LoginThrottledError はログインを拒否したときのエラーで、次にログインを試せるまでの時間を持つ
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s。%d秒後にもう一度お試しください", ErrLoginThrottled.Error(), e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds は待ち時間を Retry-After ヘッダー用に秒単位（切り上げ）で返す
+func (e *LoginThrottledError) RetryAfterSeconds() int {
+	return max(int(math.Ceil(e.RetryAfter.Seconds())), 1)
+}
+
+// Is は errors.Is(err, ErrLoginThrottled) で判定できるようにする
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// LoginAttemptService はログインの試行を記録し、失敗が続いたメールアドレス・IPアドレスからのログインを制限する
+// 記録した試行履歴は監査用に管理者が閲覧できる
+type LoginAttemptService struct {
+	repo   *repositories.LoginAttemptRepository
+	config models.LoginThrottleConfig
+}
+
+// NewLoginAttemptService はLoginAttemptServiceのインスタンスを生成
+func NewLoginAttemptService(repo *repositories.LoginAttemptRepository, config models.LoginThrottleConfig) *LoginAttemptService {
+	return &LoginAttemptService{repo: repo, config: config}
+}
+
+// Reserve は email・client からのログインを受け付けてよいか確認し、受け付ける場合はパスワードを確認する前に失敗として記録する
+// 結果が分かったら、返した試行を Complete で更新すること
+// 確認と記録はメールアドレス・IPアドレスのロックを取って行うので、同時に送られた試行もすべて失敗回数に数えられ、
+// 待ち時間やロックを超えてパスワードを試されることはない
+// 失敗が続いていれば、待ち時間が過ぎるまで LoginThrottledError を返す
+func (s *LoginAttemptService) Reserve(email string, client models.LoginClient, now time.Time) (*models.LoginAttempt, error) {
+	attempt := newLoginAttempt(email, nil, client, models.LoginResultInvalidCredentials)
+
+	var retryAfter time.Duration
+	err := s.repo.WithLock(attempt.Email, attempt.IPAddress, func(tx *repositories.LoginAttemptTx) error {
+		var err error
+		retryAfter, err = s.retryAfter(tx, attempt.Email, attempt.IPAddress, now)
+		if err != nil {
+			return err
+		}
+		if retryAfter > 0 {
+			return s.recordThrottled(tx, attempt, now)
+		}
+		return tx.Create(attempt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return attempt, nil
+}
+
+// Complete は Reserve で記録した試行を、パスワードを確認した結果で更新する
+func (s *LoginAttemptService) Complete(attempt *models.LoginAttempt, userID *int, result string) error {
+	attempt.UserID = userID
+	attempt.Result = result
+	return s.repo.UpdateResult(attempt.ID, userID, result)
+}
+
+// retryAfter は email・ipAddress からのログインを受け付けるまでの待ち時間を返す（0ならすぐにログインできる）
+// メールアドレス単位・IPアドレス単位のそれぞれで、失敗回数に応じた待ち時間を計算して長いほうを返す
+// 登録されていないメールアドレスも同じように数えるので、待ち時間の違いから登録の有無は分からない
+func (s *LoginAttemptService) retryAfter(tx *repositories.LoginAttemptTx, email, ipAddress string, now time.Time) (time.Duration, error) {
+	since := now.Add(-s.config.Window)
+
+	account, err := tx.AccountFailures(email, since)
+	if err != nil {
+		return 0, err
+	}
+	ip, err := tx.IPFailures(ipAddress, since)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(
+		s.waitFor(account, s.config.AccountFreeAttempts, s.config.AccountLockoutThreshold, now),
+		s.waitFor(ip, s.config.IPFreeAttempts, s.config.IPLockoutThreshold, now),
+	), nil
+}
+
+// recordThrottled は拒否した試行を記録する
+// 拒否している間に何度送られても行が増え続けないように、同じメールアドレス・IPアドレスについては
+// ThrottledRecordInterval に1回だけ記録する
+func (s *LoginAttemptService) recordThrottled(tx *repositories.LoginAttemptTx, attempt *models.LoginAttempt, now time.Time) error {
+	if s.config.ThrottledRecordInterval > 0 {
+		recorded, err := tx.RecentlyThrottled(attempt.Email, attempt.IPAddress, now.Add(-s.config.ThrottledRecordInterval))
+		if err != nil {
+			return err
+		}
+		if recorded {
+			return nil
+		}
+	}
+	attempt.Result = models.LoginResultThrottled
+	return tx.Create(attempt)
+}
+
+// waitFor は失敗回数から、最後の失敗を起点にした残りの待ち時間を計算する
+func (s *LoginAttemptService) waitFor(stats models.LoginFailureStats, freeAttempts, lockoutThreshold int, now time.Time) time.Duration {
+	if stats.LastFailedAt == nil {
+		return 0
+	}
+
+	var delay time.Duration
+	switch {
+	case lockoutThreshold > 0 && stats.Count >= lockoutThreshold:
+		delay = s.config.LockoutDuration
+	case stats.Count >= freeAttempts:
+		delay = progressiveDelay(s.config.BaseDelay, s.config.MaxDelay, stats.Count-freeAttempts)
+	default:
+		return 0
+	}
+
+	return max(stats.LastFailedAt.Add(delay).Sub(now), 0)
+}
+
+// progressiveDelay は base の 2^n 倍の待ち時間を返す（最大 maxDelay）
+func progressiveDelay(base, maxDelay time.Duration, n int) time.Duration {
+	// 2^n 倍がオーバーフローする前に上限で打ち切る
+	if float64(base)*math.Pow(2, float64(n)) >= float64(maxDelay) {
+		return maxDelay
+	}
+	return base << n
+}
+
+// RecordPasswordReset はパスワードを再設定したことを記録する
+// メールアドレスの失敗回数がリセットされるので、他人に失敗を繰り返されてロックされても、本人は再設定すればログインできる
+func (s *LoginAttemptService) RecordPasswordReset(email string, userID int, client models.LoginClient) error {
+	return s.repo.Create(newLoginAttempt(email, &userID, client, models.LoginResultPasswordReset))
+}
+
+// newLoginAttempt は記録するログインの試行を作る（userID は登録されていないメールアドレスならnil）
+func newLoginAttempt(email string, userID *int, client models.LoginClient, result string) *models.LoginAttempt {
+	userAgent := client.UserAgent
+	if len(userAgent) > userAgentMaxLength {
+		userAgent = strings.ToValidUTF8(userAgent[:userAgentMaxLength], "")
+	}
+
+	return &models.LoginAttempt{
+		Email:     normalizeEmail(email),
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: userAgent,
+		Result:    result,
+	}
+}
+
+// List はログインの試行履歴を新しい順に1ページ分取得する（管理者用）
+// 次ページがある場合は nextCursor を、ないなら空文字を返す（レビュー一覧と同じ形式）
+func (s *LoginAttemptService) List(query models.LoginAttemptListQuery) ([]models.LoginAttempt, string, error) {
+	// 1. バリデーション
+	switch query.Result {
+	case "", models.LoginResultSuccess, models.LoginResultInvalidCredentials, models.LoginResultThrottled, models.LoginResultPasswordReset:
+	default:
+		return nil, "", ErrInvalidLoginResult
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 50 // デフォルト値
+	}
+	if limit > 200 {
+		limit = 200 // 上限値
+	}
+
+	opts := models.LoginAttemptListOptions{
+		Email:  normalizeEmail(query.Email),
+		IP:     query.IP,
+		UserID: query.UserID,
+		Result: query.Result,
+		// 次ページがあるか判定するために1件多く取得する
+		Limit: limit + 1,
+	}
+
+	// 2. カーソル（前ページ最後の試行のID）をデコード
+	if query.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		id, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		opts.BeforeID = id
+	}
+
+	// 3. Repository呼び出し
+	attempts, err := s.repo.List(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 4. 余分に取得した1件があれば次ページあり
+	nextCursor := ""
+	if len(attempts) > limit {
+		attempts = attempts[:limit]
+		lastID := strconv.FormatInt(attempts[len(attempts)-1].ID, 10)
+		nextCursor = base64.RawURLEncoding.EncodeToString([]byte(lastID))
+	}
+
+	return attempts, nextCursor, nil
+}
+
+// DeleteOld は AuditRetention より古い試行履歴を削除し、削除した件数を返す
+func (s *LoginAttemptService) DeleteOld() (int64, error) {
+	if s.config.AuditRetention <= 0 {
+		return 0, nil
+	}
+	// 失敗回数を数える期間の履歴は消さないようにする
+	retention := max(s.config.AuditRetention, s.config.Window, s.config.LockoutDuration)
+	return s.repo.DeleteBefore(time.Now().Add(-retention))
+}
+
+// normalizeEmail は失敗回数を数えるときのキーにするため、メールアドレスの大文字・小文字と前後の空白をそろえる
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
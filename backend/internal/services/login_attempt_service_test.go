@@ -0,0 +1,160 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReserveCountsConcurrentAttempts(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestLoginAttemptService(t, db)
+	email := newTestLoginEmail(t, db)
+
+	// 失敗を記録する前に同時に送っても、受け付けるのは待たずに試せる2回だけ
+	const concurrency = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Reserve(email, testLoginClient, time.Now())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	reserved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			reserved++
+		case !errors.Is(err, ErrLoginThrottled):
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if reserved != 2 {
+		t.Errorf("reserved = %d, want 2", reserved)
+	}
+}
+
+func TestReserveRecordsThrottledAttemptsOncePerInterval(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestLoginAttemptService(t, db)
+	email := newTestLoginEmail(t, db)
+
+	for range 2 {
+		if _, err := s.Reserve(email, testLoginClient, time.Now()); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	for range 5 {
+		if _, err := s.Reserve(email, testLoginClient, time.Now()); !errors.Is(err, ErrLoginThrottled) {
+			t.Fatalf("Reserve() error = %v, want ErrLoginThrottled", err)
+		}
+	}
+
+	var throttled int
+	err := db.Get(&throttled, `SELECT COUNT(*) FROM login_attempts WHERE email = $1 AND result = $2`,
+		email, models.LoginResultThrottled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if throttled != 1 {
+		t.Errorf("throttled rows = %d, want 1", throttled)
+	}
+}
+
+func TestSuccessAndPasswordResetClearAccountFailures(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestLoginAttemptService(t, db)
+	user := createTestUser(t, db, "hash")
+
+	// 成功で終わった試行は失敗に数えない
+	attempt, err := s.Reserve(user.Email, testLoginClient, time.Now())
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := s.Complete(attempt, &user.ID, models.LoginResultSuccess); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	for range 2 {
+		if _, err := s.Reserve(user.Email, testLoginClient, time.Now()); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if _, err := s.Reserve(user.Email, testLoginClient, time.Now()); !errors.Is(err, ErrLoginThrottled) {
+		t.Fatalf("Reserve() error = %v, want ErrLoginThrottled", err)
+	}
+
+	// 本人がパスワードを再設定したら、すぐにログインできる
+	if err := s.RecordPasswordReset(user.Email, user.ID, testLoginClient); err != nil {
+		t.Fatalf("RecordPasswordReset() error = %v", err)
+	}
+	if _, err := s.Reserve(user.Email, testLoginClient, time.Now()); err != nil {
+		t.Errorf("Reserve() after password reset error = %v", err)
+	}
+}
+
+func TestListLoginAttemptsPaginates(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestLoginAttemptService(t, db)
+	email := newTestLoginEmail(t, db)
+
+	var ids []int64
+	for range 5 {
+		attempt := newLoginAttempt(email, nil, testLoginClient, models.LoginResultInvalidCredentials)
+		if err := s.repo.Create(attempt); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, attempt.ID)
+	}
+
+	// 新しい順に2件ずつ、カーソルで続きを取得する
+	var got []int64
+	cursor := ""
+	for page := 0; ; page++ {
+		attempts, next, err := s.List(models.LoginAttemptListQuery{Email: email, Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(attempts) > 2 {
+			t.Fatalf("page %d has %d attempts, want at most 2", page, len(attempts))
+		}
+		for _, attempt := range attempts {
+			got = append(got, attempt.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(got) != len(ids) {
+		t.Fatalf("List() returned %v, want %d attempts", got, len(ids))
+	}
+	for i, id := range got {
+		if want := ids[len(ids)-1-i]; id != want {
+			t.Errorf("attempt %d = %d, want %d", i, id, want)
+		}
+	}
+}
+
+func TestListLoginAttemptsValidatesQuery(t *testing.T) {
+	s := NewLoginAttemptService(nil, models.LoginThrottleConfig{})
+
+	if _, _, err := s.List(models.LoginAttemptListQuery{Cursor: "not base64!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List() error = %v, want ErrInvalidCursor", err)
+	}
+	if _, _, err := s.List(models.LoginAttemptListQuery{Cursor: "MA"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List(cursor=0) error = %v, want ErrInvalidCursor", err)
+	}
+	if _, _, err := s.List(models.LoginAttemptListQuery{Result: "unknown"}); !errors.Is(err, ErrInvalidLoginResult) {
+		t.Errorf("List() error = %v, want ErrInvalidLoginResult", err)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"anime-score-backend/internal/mail"
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidResetToken はパスワード再設定用のトークンが見つからない・使用済み・期限切れの場合のエラー
+var ErrInvalidResetToken = errors.New("パスワード再設定用のリンクが無効か、有効期限が切れています")
+
+// ErrPasswordTooLong は新しいパスワードが bcrypt で扱える72バイトを超える場合のエラー
+// 入力の max=72 は文字数で数えるので、全角文字を含むと文字数の制限内でもここで弾く
+var ErrPasswordTooLong = errors.New("パスワードが長すぎます")
+
+// PasswordResetService はメールで送るリンクを使ったパスワードの再設定を行う
+type PasswordResetService struct {
+	users       *repositories.UserRepository
+	repo        *repositories.PasswordResetRepository
+	authService *AuthService
+	attempts    *LoginAttemptService
+	mailer      mail.Sender
+	config      models.PasswordResetConfig
+	requests    chan string // 送信待ちの再設定メールの宛先（Run の worker が送る）
+}
+
+// NewPasswordResetService はPasswordResetServiceのインスタンスを生成
+func NewPasswordResetService(
+	users *repositories.UserRepository,
+	repo *repositories.PasswordResetRepository,
+	authService *AuthService,
+	attempts *LoginAttemptService,
+	mailer mail.Sender,
+	config models.PasswordResetConfig,
+) *PasswordResetService {
+	return &PasswordResetService{
+		users:       users,
+		repo:        repo,
+		authService: authService,
+		attempts:    attempts,
+		mailer:      mailer,
+		config:      config,
+		requests:    make(chan string, max(config.QueueSize, 1)),
+	}
+}
+
+// RequestReset はメールアドレスのユーザーにパスワード再設定用のリンクをメールで送るよう依頼する
+// 登録されていないメールアドレスかどうかを知られないように、結果は待たずに Run の worker が送る
+// （ユーザーの検索・メールの送信にかかる時間の差からも分からないようにする）
+// 送信待ちが QueueSize を超えたら、goroutine やメモリが増え続けないように依頼を捨てる
+func (s *PasswordResetService) RequestReset(email string) {
+	select {
+	case s.requests <- email:
+	default:
+		log.Println("Password reset mail queue is full, dropping a request")
+	}
+}
+
+// Run は ctx がキャンセルされるまで、Workers 個の goroutine で送信待ちの再設定メールを送る
+// goroutine で呼び出すこと（例: go service.Run(ctx)）
+func (s *PasswordResetService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range max(s.config.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case email := <-s.requests:
+					if err := s.sendResetMail(email); err != nil {
+						log.Println("Failed to send password reset mail:", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// sendResetMail はトークンを発行し、再設定用のリンクをメールで送る
+// 登録されていないメールアドレスや、前回送ってから ResendInterval が経っていない場合は何もしない
+func (s *PasswordResetService) sendResetMail(email string) error {
+	// 1. Emailでユーザー検索
+	user, err := s.users.GetByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 2. 短い間隔で何度もメールを送らないようにする
+	// 送ってよいかの確認と送信日時の記録を1回で行うので、同時に頼まれても送るのは1通だけになる
+	// 送れなかったときに同じ値で消せるように、DBと同じマイクロ秒にそろえる
+	now := time.Now().Truncate(time.Microsecond)
+	claimed, err := s.users.ClaimPasswordResetMail(user.ID, now, now.Add(-s.config.ResendInterval))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	// 3. トークンを発行してメールを送る
+	// 送れなかったら送信日時を消して、待たずに再送できるようにする
+	if err := s.send(user, now); err != nil {
+		if releaseErr := s.users.ReleasePasswordResetMail(user.ID, now); releaseErr != nil {
+			log.Println("Failed to release password reset mail:", releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// send はトークンを発行してハッシュだけを保存し、再設定用のリンクをメールで送る
+func (s *PasswordResetService) send(user *models.User, now time.Time) error {
+	// 1. トークンを発行し、ハッシュだけを保存する
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(s.config.TokenTTL),
+	}
+	if err := s.repo.Create(record); err != nil {
+		return err
+	}
+
+	// 2. 再設定ページへのリンクをメールで送る
+	link, err := linkWithToken(s.config.ResetURL, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "【AnimeScore】パスワード再設定のご案内",
+		Body: strings.Join([]string{
+			user.Username + " 様",
+			"",
+			"パスワード再設定のリクエストを受け付けました。",
+			"以下のリンクから新しいパスワードを設定してください。",
+			"",
+			link,
+			"",
+			fmt.Sprintf("リンクの有効期限は%sです。リンクは1回だけ使えます。", formatDuration(s.config.TokenTTL)),
+			"お心当たりがない場合は、このメールを破棄してください。パスワードは変更されません。",
+		}, "\n"),
+	})
+}
+
+// ResetPassword はトークンを確認して新しいパスワードを設定し、すべての端末からログアウトさせる
+// パスワードを盗まれて再設定した場合に、盗んだ側のログインも使えなくするため
+// 再設定したことはログインの試行履歴にも記録し、ログインの失敗が続いてかかったロックを解く（client は再設定したクライアント）
+func (s *PasswordResetService) ResetPassword(token string, password string, client models.LoginClient) error {
+	// 1. 無効なトークンで重いハッシュ化を何度もさせられないように、先にトークンを確認する
+	tokenHash := hashToken(token)
+	if _, err := s.repo.FindValid(tokenHash, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// 2. パスワードをハッシュ化
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return ErrPasswordTooLong
+	}
+	if err != nil {
+		return err
+	}
+
+	// 3. トークンを使用済みにしてパスワードを変更する
+	// ハッシュ化の間に同じトークンが使われていたら、ここで ok が false になる
+	userID, ok, err := s.repo.ResetPassword(tokenHash, time.Now(), func(tx *sqlx.Tx, userID int) error {
+		return s.users.UpdatePassword(tx, userID, string(hashedPass))
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	// 4. 発行済みのトークンをすべて失効させる
+	// パスワードはもう変わっているので、失敗しても再設定は成功として返す（ログに残す）
+	if err := s.authService.RevokeAllTokens(userID); err != nil {
+		log.Printf("Failed to revoke tokens after password reset (userId: %d): %v", userID, err)
+	}
+
+	// 5. メールアドレスの失敗回数をリセットする
+	// 他人にパスワードを何度も間違えられてロックされても、メールを受け取れる本人は再設定すればログインできる
+	user, err := s.users.GetByID(userID)
+	if err == nil {
+		err = s.attempts.RecordPasswordReset(user.Email, userID, client)
+	}
+	if err != nil {
+		log.Printf("Failed to record password reset (userId: %d): %v", userID, err)
+	}
+	return nil
+}
+
+// DeleteExpiredTokens は有効期限が過ぎたパスワード再設定用のトークンを削除し、削除した件数を返す
+func (s *PasswordResetService) DeleteExpiredTokens() (int64, error) {
+	return s.repo.DeleteExpired(time.Now())
+}
+
+// linkWithToken はメールに載せるページのURLにトークンをクエリパラメータとして付ける
+func linkWithToken(pageURL string, token string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid link URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// formatDuration はメールの本文用に期間を「1時間」「30分」「45秒」のように表す
+// 割り切れない端数は切り捨てる（有効期限を実際より長く案内しないため）
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d時間", d/time.Hour)
+	}
+	if d >= time.Minute {
+		return fmt.Sprintf("%d分", d/time.Minute)
+	}
+	return fmt.Sprintf("%d秒", d/time.Second)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestPasswordResetService(t *testing.T, db *sqlx.DB) *PasswordResetService {
+	t.Helper()
+	return NewPasswordResetService(
+		repositories.NewUserRepository(db),
+		repositories.NewPasswordResetRepository(db),
+		newTestAuthService(t, db),
+		newTestLoginAttemptService(t, db),
+		nil,
+		models.PasswordResetConfig{TokenTTL: time.Hour, QueueSize: 1, Workers: 1},
+	)
+}
+
+// createTestResetToken はユーザーのパスワード再設定用のトークンを保存し、メールで送るトークンを返す
+func createTestResetToken(t *testing.T, db *sqlx.DB, userID int) string {
+	t.Helper()
+	token := "reset-" + time.Now().Format(time.RFC3339Nano)
+	err := repositories.NewPasswordResetRepository(db).Create(&models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("failed to create reset token: %v", err)
+	}
+	return token
+}
+
+func TestRequestResetDropsWhenQueueIsFull(t *testing.T) {
+	s := NewPasswordResetService(nil, nil, nil, nil, nil, models.PasswordResetConfig{QueueSize: 2})
+
+	// worker が動いていなくても、送信待ちが上限を超えたら待たずに捨てる
+	done := make(chan struct{})
+	go func() {
+		for range 5 {
+			s.RequestReset("user@example.com")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RequestReset() blocked on a full queue")
+	}
+	if len(s.requests) != 2 {
+		t.Errorf("queued = %d, want 2", len(s.requests))
+	}
+}
+
+func TestResetPasswordRejectsInvalidToken(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestPasswordResetService(t, db)
+
+	if err := s.ResetPassword("unknown-token", "new-password", testLoginClient); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetPasswordIsSingleUseAndLogsOut(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestPasswordResetService(t, db)
+	user := createTestUser(t, db, "old-hash")
+	token := createTestResetToken(t, db, user.ID)
+
+	tokens, err := s.authService.issueTokens(user.ID, "")
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	if err := s.ResetPassword(token, "new-password", testLoginClient); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+
+	updated, err := repositories.NewUserRepository(db).GetByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(updated.PasswordHash), []byte("new-password")) != nil {
+		t.Error("password was not updated")
+	}
+
+	// 再設定の前に発行したリフレッシュトークンは使えない
+	if _, err := s.authService.Refresh(tokens.RefreshToken); err == nil {
+		t.Error("Refresh() should fail after password reset")
+	}
+
+	// 同じトークンでは2回再設定できない
+	if err := s.ResetPassword(token, "another-password", testLoginClient); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second ResetPassword() error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestSendResetMailSendsOnceConcurrently(t *testing.T) {
+	db := openTestDB(t)
+	users := repositories.NewUserRepository(db)
+	sender := &fakeSender{}
+	s := NewPasswordResetService(users, repositories.NewPasswordResetRepository(db), nil, nil, sender, models.PasswordResetConfig{
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Hour,
+		ResetURL:       "http://localhost:3000/password/reset",
+	})
+	user := createTestUser(t, db, "hash")
+
+	// 複数の worker が同じ宛先を同時に処理しても、送るのは1通だけ
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.sendResetMail(user.Email); err != nil {
+				t.Errorf("sendResetMail() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(sender.sent) != 1 {
+		t.Errorf("sent = %d, want 1", len(sender.sent))
+	}
+}
+
+func TestResetPasswordRejectsTooLongPassword(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestPasswordResetService(t, db)
+	user := createTestUser(t, db, "old-hash")
+	token := createTestResetToken(t, db, user.ID)
+
+	// 文字数は72以内でも、72バイトを超えると bcrypt で扱えない
+	password := strings.Repeat("あ", 30)
+	if err := s.ResetPassword(token, password, testLoginClient); !errors.Is(err, ErrPasswordTooLong) {
+		t.Fatalf("ResetPassword() error = %v, want ErrPasswordTooLong", err)
+	}
+
+	// 弾いた場合はトークンを使用済みにしないので、パスワードを直して再設定できる
+	if err := s.ResetPassword(token, "new-password", testLoginClient); err != nil {
+		t.Errorf("ResetPassword() error = %v", err)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2 * time.Hour, "2時間"},
+		{90 * time.Minute, "90分"},
+		{30 * time.Minute, "30分"},
+		{45 * time.Second, "45秒"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
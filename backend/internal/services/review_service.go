@@ -3,7 +3,22 @@ package services
 import (
 	"anime-score-backend/internal/models"
 	"anime-score-backend/internal/repositories"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
+)
+
+// レビュー操作で発生するエラー
+// ハンドラーで errors.Is を使ってステータスコードを判別できるように変数として定義しておく
+var (
+	ErrReviewNotFound  = errors.New("レビューが見つかりません")
+	ErrReviewForbidden = errors.New("他のユーザーのレビューは操作できません")
+	ErrInvalidCursor   = errors.New("カーソルが不正です")
+	ErrInvalidSort     = errors.New("並び順の指定が不正です")
+	ErrInvalidRange    = errors.New("スコアの範囲指定が不正です")
+	ErrSelfHelpfulVote = errors.New("自分のレビューには投票できません")
 )
 
 type ReviewService struct {
@@ -28,7 +43,7 @@ func NewReviewService(
 // 3. レビューを保存
 func (s *ReviewService) CreateReview(userID int64, input models.ReviewInput) (*models.Review, error) {
 	// 1. スコアのバリデーション
-	if input.Score < 0 || input.Score > 100 {
+	if input.Score == nil || *input.Score < 0 || *input.Score > 100 {
 		return nil, errors.New("スコアは0〜100の範囲で入力してください")
 	}
 
@@ -52,7 +67,7 @@ func (s *ReviewService) CreateReview(userID int64, input models.ReviewInput) (*m
 	review := &models.Review{
 		UserID:  userID,
 		AnimeID: anime.ID,
-		Score:   input.Score,
+		Score:   *input.Score,
 		Comment: input.Comment,
 	}
 
@@ -63,10 +78,221 @@ func (s *ReviewService) CreateReview(userID int64, input models.ReviewInput) (*m
 	return review, nil
 }
 
-// GetReviewsByAnimeID は特定アニメのレビュー一覧を取得
+// UpdateReview は自分のレビューのスコアとコメントを更新する
+// 1. レビューが存在するか確認
+// 2. 投稿者本人かチェック
+// 3. レビューを更新
+func (s *ReviewService) UpdateReview(userID, reviewID int64, input models.ReviewUpdateInput) (*models.Review, error) {
+	// 1. スコアのバリデーション
+	if input.Score == nil || *input.Score < 0 || *input.Score > 100 {
+		return nil, errors.New("スコアは0〜100の範囲で入力してください")
+	}
+
+	// 2. 対象のレビューを取得して所有者を確認
+	if err := s.checkOwnership(userID, reviewID); err != nil {
+		return nil, err
+	}
+
+	// 3. レビューを更新
+	review := &models.Review{
+		ID:      reviewID,
+		UserID:  userID,
+		Score:   *input.Score,
+		Comment: input.Comment,
+	}
+
+	updated, err := s.reviewRepo.Update(review)
+	if err != nil {
+		return nil, err
+	}
+	// 確認後に削除された場合など、更新対象がなくなっていたとき
+	if !updated {
+		return nil, ErrReviewNotFound
+	}
+
+	return review, nil
+}
+
+// DeleteReview は自分のレビューを削除する
+func (s *ReviewService) DeleteReview(userID, reviewID int64) error {
+	// 1. 対象のレビューを取得して所有者を確認
+	if err := s.checkOwnership(userID, reviewID); err != nil {
+		return err
+	}
+
+	// 2. レビューを削除
+	deleted, err := s.reviewRepo.Delete(reviewID, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrReviewNotFound
+	}
+
+	return nil
+}
+
+// checkOwnership はレビューが存在し、かつ指定ユーザーが投稿者であるかを確認する
+// 存在しなければ ErrReviewNotFound、他人のレビューなら ErrReviewForbidden を返す
+func (s *ReviewService) checkOwnership(userID, reviewID int64) error {
+	review, err := s.reviewRepo.FindByID(reviewID)
+	if err != nil {
+		return err
+	}
+	if review == nil {
+		return ErrReviewNotFound
+	}
+	if review.UserID != userID {
+		return ErrReviewForbidden
+	}
+	return nil
+}
+
+// GetReviewRevisions はレビュー本体とその編集履歴（古い順）を取得する
+// 履歴に現在の内容を加えたものが、スコアの推移（タイムライン）になる
+func (s *ReviewService) GetReviewRevisions(reviewID int64) (*models.Review, []models.ReviewRevision, error) {
+	review, err := s.reviewRepo.FindByID(reviewID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if review == nil {
+		return nil, nil, ErrReviewNotFound
+	}
+
+	revisions, err := s.reviewRepo.FindRevisionsByReviewID(reviewID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return review, revisions, nil
+}
+
+// GetReviewsByAnimeID は特定アニメのレビュー一覧を条件付きで1ページ分取得
+// 次ページがある場合は nextCursor を、ないなら空文字を返す（検索APIと同じ形式）
 // ※すべての操作をServiceを通して行うことで、コードの一貫性が保たれる
-func (s *ReviewService) GetReviewsByAnimeID(animeID int64) ([]models.Review, error) {
-	return s.reviewRepo.FindByAnimeID(animeID)
+func (s *ReviewService) GetReviewsByAnimeID(query models.ReviewListQuery) ([]models.Review, string, error) {
+	sort, limit, minScore, maxScore := query.Sort, query.Limit, query.MinScore, query.MaxScore
+
+	// 1. バリデーション
+	if sort == "" {
+		sort = models.ReviewSortNewest
+	}
+	switch sort {
+	case models.ReviewSortNewest, models.ReviewSortOldest, models.ReviewSortScoreDesc,
+		models.ReviewSortScoreAsc, models.ReviewSortHelpful:
+	default:
+		return nil, "", ErrInvalidSort
+	}
+
+	if limit <= 0 {
+		limit = 20 // デフォルト値
+	}
+	if limit > 50 {
+		limit = 50 // 上限値
+	}
+
+	if (minScore != nil && (*minScore < 0 || *minScore > 100)) ||
+		(maxScore != nil && (*maxScore < 0 || *maxScore > 100)) ||
+		(minScore != nil && maxScore != nil && *minScore > *maxScore) {
+		return nil, "", ErrInvalidRange
+	}
+
+	opts := models.ReviewListOptions{
+		Sort: sort,
+		// 次ページがあるか判定するために1件多く取得する
+		Limit:      limit + 1,
+		HasComment: query.HasComment,
+		MinScore:   minScore,
+		MaxScore:   maxScore,
+	}
+
+	// 2. カーソルをデコード
+	if query.Cursor != "" {
+		decoded, err := decodeReviewCursor(query.Cursor, sort)
+		if err != nil {
+			return nil, "", err
+		}
+		opts.Cursor = decoded
+	}
+
+	// 3. Repository呼び出し
+	reviews, err := s.reviewRepo.FindByAnimeID(query.AnimeID, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 4. 余分に取得した1件があれば次ページあり
+	nextCursor := ""
+	if len(reviews) > limit {
+		reviews = reviews[:limit]
+		nextCursor = encodeReviewCursor(reviews[len(reviews)-1], sort)
+	}
+
+	return reviews, nextCursor, nil
+}
+
+// encodeReviewCursor はページ最後のレビューからカーソル文字列を作る
+// 中身をクライアントに意識させないよう、JSONをURLセーフなBase64にしている
+func encodeReviewCursor(review models.Review, sort string) string {
+	var value string
+	switch sort {
+	case models.ReviewSortScoreDesc, models.ReviewSortScoreAsc:
+		value = strconv.Itoa(review.Score)
+	case models.ReviewSortHelpful:
+		value = strconv.Itoa(review.HelpfulCount)
+	default:
+		value = review.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	// 文字列とint64だけの構造体なのでMarshalは失敗しない
+	b, _ := json.Marshal(models.ReviewCursor{Value: value, ID: review.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeReviewCursor はカーソル文字列を復元し、並び順に合った値か検証する
+// 並び順を変えたのに古いカーソルが送られてきた場合もここで弾く
+func decodeReviewCursor(cursor, sort string) (*models.ReviewCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded models.ReviewCursor
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	switch sort {
+	case models.ReviewSortNewest, models.ReviewSortOldest:
+		_, err = time.Parse(time.RFC3339Nano, decoded.Value)
+	default:
+		_, err = strconv.Atoi(decoded.Value)
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &decoded, nil
+}
+
+// MarkHelpful はレビューに「参考になった」を付ける（取り消す場合は helpful=false）
+// 自分のレビューには投票できない
+func (s *ReviewService) MarkHelpful(userID, reviewID int64, helpful bool) error {
+	review, err := s.reviewRepo.FindByID(reviewID)
+	if err != nil {
+		return err
+	}
+	if review == nil {
+		return ErrReviewNotFound
+	}
+	if review.UserID == userID {
+		return ErrSelfHelpfulVote
+	}
+
+	if helpful {
+		return s.reviewRepo.AddHelpfulVote(reviewID, userID)
+	}
+	return s.reviewRepo.RemoveHelpfulVote(reviewID, userID)
 }
 
 // GetReviewsByUserID は特定ユーザーのレビュー一覧を取得
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestReviewCursorRoundTrip(t *testing.T) {
+	review := models.Review{
+		ID:           42,
+		Score:        85,
+		HelpfulCount: 3,
+		CreatedAt:    time.Date(2026, 10, 16, 12, 0, 0, 123456000, time.UTC),
+	}
+
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{models.ReviewSortNewest, "2026-10-16T12:00:00.123456Z"},
+		{models.ReviewSortOldest, "2026-10-16T12:00:00.123456Z"},
+		{models.ReviewSortScoreDesc, "85"},
+		{models.ReviewSortScoreAsc, "85"},
+		{models.ReviewSortHelpful, "3"},
+	}
+	for _, tt := range tests {
+		decoded, err := decodeReviewCursor(encodeReviewCursor(review, tt.sort), tt.sort)
+		if err != nil {
+			t.Fatalf("decodeReviewCursor(%s) error = %v", tt.sort, err)
+		}
+		if decoded.Value != tt.want || decoded.ID != review.ID {
+			t.Errorf("cursor for %s = %+v, want {%s %d}", tt.sort, decoded, tt.want, review.ID)
+		}
+	}
+}
+
+func TestDecodeReviewCursorRejectsInvalidCursor(t *testing.T) {
+	review := models.Review{ID: 1, Score: 50, CreatedAt: time.Now()}
+
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+	}{
+		{"not base64", "not base64!", models.ReviewSortNewest},
+		{"not json", "bm90IGpzb24", models.ReviewSortNewest},
+		// 並び順を変えたのに前の並び順のカーソルが送られてきた
+		{"newest cursor for score sort", encodeReviewCursor(review, models.ReviewSortNewest), models.ReviewSortScoreDesc},
+		{"score cursor for newest sort", encodeReviewCursor(review, models.ReviewSortScoreDesc), models.ReviewSortNewest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeReviewCursor(tt.cursor, tt.sort); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeReviewCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestGetReviewsByAnimeIDPaginates(t *testing.T) {
+	db := openTestDB(t)
+	s := NewReviewService(repositories.NewReviewRepository(db), nil)
+
+	var animeID int64
+	if err := db.Get(&animeID, `INSERT INTO animes (title, year) VALUES ('cursor test', 2026) RETURNING id`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM animes WHERE id = $1`, animeID) })
+
+	// 同じスコア・同じ作成日時のレビューがページの境目をまたいでも、IDで順番が決まり重複・抜けがない
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	scores := []int{80, 80, 80, 60, 90}
+	var ids []int64
+	for _, score := range scores {
+		user := createTestUser(t, db, "hash")
+		var id int64
+		err := db.Get(&id, `INSERT INTO reviews (user_id, anime_id, score, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+			user.ID, animeID, score, createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		sort string
+		want []int64
+	}{
+		// 作成日時がすべて同じなので、新着順はIDの大きい順
+		{models.ReviewSortNewest, []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{models.ReviewSortOldest, []int64{ids[0], ids[1], ids[2], ids[3], ids[4]}},
+		{models.ReviewSortScoreDesc, []int64{ids[4], ids[2], ids[1], ids[0], ids[3]}},
+		{models.ReviewSortScoreAsc, []int64{ids[3], ids[0], ids[1], ids[2], ids[4]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			var got []int64
+			cursor := ""
+			for {
+				reviews, next, err := s.GetReviewsByAnimeID(models.ReviewListQuery{
+					AnimeID: animeID, Sort: tt.sort, Limit: 2, Cursor: cursor,
+				})
+				if err != nil {
+					t.Fatalf("GetReviewsByAnimeID() error = %v", err)
+				}
+				for _, review := range reviews {
+					got = append(got, review.ID)
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("reviews = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateReviewAcceptsZeroScore(t *testing.T) {
+	db := openTestDB(t)
+	work := newTestWork(models.MetadataProviderAnnict, "0点テスト")
+	cleanupTestAnime(t, db, work)
+	annict := &fakeProvider{name: models.MetadataProviderAnnict, works: []models.MetadataWork{work}}
+	animeService := NewAnimeService(annict, nil, repositories.NewAnimeRepository(db), models.RankingOptions{})
+	s := NewReviewService(repositories.NewReviewRepository(db), animeService)
+	user := createTestUser(t, db, "hash")
+
+	// スコアがない入力は弾く
+	if _, err := s.CreateReview(int64(user.ID), models.ReviewInput{AnnictID: work.ExternalID}); err == nil {
+		t.Error("CreateReview() without score should fail")
+	}
+
+	// 0点は未入力ではなく、有効なスコアとして投稿できる
+	zero := 0
+	review, err := s.CreateReview(int64(user.ID), models.ReviewInput{AnnictID: work.ExternalID, Score: &zero})
+	if err != nil {
+		t.Fatalf("CreateReview() error = %v", err)
+	}
+	if review.Score != 0 {
+		t.Errorf("score = %d, want 0", review.Score)
+	}
+}
+
+// createTestReview はユーザーを作ってアニメにレビューを投稿し、レビューのIDを返す
+func createTestReview(t *testing.T, db *sqlx.DB, animeID int64, score int) (*models.User, int64) {
+	t.Helper()
+	user := createTestUser(t, db, "hash")
+	var id int64
+	err := db.Get(&id, `INSERT INTO reviews (user_id, anime_id, score) VALUES ($1, $2, $3) RETURNING id`, user.ID, animeID, score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, id
+}
+
+// createTestAnimeRow はアニメを1件保存し、テストの終わりに削除する
+func createTestAnimeRow(t *testing.T, db *sqlx.DB, title string) int64 {
+	t.Helper()
+	var id int64
+	if err := db.Get(&id, `INSERT INTO animes (title, year) VALUES ($1, 2026) RETURNING id`, title); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM animes WHERE id = $1`, id) })
+	return id
+}
+
+func TestUpdateReviewRecordsRevisions(t *testing.T) {
+	db := openTestDB(t)
+	s := NewReviewService(repositories.NewReviewRepository(db), nil)
+	animeID := createTestAnimeRow(t, db, "revision test")
+	user, reviewID := createTestReview(t, db, animeID, 60)
+
+	// 他人のレビューは編集できず、履歴も増えない
+	other := createTestUser(t, db, "hash")
+	score := 10
+	if _, err := s.UpdateReview(int64(other.ID), reviewID, models.ReviewUpdateInput{Score: &score}); !errors.Is(err, ErrReviewForbidden) {
+		t.Fatalf("UpdateReview() by another user error = %v, want ErrReviewForbidden", err)
+	}
+
+	// 編集のたびに、編集前の内容が古い順に履歴に残る
+	for _, score := range []int{70, 80} {
+		if _, err := s.UpdateReview(int64(user.ID), reviewID, models.ReviewUpdateInput{Score: &score}); err != nil {
+			t.Fatalf("UpdateReview() error = %v", err)
+		}
+	}
+	review, revisions, err := s.GetReviewRevisions(reviewID)
+	if err != nil {
+		t.Fatalf("GetReviewRevisions() error = %v", err)
+	}
+	if review.Score != 80 || review.UpdatedAt == nil {
+		t.Errorf("review = %+v, want score 80 and updatedAt set", review)
+	}
+	var scores []int
+	for _, revision := range revisions {
+		scores = append(scores, revision.Score)
+	}
+	if !slices.Equal(scores, []int{60, 70}) {
+		t.Errorf("revision scores = %v, want [60 70]", scores)
+	}
+
+	if _, _, err := s.GetReviewRevisions(reviewID + 1_000_000); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("GetReviewRevisions() for a missing review error = %v, want ErrReviewNotFound", err)
+	}
+}
+
+func TestGetReviewsByAnimeIDIncludesAuthor(t *testing.T) {
+	db := openTestDB(t)
+	s := NewReviewService(repositories.NewReviewRepository(db), nil)
+	animeID := createTestAnimeRow(t, db, "author test")
+	otherAnimeID := createTestAnimeRow(t, db, "author test 2")
+	user, _ := createTestReview(t, db, animeID, 75)
+	if _, err := db.Exec(`INSERT INTO reviews (user_id, anime_id, score) VALUES ($1, $2, 50)`, user.ID, otherAnimeID); err != nil {
+		t.Fatal(err)
+	}
+
+	reviews, _, err := s.GetReviewsByAnimeID(models.ReviewListQuery{AnimeID: animeID})
+	if err != nil {
+		t.Fatalf("GetReviewsByAnimeID() error = %v", err)
+	}
+	if len(reviews) != 1 || reviews[0].Author == nil {
+		t.Fatalf("reviews = %+v, want one review with its author", reviews)
+	}
+	author := reviews[0].Author
+	// レビュー数はこのアニメに限らず、投稿者のすべてのレビューを数える
+	if author.ID != int64(user.ID) || author.Username != user.Username || author.ReviewCount != 2 || author.JoinedAt.IsZero() {
+		t.Errorf("author = %+v, want %s with 2 reviews", author, user.Username)
+	}
+
+	// 公開プロフィールなので、メールアドレスはレスポンスに含めない
+	b, err := json.Marshal(reviews[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), user.Email) {
+		t.Errorf("review json %s should not contain the author's email", b)
+	}
+}
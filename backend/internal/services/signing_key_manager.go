@@ -0,0 +1,479 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownSigningKey はトークンの kid に対応する署名鍵が見つからない場合のエラー
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// encryptedKeyPrefix は暗号化して保存した秘密鍵の先頭に付ける印（暗号化の方式を変えるときのためのバージョン付き）
+// 印のない秘密鍵は、暗号化に対応する前に保存した PEM のまま
+const encryptedKeyPrefix = "enc:v1:"
+
+// signingKeyReloadInterval は知らない kid のトークンが来たときに、DBから鍵を読み込み直す最短の間隔
+// 不正なトークンを大量に送られてもDBへの問い合わせが増えすぎないようにする
+const signingKeyReloadInterval = 5 * time.Second
+
+// signingKey は読み込み済みの署名鍵
+type signingKey struct {
+	id        string // kid（HS256 では空文字）
+	method    jwt.SigningMethod
+	private   any // 署名用: *rsa.PrivateKey / ed25519.PrivateKey / []byte
+	public    any // 検証用: *rsa.PublicKey / ed25519.PublicKey / []byte
+	createdAt time.Time
+}
+
+// SigningKeyManager はJWTの署名鍵を管理する
+// RS256 / EdDSA では鍵をDBに保存して複数のプロセスで共有し、RotationInterval ごとに新しい鍵に交換する
+// DBの秘密鍵は EncryptionKey（KEK）で暗号化し、DBが漏れても秘密鍵だけでは使えないようにする
+// 交換した古い鍵は VerifyGrace の間だけ検証用に残すので、交換前に発行したトークンもそのまま使える
+// HS256 では JWT_SECRET_KEY の共通鍵だけを使い、交換はしない
+// HS256 から切り替えたときに JWT_SECRET_KEY が残っていれば、切り替え前に発行したトークンも検証だけはできる
+// （最初の非対称鍵を作る前の iat のトークンだけを受け付け、その VerifyGrace 後には共通鍵を使わなくなる）
+type SigningKeyManager struct {
+	repo   *repositories.SigningKeyRepository
+	config models.SigningKeyConfig
+	kek    cipher.AEAD // 秘密鍵の暗号化に使う（HS256 では nil）
+	legacy *signingKey // 切り替え前の HS256 の共通鍵（検証だけに使う。なければ nil）
+
+	mu         sync.RWMutex
+	keys       map[string]*signingKey // kid をキーにした検証用の鍵
+	active     *signingKey            // 署名に使う鍵
+	legacyEnd  time.Time              // 共通鍵で検証するトークンの iat の上限（最初の非対称鍵を作った日時）
+	lastReload time.Time
+}
+
+// NewSigningKeyManager はSigningKeyManagerのインスタンスを生成する
+// RS256 / EdDSA の場合はDBから鍵を読み込み、署名鍵がなければ作成する（EncryptionKey がなければエラー）
+func NewSigningKeyManager(repo *repositories.SigningKeyRepository, config models.SigningKeyConfig) (*SigningKeyManager, error) {
+	m := &SigningKeyManager{
+		repo:   repo,
+		config: config,
+		keys:   map[string]*signingKey{},
+	}
+
+	switch config.Algorithm {
+	case models.SigningAlgorithmHS256:
+		if config.Secret == "" {
+			return nil, errors.New("JWT_SECRET_KEY is required for HS256")
+		}
+		key := &signingKey{
+			method:  jwt.SigningMethodHS256,
+			private: []byte(config.Secret),
+			public:  []byte(config.Secret),
+		}
+		m.keys[""] = key
+		m.active = key
+		return m, nil
+	case models.SigningAlgorithmRS256, models.SigningAlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %q", config.Algorithm)
+	}
+
+	kek, err := newKeyEncryption(config.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	m.kek = kek
+
+	if config.Secret != "" {
+		m.legacy = &signingKey{
+			method: jwt.SigningMethodHS256,
+			public: []byte(config.Secret),
+		}
+	}
+
+	if err := m.RotateIfDue(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Run は ctx がキャンセルされるまで、一定間隔で鍵の交換が必要か確認し、ほかのプロセスが作った鍵を読み込む
+// goroutine で呼び出すこと（例: go manager.Run(ctx)）
+func (m *SigningKeyManager) Run(ctx context.Context) {
+	if m.config.Algorithm == models.SigningAlgorithmHS256 || m.config.CheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.RotateIfDue(); err != nil {
+			log.Println("Failed to rotate signing key:", err)
+		}
+		if deleted, err := m.repo.DeleteExpired(time.Now()); err != nil {
+			log.Println("Failed to delete expired signing keys:", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired signing keys", deleted)
+		}
+	}
+}
+
+// RotateIfDue は署名鍵がない・RotationInterval より古い・アルゴリズムが設定と違う場合に新しい鍵を作り、
+// DBから鍵を読み込み直す
+// 複数のプロセスが同時に呼んでも、アドバイザリーロックで新しい鍵は1つだけ作られる
+func (m *SigningKeyManager) RotateIfDue() error {
+	if m.config.Algorithm == models.SigningAlgorithmHS256 {
+		return nil
+	}
+
+	err := m.repo.WithRotationLock(func(tx *repositories.SigningKeyTx) error {
+		now := time.Now()
+		keys, err := tx.FindUsable(now)
+		if err != nil {
+			return err
+		}
+
+		// 暗号化に対応する前に保存した鍵は、ここで暗号化し直す
+		for _, key := range keys {
+			if strings.HasPrefix(key.PrivateKey, encryptedKeyPrefix) {
+				continue
+			}
+			sealed, err := sealPrivateKey(m.kek, key.KID, key.PrivateKey)
+			if err != nil {
+				return err
+			}
+			if err := tx.UpdatePrivateKey(key.KID, sealed); err != nil {
+				return err
+			}
+			log.Printf("Encrypted JWT signing key (kid: %s)", key.KID)
+		}
+
+		// 新しい順に並んでいるので、最初に見つかった署名中の鍵が現在の署名鍵
+		for _, key := range keys {
+			if key.RetiredAt != nil {
+				continue
+			}
+			if key.Algorithm == m.config.Algorithm &&
+				(m.config.RotationInterval <= 0 || now.Sub(key.CreatedAt) < m.config.RotationInterval) {
+				return nil
+			}
+			break
+		}
+
+		// 新しい鍵の作成と古い鍵の交換は同じトランザクションで行い、署名鍵が2つある状態を作らない
+		key, err := generateSigningKey(m.config.Algorithm, m.kek)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(key); err != nil {
+			return err
+		}
+		if err := tx.RetireOthers(key.KID, now.Add(m.config.VerifyGrace)); err != nil {
+			return err
+		}
+
+		log.Printf("Rotated JWT signing key (kid: %s, alg: %s)", key.KID, key.Algorithm)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.Reload()
+}
+
+// Reload はDBから検証に使える鍵を読み込み直す
+func (m *SigningKeyManager) Reload() error {
+	if m.config.Algorithm == models.SigningAlgorithmHS256 {
+		return nil
+	}
+
+	now := time.Now()
+	records, err := m.repo.FindUsable(now)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(records)+1)
+	var active *signingKey
+	var legacyEnd time.Time
+	for _, record := range records {
+		// 切り替えた日時は、検証に使える鍵のうち最も古い鍵を作った日時
+		// それより前の鍵は期限切れなので、共通鍵も VerifyGrace を過ぎて使わなくなっている
+		if legacyEnd.IsZero() || record.CreatedAt.Before(legacyEnd) {
+			legacyEnd = record.CreatedAt
+		}
+
+		key, err := parseSigningKey(m.kek, record)
+		if err != nil {
+			log.Printf("Failed to parse signing key (kid: %s): %v", record.KID, err)
+			continue
+		}
+		keys[key.id] = key
+
+		// 新しい順に並んでいるので、最初に見つかった署名中の鍵を署名に使う
+		if active == nil && record.RetiredAt == nil && record.Algorithm == m.config.Algorithm {
+			active = key
+		}
+	}
+	if active == nil {
+		return errors.New("no active signing key")
+	}
+	if m.legacy != nil && now.Before(legacyEnd.Add(m.config.VerifyGrace)) {
+		keys[""] = m.legacy
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.active = active
+	m.legacyEnd = legacyEnd
+	m.lastReload = now
+	m.mu.Unlock()
+	return nil
+}
+
+// Sign はクレームに現在の署名鍵で署名し、ヘッダーに kid を付けたトークンを返す
+func (m *SigningKeyManager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key := m.active
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		token.Header["kid"] = key.id
+	}
+	return token.SignedString(key.private)
+}
+
+// VerificationKey はトークンのヘッダーの kid に対応する検証用の鍵を返す（jwt.Keyfunc として使う）
+// 知らない kid の場合は、ほかのプロセスが新しい鍵を作った直後かもしれないので、DBから読み込み直して探す
+func (m *SigningKeyManager) VerificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, reloadable := m.lookup(kid)
+	if key == nil && reloadable {
+		if err := m.Reload(); err != nil {
+			log.Println("Failed to reload signing keys:", err)
+		}
+		key, _ = m.lookup(kid)
+	}
+	if key == nil {
+		return nil, ErrUnknownSigningKey
+	}
+	if key == m.legacy && !m.acceptsLegacy(token, time.Now()) {
+		return nil, ErrUnknownSigningKey
+	}
+
+	// アルゴリズムが鍵のものと一致するか確認（alg を書き換えたトークンへの対策）
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// acceptsLegacy は切り替え前の共通鍵でトークンを検証してよいか確認する
+// 切り替えた後に共通鍵で署名したトークン（JWT_SECRET_KEY を知っていれば作れる）は受け付けない
+func (m *SigningKeyManager) acceptsLegacy(token *jwt.Token, now time.Time) bool {
+	m.mu.RLock()
+	legacyEnd := m.legacyEnd
+	m.mu.RUnlock()
+
+	if !now.Before(legacyEnd.Add(m.config.VerifyGrace)) {
+		return false
+	}
+	// iat はマイクロ秒まであるので、秒に切り捨てる claims.GetIssuedAt は使わない
+	claims, _ := token.Claims.(jwt.MapClaims)
+	iat, ok := claims["iat"].(float64)
+	return ok && time.UnixMicro(int64(math.Round(iat*1e6))).Before(legacyEnd)
+}
+
+// lookup は kid の鍵を探す
+// 見つからない場合は、DBから読み込み直してよいか（前回から signingKeyReloadInterval 以上経っているか）もあわせて返す
+func (m *SigningKeyManager) lookup(kid string) (*signingKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if key, ok := m.keys[kid]; ok {
+		return key, false
+	}
+	reloadable := kid != "" &&
+		m.config.Algorithm != models.SigningAlgorithmHS256 &&
+		time.Since(m.lastReload) >= signingKeyReloadInterval
+	return nil, reloadable
+}
+
+// JWKS は検証に使える公開鍵の一覧を新しい順に返す（HS256 の共通鍵は公開しない）
+func (m *SigningKeyManager) JWKS() models.JWKS {
+	m.mu.RLock()
+	keys := slices.Collect(maps.Values(m.keys))
+	m.mu.RUnlock()
+
+	slices.SortFunc(keys, func(a, b *signingKey) int {
+		return b.createdAt.Compare(a.createdAt)
+	})
+
+	jwks := models.JWKS{Keys: []models.JWK{}}
+	for _, key := range keys {
+		jwk := models.JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// generateSigningKey は新しい署名鍵を作る（秘密鍵は PKCS#8 の PEM にして、kek で暗号化する）
+func generateSigningKey(algorithm string, kek cipher.AEAD) (*models.SigningKey, error) {
+	var private any
+	switch algorithm {
+	case models.SigningAlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case models.SigningAlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %q", algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sealPrivateKey(kek, kid, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		KID:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: sealed,
+	}, nil
+}
+
+// parseSigningKey はDBに保存した署名鍵を kek で復号して読み込む
+// 暗号化に対応する前に保存した PEM のままの鍵もそのまま読み込む（次の RotateIfDue で暗号化される）
+func parseSigningKey(kek cipher.AEAD, record models.SigningKey) (*signingKey, error) {
+	privatePEM := record.PrivateKey
+	if strings.HasPrefix(privatePEM, encryptedKeyPrefix) {
+		var err error
+		if privatePEM, err = openPrivateKey(kek, record.KID, privatePEM); err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: record.KID, private: private, createdAt: record.CreatedAt}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if record.Algorithm != models.SigningAlgorithmRS256 {
+			return nil, fmt.Errorf("algorithm mismatch: %s", record.Algorithm)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.public = &k.PublicKey
+	case ed25519.PrivateKey:
+		if record.Algorithm != models.SigningAlgorithmEdDSA {
+			return nil, fmt.Errorf("algorithm mismatch: %s", record.Algorithm)
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.public = k.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", private)
+	}
+	return key, nil
+}
+
+// newKeyEncryption は base64 の32バイトの鍵（JWT_KEY_ENCRYPTION_KEY）から、秘密鍵の暗号化に使う AES-256-GCM を作る
+func newKeyEncryption(encoded string) (cipher.AEAD, error) {
+	if encoded == "" {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY is required for RS256 / EdDSA")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY must be 32 bytes encoded in base64")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey は秘密鍵の PEM を kek で暗号化し、DBに保存する文字列にする
+// kid を追加認証データにするので、暗号文をほかの鍵の行にコピーしても復号できない
+func sealPrivateKey(kek cipher.AEAD, kid, privatePEM string) (string, error) {
+	nonce := make([]byte, kek.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := kek.Seal(nonce, nonce, []byte(privatePEM), []byte(kid))
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPrivateKey は sealPrivateKey で暗号化した秘密鍵を復号して PEM に戻す
+func openPrivateKey(kek cipher.AEAD, kid, stored string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedKeyPrefix))
+	if err != nil || len(sealed) < kek.NonceSize() {
+		return "", errors.New("invalid encrypted private key")
+	}
+	nonce, ciphertext := sealed[:kek.NonceSize()], sealed[kek.NonceSize():]
+	privatePEM, err := kek.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		// JWT_KEY_ENCRYPTION_KEY が鍵を作ったときと違う
+		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return string(privatePEM), nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKEK(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestNewKeyEncryptionValidatesKey(t *testing.T) {
+	for _, encoded := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := newKeyEncryption(encoded); err == nil {
+			t.Errorf("newKeyEncryption(%q) should fail", encoded)
+		}
+	}
+	if _, err := newKeyEncryption(newTestKEK(t)); err != nil {
+		t.Errorf("newKeyEncryption() error = %v", err)
+	}
+}
+
+func TestSigningKeyIsEncryptedAtRest(t *testing.T) {
+	kek, err := newKeyEncryption(newTestKEK(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, algorithm := range []string{models.SigningAlgorithmEdDSA, models.SigningAlgorithmRS256} {
+		record, err := generateSigningKey(algorithm, kek)
+		if err != nil {
+			t.Fatalf("generateSigningKey(%s) error = %v", algorithm, err)
+		}
+		if !strings.HasPrefix(record.PrivateKey, encryptedKeyPrefix) || strings.Contains(record.PrivateKey, "PRIVATE KEY") {
+			t.Fatalf("private key of %s is not encrypted: %q", algorithm, record.PrivateKey)
+		}
+
+		key, err := parseSigningKey(kek, *record)
+		if err != nil {
+			t.Fatalf("parseSigningKey(%s) error = %v", algorithm, err)
+		}
+		if key.method.Alg() != algorithm {
+			t.Errorf("method = %s, want %s", key.method.Alg(), algorithm)
+		}
+	}
+}
+
+func TestParseSigningKeyRejectsWrongKEKOrKID(t *testing.T) {
+	kek, _ := newKeyEncryption(newTestKEK(t))
+	other, _ := newKeyEncryption(newTestKEK(t))
+	record, err := generateSigningKey(models.SigningAlgorithmEdDSA, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseSigningKey(other, *record); err == nil {
+		t.Error("parseSigningKey() should fail with another KEK")
+	}
+
+	// 暗号文をほかの kid の行にコピーしても復号できない
+	copied := *record
+	copied.KID = "another-kid"
+	if _, err := parseSigningKey(kek, copied); err == nil {
+		t.Error("parseSigningKey() should fail for another kid")
+	}
+}
+
+func TestParseSigningKeyReadsPlaintextPEM(t *testing.T) {
+	kek, _ := newKeyEncryption(newTestKEK(t))
+	record, err := generateSigningKey(models.SigningAlgorithmEdDSA, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 暗号化に対応する前に保存した鍵は PEM のまま読める
+	privatePEM, err := openPrivateKey(kek, record.KID, record.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.PrivateKey = privatePEM
+	if _, err := parseSigningKey(kek, *record); err != nil {
+		t.Errorf("parseSigningKey() error = %v", err)
+	}
+}
+
+func TestLegacySecretVerifiesOldTokensOnly(t *testing.T) {
+	kek, _ := newKeyEncryption(newTestKEK(t))
+	record, err := generateSigningKey(models.SigningAlgorithmEdDSA, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.CreatedAt = time.Now().Add(-time.Minute)
+	active, err := parseSigningKey(kek, *record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 から EdDSA に切り替えた直後（JWT_SECRET_KEY が残っている）
+	legacy := &signingKey{method: jwt.SigningMethodHS256, public: []byte("old-secret")}
+	m := &SigningKeyManager{
+		config:     models.SigningKeyConfig{Algorithm: models.SigningAlgorithmEdDSA, VerifyGrace: time.Hour},
+		legacy:     legacy,
+		keys:       map[string]*signingKey{"": legacy, active.id: active},
+		active:     active,
+		legacyEnd:  active.createdAt,
+		lastReload: time.Now(),
+	}
+
+	signLegacy := func(claims jwt.MapClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("old-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	iat := func(at time.Time) float64 { return float64(at.UnixMicro()) / 1e6 }
+
+	// 切り替え前に発行したトークンは受け付ける
+	old := signLegacy(jwt.MapClaims{"user_id": 1, "iat": iat(active.createdAt.Add(-time.Second))})
+	if _, err := jwt.Parse(old, m.VerificationKey); err != nil {
+		t.Errorf("token issued before the switch should be accepted: %v", err)
+	}
+
+	// 切り替えた後に共通鍵で署名したトークン・iat のないトークンは受け付けない
+	for name, claims := range map[string]jwt.MapClaims{
+		"issued after the switch": {"user_id": 1, "iat": iat(time.Now())},
+		"without iat":             {"user_id": 1},
+	} {
+		if _, err := jwt.Parse(signLegacy(claims), m.VerificationKey); !errors.Is(err, ErrUnknownSigningKey) {
+			t.Errorf("token %s: error = %v, want ErrUnknownSigningKey", name, err)
+		}
+	}
+
+	current, err := m.Sign(jwt.MapClaims{"user_id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(current, m.VerificationKey)
+	if err != nil {
+		t.Fatalf("token signed with the active key should be accepted: %v", err)
+	}
+	if token.Method.Alg() != models.SigningAlgorithmEdDSA {
+		t.Errorf("alg = %s, want EdDSA", token.Method.Alg())
+	}
+
+	// 共通鍵は JWKS で公開しない
+	if jwks := m.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != active.id {
+		t.Errorf("JWKS() = %+v, want only the active key", jwks)
+	}
+
+	// 切り替えから VerifyGrace が過ぎたら、切り替え前のトークンも受け付けない
+	m.legacyEnd = time.Now().Add(-2 * time.Hour)
+	if _, err := jwt.Parse(old, m.VerificationKey); !errors.Is(err, ErrUnknownSigningKey) {
+		t.Errorf("token after VerifyGrace: error = %v, want ErrUnknownSigningKey", err)
+	}
+}
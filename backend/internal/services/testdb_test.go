@@ -0,0 +1,97 @@
+package services
+
+import (
+	"anime-score-backend/internal/models"
+	"anime-score-backend/internal/repositories"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // pgxドライバー
+	"github.com/jmoiron/sqlx"
+)
+
+// openTestDB は TEST_DATABASE_URL のDBに接続する（未設定ならテストをスキップする）
+// テストはデータを作成・削除するので、開発用のDBではなく initdb/schema.sql を適用したテスト専用のDBを指定すること（手順はREADMEの「テスト」）
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser はテスト用のユーザーを作成し、テストの終わりに削除する（関連する行とログインの試行履歴も一緒に消す）
+func createTestUser(t *testing.T, db *sqlx.DB, passwordHash string) *models.User {
+	t.Helper()
+	suffix := time.Now().UnixNano()
+	user := &models.User{
+		Username:     fmt.Sprintf("test-%d", suffix),
+		Email:        fmt.Sprintf("test-%d@example.com", suffix),
+		PasswordHash: passwordHash,
+	}
+	if err := repositories.NewUserRepository(db).Create(user); err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+		// ログインの試行履歴はユーザーを消しても残るので、メールアドレスで消す
+		db.Exec(`DELETE FROM login_attempts WHERE email = $1`, user.Email)
+	})
+	return user
+}
+
+// newTestAuthService はテスト用のDBを使うAuthServiceを作る（署名は HS256、メール送信・ログイン制限はなし）
+func newTestAuthService(t *testing.T, db *sqlx.DB) *AuthService {
+	t.Helper()
+	keys, err := NewSigningKeyManager(nil, models.SigningKeyConfig{
+		Algorithm: models.SigningAlgorithmHS256,
+		Secret:    "test-secret",
+	})
+	if err != nil {
+		t.Fatalf("NewSigningKeyManager() error = %v", err)
+	}
+	return NewAuthService(
+		repositories.NewUserRepository(db),
+		repositories.NewTokenRevocationRepository(db),
+		repositories.NewRefreshTokenRepository(db),
+		keys,
+		nil,
+		nil,
+		models.TokenConfig{AccessTTL: time.Minute, RefreshTTL: time.Hour},
+	)
+}
+
+// testLoginClient はテストでログインやパスワードの再設定を行うクライアント
+var testLoginClient = models.LoginClient{IPAddress: "198.51.100.1", UserAgent: "test"}
+
+// newTestLoginAttemptService はテスト用のDBを使うLoginAttemptServiceを作る
+// 2回失敗すると1時間待たないとログインできない（ロックはしない）
+func newTestLoginAttemptService(t *testing.T, db *sqlx.DB) *LoginAttemptService {
+	t.Helper()
+	return NewLoginAttemptService(repositories.NewLoginAttemptRepository(db), models.LoginThrottleConfig{
+		Window:                  time.Hour,
+		BaseDelay:               time.Hour,
+		MaxDelay:                time.Hour,
+		LockoutDuration:         time.Hour,
+		AccountFreeAttempts:     2,
+		IPFreeAttempts:          100,
+		ThrottledRecordInterval: time.Minute,
+	})
+}
+
+// newTestLoginEmail はほかのテストと失敗回数が混ざらないメールアドレスを作り、テストの終わりに試行履歴を削除する
+func newTestLoginEmail(t *testing.T, db *sqlx.DB) string {
+	t.Helper()
+	email := fmt.Sprintf("login-%d@example.com", time.Now().UnixNano())
+	t.Cleanup(func() { db.Exec(`DELETE FROM login_attempts WHERE email = $1`, email) })
+	return email
+}
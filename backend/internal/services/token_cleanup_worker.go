@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TokenCleanupWorker は有効期限が過ぎて不要になったトークンの失効情報・リフレッシュトークン・パスワード再設定用のトークンと、
+// 保存期間を過ぎたログインの試行履歴を定期的に削除するバックグラウンド処理
+// 期限切れのトークンは検証で弾かれるので、記録を残しておく必要はない
+type TokenCleanupWorker struct {
+	authService          *AuthService
+	passwordResetService *PasswordResetService
+	loginAttemptService  *LoginAttemptService
+	interval             time.Duration
+}
+
+// NewTokenCleanupWorker はTokenCleanupWorkerのインスタンスを生成
+func NewTokenCleanupWorker(
+	authService *AuthService,
+	passwordResetService *PasswordResetService,
+	loginAttemptService *LoginAttemptService,
+	interval time.Duration,
+) *TokenCleanupWorker {
+	return &TokenCleanupWorker{
+		authService:          authService,
+		passwordResetService: passwordResetService,
+		loginAttemptService:  loginAttemptService,
+		interval:             interval,
+	}
+}
+
+// Run は ctx がキャンセルされるまで、一定間隔で期限切れのトークンを削除する
+// goroutine で呼び出すこと（例: go worker.Run(ctx)）
+func (w *TokenCleanupWorker) Run(ctx context.Context) {
+	if w.interval <= 0 {
+		log.Println("Token cleanup worker is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		// 起動直後に1回実行し、その後は interval ごとに実行する
+		deleted, err := w.authService.DeleteExpiredTokens()
+		if err != nil {
+			log.Println("Failed to delete expired tokens:", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired tokens", deleted)
+		}
+		deleted, err = w.passwordResetService.DeleteExpiredTokens()
+		if err != nil {
+			log.Println("Failed to delete expired password reset tokens:", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired password reset tokens", deleted)
+		}
+		deleted, err = w.loginAttemptService.DeleteOld()
+		if err != nil {
+			log.Println("Failed to delete old login attempts:", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d old login attempts", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -5,21 +5,63 @@ import { NextRequest, NextResponse } from "next/server";
 const BACKEND_URL = process.env.BACKEND_URL || "http://localhost:8080";
 
 // Cookie の設定値
+// 有効期限はバックエンドが返すトークンの有効期限（expiresAt / refreshExpiresAt）に合わせる
 const COOKIE_NAME = "auth_token";
-const COOKIE_MAX_AGE = 60 * 60 * 24; // 24時間（秒）
+const REFRESH_COOKIE_NAME = "refresh_token";
+
+// トークンを発行するエンドポイント（レスポンスのトークンを Cookie に変換する）
+const TOKEN_PATHS = ["login", "signup", "token/refresh"];
+// ログアウトのエンドポイント（Cookie を削除する）
+const LOGOUT_PATHS = ["logout", "logout/all"];
+
+// バックエンドが発行するトークンの組
+// 交換直後の同時リクエストへの再発行では、アクセストークンだけが返ってくる
+interface AuthTokens {
+  token: string;
+  expiresAt: string;
+  refreshToken?: string;
+  refreshExpiresAt?: string;
+}
+
+function isAuthTokens(data: Record<string, unknown>): boolean {
+  return (
+    typeof data.token === "string" &&
+    typeof data.expiresAt === "string" &&
+    (data.refreshToken === undefined ||
+      (typeof data.refreshToken === "string" &&
+        typeof data.refreshExpiresAt === "string"))
+  );
+}
+
+// 有効期限（ISO 8601）までの秒数
+function secondsUntil(expiresAt: string): number {
+  return Math.max(Math.floor((Date.parse(expiresAt) - Date.now()) / 1000), 1);
+}
 
 // ========== 認証用 Cookie を設定するヘルパー ==========
-function setAuthCookie(response: NextResponse, token: string) {
-  response.cookies.set(COOKIE_NAME, token, {
+function setAuthCookies(response: NextResponse, tokens: AuthTokens) {
+  response.cookies.set(COOKIE_NAME, tokens.token, {
     httpOnly: true,
     secure: process.env.NODE_ENV === "production",
     sameSite: "lax",
     path: "/",
-    maxAge: COOKIE_MAX_AGE,
+    maxAge: secondsUntil(tokens.expiresAt),
+  });
+  // リフレッシュトークンはトークンの再発行・ログアウトのときだけ使うので、パスを /api に絞る
+  // アクセストークンだけが返ってきた場合は、先に交換したリクエストが保存した Cookie を残す
+  if (!tokens.refreshToken || !tokens.refreshExpiresAt) {
+    return;
+  }
+  response.cookies.set(REFRESH_COOKIE_NAME, tokens.refreshToken, {
+    httpOnly: true,
+    secure: process.env.NODE_ENV === "production",
+    sameSite: "lax",
+    path: "/api",
+    maxAge: secondsUntil(tokens.refreshExpiresAt),
   });
 }
 
-function clearAuthCookie(response: NextResponse) {
+function clearAuthCookies(response: NextResponse) {
   response.cookies.set(COOKIE_NAME, "", {
     httpOnly: true,
     secure: process.env.NODE_ENV === "production",
@@ -27,6 +69,82 @@ function clearAuthCookie(response: NextResponse) {
     path: "/",
     maxAge: 0,
   });
+  response.cookies.set(REFRESH_COOKIE_NAME, "", {
+    httpOnly: true,
+    secure: process.env.NODE_ENV === "production",
+    sameSite: "lax",
+    path: "/api",
+    maxAge: 0,
+  });
+}
+
+// ========== バックエンドへのリクエスト ==========
+async function fetchBackend(
+  url: string,
+  method: string,
+  token: string | undefined,
+  body: string | undefined,
+  clientHeaders: Record<string, string> = {}
+) {
+  const headers: HeadersInit = {
+    "Content-Type": "application/json",
+    ...clientHeaders,
+  };
+
+  if (token) {
+    // Cookie のトークンを Authorization: Bearer ヘッダーに乗せ換え
+    headers["Authorization"] = `Bearer ${token}`;
+  }
+
+  return fetch(url, { method, headers, body });
+}
+
+// BFF の前にあり、X-Forwarded-For の右端に接続元のアドレスを追加するプロキシ（ロードバランサーなど）の数
+// プロキシを置かない場合は 1 のままにする（Next.js のサーバーが接続元のアドレスを入れる）
+const TRUSTED_PROXY_HOPS = Math.max(Number(process.env.TRUSTED_PROXY_HOPS) || 1, 1);
+
+// ブラウザの IP アドレス（信頼できるプロキシから見た接続元）
+// X-Forwarded-For の左側はブラウザが自由に書けるので、信頼できるプロキシが追加した右から TRUSTED_PROXY_HOPS 番目だけを使う
+function clientAddress(req: NextRequest): string | undefined {
+  const addresses = (req.headers.get("x-forwarded-for") ?? "")
+    .split(",")
+    .map((address) => address.trim())
+    .filter((address) => address !== "");
+  return addresses[addresses.length - TRUSTED_PROXY_HOPS];
+}
+
+// ブラウザの IP アドレスと User-Agent をバックエンドに伝えるヘッダー
+// BFF を経由するとバックエンドからは BFF の IP アドレスに見えるため、
+// ログインの失敗回数を IP アドレスごとに数えられるように X-Forwarded-For で渡す
+// ブラウザが送ってきた X-Forwarded-For はそのまま転送せず、接続元のアドレスだけで置き換える
+// （転送すると、偽のアドレスを付けて失敗回数の制限を逃れられてしまう）
+function clientHeaders(req: NextRequest): Record<string, string> {
+  const headers: Record<string, string> = {};
+  const address = clientAddress(req);
+  if (address) {
+    headers["X-Forwarded-For"] = address;
+  }
+  const userAgent = req.headers.get("user-agent");
+  if (userAgent) {
+    headers["User-Agent"] = userAgent;
+  }
+  return headers;
+}
+
+// リフレッシュトークンでアクセストークンを再発行する（失敗したら null）
+async function refreshTokens(refreshToken: string): Promise<AuthTokens | null> {
+  const res = await fetchBackend(
+    `${BACKEND_URL}/api/token/refresh`,
+    "POST",
+    undefined,
+    JSON.stringify({ refreshToken })
+  );
+  if (!res.ok) {
+    return null;
+  }
+
+  const data = await res.json().catch(() => ({}));
+  return isAuthTokens(data) ? (data as AuthTokens) : null;
 }
 
 // ========== BFF プロキシ本体 ==========
@@ -41,17 +159,8 @@ async function proxyToBackend(
 
   // ── Cookie からトークンを読み取り ──
   const cookieStore = await cookies();
-  const token = cookieStore.get(COOKIE_NAME)?.value;
-
-  // ── バックエンドへ送るヘッダーを構築 ──
-  const headers: HeadersInit = {
-    "Content-Type": "application/json",
-  };
-
-  if (token) {
-    // Cookie のトークンを Authorization: Bearer ヘッダーに乗せ換え
-    headers["Authorization"] = `Bearer ${token}`;
-  }
+  let token = cookieStore.get(COOKIE_NAME)?.value;
+  const refreshToken = cookieStore.get(REFRESH_COOKIE_NAME)?.value;
 
   // ── リクエストボディの転送（GET/HEAD 以外） ──
   let body: string | undefined;
@@ -59,12 +168,40 @@ async function proxyToBackend(
     body = await req.text();
   }
 
+  // ── token/refresh / logout: Cookie のリフレッシュトークンをボディに乗せる ──
+  if ((path === "token/refresh" || LOGOUT_PATHS.includes(path)) && !body && refreshToken) {
+    body = JSON.stringify({ refreshToken });
+  }
+
+  // ── アクセストークンの期限が切れていたら、リフレッシュトークンで再発行してから送る ──
+  // logout はリフレッシュトークンだけで失効させられるので、ログアウトのためにトークンを再発行しない
+  const canRefresh = !TOKEN_PATHS.includes(path) && path !== "logout";
+  let refreshed: AuthTokens | null = null;
+  let refreshFailed = false;
+  if (!token && refreshToken && canRefresh) {
+    refreshed = await refreshTokens(refreshToken);
+    refreshFailed = !refreshed;
+    token = refreshed?.token;
+  }
+
   // ── バックエンドへリクエスト ──
-  const backendRes = await fetch(backendUrl, {
-    method: req.method,
-    headers,
-    body,
-  });
+  const forwarded = clientHeaders(req);
+  let backendRes = await fetchBackend(backendUrl, req.method, token, body, forwarded);
+
+  // ── 401 ならリフレッシュトークンで再発行して1回だけ再送する ──
+  if (
+    backendRes.status === 401 &&
+    refreshToken &&
+    canRefresh &&
+    !refreshed &&
+    !refreshFailed
+  ) {
+    refreshed = await refreshTokens(refreshToken);
+    refreshFailed = !refreshed;
+    if (refreshed) {
+      backendRes = await fetchBackend(backendUrl, req.method, refreshed.token, body, forwarded);
+    }
+  }
 
   // ── レスポンスの処理 ──
   // JSON パースを試みる（失敗した場合は空オブジェクト）
@@ -75,29 +212,48 @@ async function proxyToBackend(
     data = {};
   }
 
-  // ── login / signup: レスポンスからトークンを取り出して Cookie にセット ──
-  if (
-    (path === "login" || path === "signup") &&
-    backendRes.ok &&
-    typeof data.token === "string"
-  ) {
+  // ── login / signup / token/refresh: レスポンスからトークンを取り出して Cookie にセット ──
+  if (TOKEN_PATHS.includes(path) && backendRes.ok && isAuthTokens(data)) {
+    const tokens = data as unknown as AuthTokens;
     // トークンをクライアントに返さないようコピーから除去
-    // data オブジェクトから token プロパティを取り出し、残りを rest に格納する
-    const { token: extractedToken, ...rest } = data;
+    const rest = { ...data };
+    delete rest.token;
+    delete rest.expiresAt;
+    delete rest.refreshToken;
+    delete rest.refreshExpiresAt;
     const response = NextResponse.json(rest, { status: backendRes.status });
-    setAuthCookie(response, extractedToken);
+    setAuthCookies(response, tokens);
+    return response;
+  }
+
+  // ── logout / logout/all: Cookie を削除 ──
+  // トークンが既に失効済み（401）の場合も、使えない Cookie が残らないように削除する
+  if (
+    LOGOUT_PATHS.includes(path) &&
+    (backendRes.ok || backendRes.status === 401)
+  ) {
+    const response = NextResponse.json(data, { status: backendRes.status });
+    clearAuthCookies(response);
     return response;
   }
 
-  // ── logout: Cookie を削除 ──
-  if (path === "logout" && backendRes.ok) {
+  // ── password/reset: すべての端末からログアウトされるので Cookie を削除 ──
+  if (path === "password/reset" && backendRes.ok) {
     const response = NextResponse.json(data, { status: backendRes.status });
-    clearAuthCookie(response);
+    clearAuthCookies(response);
     return response;
   }
 
   // ── その他: そのまま返す ──
-  return NextResponse.json(data, { status: backendRes.status });
+  const response = NextResponse.json(data, { status: backendRes.status });
+  if (refreshed) {
+    // 途中で再発行したトークンを Cookie に保存する
+    setAuthCookies(response, refreshed);
+  } else if (refreshFailed) {
+    // リフレッシュトークンも使えなければ、ログアウト状態にする
+    clearAuthCookies(response);
+  }
+  return response;
 }
 
 // ========== HTTP メソッドごとのハンドラー ==========
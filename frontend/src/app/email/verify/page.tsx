@@ -0,0 +1,83 @@
+"use client";
+
+import { Suspense, useEffect, useRef, useState } from "react";
+import { useSearchParams } from "next/navigation";
+import Link from "next/link";
+import { Header } from "@/components/Header";
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
+import { ApiError, verifyEmail } from "@/lib/api";
+
+// メールのリンク（/email/verify?token=xxx）から開くページ
+export default function VerifyEmailPage() {
+  return (
+    <div className="min-h-screen bg-gray-50">
+      <Header />
+
+      <main className="container mx-auto flex items-center justify-center px-4 py-16">
+        <Card className="w-full max-w-md">
+          <CardHeader>
+            <CardTitle className="text-center text-xl">メールアドレスの確認</CardTitle>
+          </CardHeader>
+          <CardContent>
+            {/* useSearchParams を使うコンポーネントは Suspense で囲む必要がある */}
+            <Suspense>
+              <VerifyEmailResult />
+            </Suspense>
+          </CardContent>
+        </Card>
+      </main>
+    </div>
+  );
+}
+
+function VerifyEmailResult() {
+  const token = useSearchParams().get("token");
+  const [message, setMessage] = useState<string | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  // 開発モードで useEffect が2回呼ばれても、確認のリクエストは1回だけ送る
+  const requested = useRef(false);
+
+  useEffect(() => {
+    if (!token || requested.current) return;
+    requested.current = true;
+
+    verifyEmail({ token })
+      .then((response) => setMessage(response.message))
+      .catch((err) => {
+        if (err instanceof ApiError) {
+          setError(err.message);
+        } else {
+          setError("メールアドレスの確認に失敗しました");
+        }
+      });
+  }, [token]);
+
+  if (!token || error) {
+    return (
+      <div className="space-y-4 text-center text-sm">
+        <div className="rounded bg-red-50 p-3 text-red-600">
+          {error ?? "リンクが正しくありません"}
+        </div>
+        <p className="text-gray-600">
+          マイページから確認メールを再送できます。
+        </p>
+        <Link href="/me" className="text-primary hover:underline">
+          マイページへ
+        </Link>
+      </div>
+    );
+  }
+
+  if (!message) {
+    return <p className="text-center text-gray-500">確認中...</p>;
+  }
+
+  return (
+    <div className="space-y-4 text-center text-sm">
+      <div className="rounded bg-green-50 p-3 text-green-700">{message}</div>
+      <Link href="/" className="text-primary hover:underline">
+        トップページへ
+      </Link>
+    </div>
+  );
+}
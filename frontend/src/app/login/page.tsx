@@ -101,6 +101,13 @@ export default function LoginPage() {
               </Button>
             </form>
 
+            {/* パスワード再設定リンク */}
+            <div className="mt-4 text-center text-sm text-gray-600">
+              <Link href="/password/forgot" className="text-primary hover:underline">
+                パスワードを忘れた方
+              </Link>
+            </div>
+
             {/* 会員登録リンク */}
             <div className="mt-4 text-center text-sm text-gray-600">
               アカウントをお持ちでない方は{" "}
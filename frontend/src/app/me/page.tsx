@@ -6,7 +6,7 @@ import Link from "next/link";
 import { Header } from "@/components/Header";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { useAuth } from "@/contexts/AuthContext";
-import { getMyReviews } from "@/lib/api";
+import { getMyReviews, resendVerificationEmail, ApiError } from "@/lib/api";
 import type { ReviewWithAnime } from "@/types";
 
 export default function MyPage() {
@@ -16,6 +16,8 @@ export default function MyPage() {
   const [reviews, setReviews] = useState<ReviewWithAnime[]>([]);
   const [isLoading, setIsLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
+  const [resendMessage, setResendMessage] = useState<string | null>(null);
+  const [isResending, setIsResending] = useState(false);
 
   // 未ログインならログインページへリダイレクト
   useEffect(() => {
@@ -44,6 +46,23 @@ export default function MyPage() {
     fetchReviews();
   }, [user]);
 
+  // 確認メールを再送
+  const handleResend = async () => {
+    setIsResending(true);
+    try {
+      const response = await resendVerificationEmail();
+      setResendMessage(response.message);
+    } catch (err) {
+      if (err instanceof ApiError) {
+        setResendMessage(err.message);
+      } else {
+        setResendMessage("確認メールの送信に失敗しました");
+      }
+    } finally {
+      setIsResending(false);
+    }
+  };
+
   // 認証確認中
   if (authLoading) {
     return (
@@ -70,6 +89,24 @@ export default function MyPage() {
       <main className="container mx-auto px-4 py-8">
         <h1 className="mb-6 text-2xl font-bold">マイページ</h1>
 
+        {/* メールアドレス未確認の案内 */}
+        {!user.email_verified_at && (
+          <div className="mb-6 rounded bg-yellow-50 p-4 text-sm text-yellow-800">
+            <p>
+              メールアドレスが確認されていません。届いたメールのリンクから確認してください。
+            </p>
+            <button
+              type="button"
+              onClick={handleResend}
+              disabled={isResending}
+              className="mt-2 text-primary hover:underline disabled:opacity-50"
+            >
+              {isResending ? "送信中..." : "確認メールを再送する"}
+            </button>
+            {resendMessage && <p className="mt-2">{resendMessage}</p>}
+          </div>
+        )}
+
         {/* ユーザー情報 */}
         <Card className="mb-8">
           <CardHeader>
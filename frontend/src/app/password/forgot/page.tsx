@@ -0,0 +1,109 @@
+"use client";
+
+import { useState } from "react";
+import Link from "next/link";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { z } from "zod";
+import { Header } from "@/components/Header";
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { ApiError, forgotPassword } from "@/lib/api";
+
+// バリデーションスキーマ
+const forgotSchema = z.object({
+  email: z.string().email("有効なメールアドレスを入力してください"),
+});
+
+type ForgotFormData = z.infer<typeof forgotSchema>;
+
+export default function ForgotPasswordPage() {
+  const [error, setError] = useState<string | null>(null);
+  const [message, setMessage] = useState<string | null>(null);
+
+  const {
+    register,
+    handleSubmit,
+    formState: { errors, isSubmitting },
+  } = useForm<ForgotFormData>({
+    resolver: zodResolver(forgotSchema),
+  });
+
+  const onSubmit = async (data: ForgotFormData) => {
+    setError(null);
+    try {
+      // 登録されていないメールアドレスでも同じメッセージが返る
+      const response = await forgotPassword(data);
+      setMessage(response.message);
+    } catch (err) {
+      if (err instanceof ApiError) {
+        setError(err.message);
+      } else {
+        setError("送信に失敗しました");
+      }
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gray-50">
+      <Header />
+
+      <main className="container mx-auto flex items-center justify-center px-4 py-16">
+        <Card className="w-full max-w-md">
+          <CardHeader>
+            <CardTitle className="text-center text-xl">パスワードの再設定</CardTitle>
+          </CardHeader>
+          <CardContent>
+            {message ? (
+              // 送信完了
+              <div className="rounded bg-green-50 p-3 text-sm text-green-700">
+                {message}
+              </div>
+            ) : (
+              <form onSubmit={handleSubmit(onSubmit)} className="space-y-4">
+                <p className="text-sm text-gray-600">
+                  登録したメールアドレスを入力してください。パスワード再設定用のリンクを送信します。
+                </p>
+
+                {/* エラー表示 */}
+                {error && (
+                  <div className="rounded bg-red-50 p-3 text-sm text-red-600">
+                    {error}
+                  </div>
+                )}
+
+                {/* メールアドレス */}
+                <div className="space-y-2">
+                  <Label htmlFor="email">メールアドレス</Label>
+                  <Input
+                    id="email"
+                    type="email"
+                    placeholder="example@mail.com"
+                    {...register("email")}
+                  />
+                  {errors.email && (
+                    <p className="text-sm text-red-500">{errors.email.message}</p>
+                  )}
+                </div>
+
+                {/* 送信ボタン */}
+                <Button type="submit" className="w-full" disabled={isSubmitting}>
+                  {isSubmitting ? "送信中..." : "再設定用のリンクを送信"}
+                </Button>
+              </form>
+            )}
+
+            {/* ログインリンク */}
+            <div className="mt-4 text-center text-sm text-gray-600">
+              <Link href="/login" className="text-primary hover:underline">
+                ログインに戻る
+              </Link>
+            </div>
+          </CardContent>
+        </Card>
+      </main>
+    </div>
+  );
+}
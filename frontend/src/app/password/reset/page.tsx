@@ -0,0 +1,135 @@
+"use client";
+
+import { Suspense, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import Link from "next/link";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { z } from "zod";
+import { Header } from "@/components/Header";
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { ApiError, resetPassword } from "@/lib/api";
+
+// バリデーションスキーマ
+const resetSchema = z
+  .object({
+    password: z.string().min(8, "パスワードは8文字以上で入力してください"),
+    confirmPassword: z.string(),
+  })
+  .refine((data) => data.password === data.confirmPassword, {
+    message: "パスワードが一致しません",
+    path: ["confirmPassword"],
+  });
+
+type ResetFormData = z.infer<typeof resetSchema>;
+
+// メールのリンク（/password/reset?token=xxx）から開くページ
+export default function ResetPasswordPage() {
+  return (
+    <div className="min-h-screen bg-gray-50">
+      <Header />
+
+      <main className="container mx-auto flex items-center justify-center px-4 py-16">
+        <Card className="w-full max-w-md">
+          <CardHeader>
+            <CardTitle className="text-center text-xl">新しいパスワードの設定</CardTitle>
+          </CardHeader>
+          <CardContent>
+            {/* useSearchParams を使うコンポーネントは Suspense で囲む必要がある */}
+            <Suspense>
+              <ResetPasswordForm />
+            </Suspense>
+          </CardContent>
+        </Card>
+      </main>
+    </div>
+  );
+}
+
+function ResetPasswordForm() {
+  const router = useRouter();
+  const token = useSearchParams().get("token");
+  const [error, setError] = useState<string | null>(null);
+
+  const {
+    register,
+    handleSubmit,
+    formState: { errors, isSubmitting },
+  } = useForm<ResetFormData>({
+    resolver: zodResolver(resetSchema),
+  });
+
+  const onSubmit = async (data: ResetFormData) => {
+    if (!token) return;
+    setError(null);
+    try {
+      await resetPassword({ token, password: data.password });
+      // すべての端末からログアウトされるので、新しいパスワードでログインし直してもらう
+      router.push("/login");
+    } catch (err) {
+      if (err instanceof ApiError) {
+        setError(err.message);
+      } else {
+        setError("パスワードの再設定に失敗しました");
+      }
+    }
+  };
+
+  if (!token) {
+    return (
+      <div className="space-y-4 text-center text-sm text-gray-600">
+        <p>リンクが正しくありません。もう一度再設定用のリンクを送信してください。</p>
+        <Link href="/password/forgot" className="text-primary hover:underline">
+          パスワードの再設定
+        </Link>
+      </div>
+    );
+  }
+
+  return (
+    <form onSubmit={handleSubmit(onSubmit)} className="space-y-4">
+      {/* エラー表示 */}
+      {error && (
+        <div className="rounded bg-red-50 p-3 text-sm text-red-600">
+          {error}
+        </div>
+      )}
+
+      {/* 新しいパスワード */}
+      <div className="space-y-2">
+        <Label htmlFor="password">新しいパスワード</Label>
+        <Input
+          id="password"
+          type="password"
+          placeholder="8文字以上"
+          {...register("password")}
+        />
+        {errors.password && (
+          <p className="text-sm text-red-500">{errors.password.message}</p>
+        )}
+      </div>
+
+      {/* 確認用 */}
+      <div className="space-y-2">
+        <Label htmlFor="confirmPassword">新しいパスワード（確認）</Label>
+        <Input
+          id="confirmPassword"
+          type="password"
+          placeholder="もう一度入力"
+          {...register("confirmPassword")}
+        />
+        {errors.confirmPassword && (
+          <p className="text-sm text-red-500">{errors.confirmPassword.message}</p>
+        )}
+      </div>
+
+      {/* 送信ボタン */}
+      <Button type="submit" className="w-full" disabled={isSubmitting}>
+        {isSubmitting ? "設定中..." : "パスワードを設定"}
+      </Button>
+    </form>
+  );
+}
@@ -7,11 +7,11 @@ import { Card, CardContent } from "@/components/ui/card";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { searchAnimes } from "@/lib/api";
-import type { AnnictWork } from "@/types";
+import type { AnimeSearchResult } from "@/types";
 
 export default function SearchPage() {
   const [keyword, setKeyword] = useState("");
-  const [results, setResults] = useState<AnnictWork[]>([]);
+  const [results, setResults] = useState<AnimeSearchResult[]>([]);
   const [isLoading, setIsLoading] = useState(false);
   const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -3,11 +3,16 @@ import type {
   SignUpResponse,
   LoginInput,
   LoginResponse,
+  ForgotPasswordInput,
+  ResetPasswordInput,
+  MessageResponse,
+  VerifyEmailInput,
   GetMeResponse,
   AnimeListResponse,
   AnimeSearchResponse,
   AnimeDetailResponse,
   ReviewListResponse,
+  ReviewSort,
   MyReviewListResponse,
   ReviewInput,
   ReviewCreateResponse,
@@ -97,6 +102,38 @@ export async function logout(): Promise<void> {
   await api.post<void>("/api/logout");
 }
 
+// すべての端末からログアウト（これまでに発行したトークンをすべて失効させる）
+export async function logoutAll(): Promise<void> {
+  await api.post<void>("/api/logout/all");
+}
+
+// パスワード再設定用のリンクをメールで送る
+// 登録されていないメールアドレスでも同じレスポンスが返る
+export async function forgotPassword(
+  input: ForgotPasswordInput
+): Promise<MessageResponse> {
+  return api.post<MessageResponse>("/api/password/forgot", input);
+}
+
+// メールのリンクのトークンで新しいパスワードを設定する（すべての端末からログアウトされる）
+export async function resetPassword(
+  input: ResetPasswordInput
+): Promise<MessageResponse> {
+  return api.post<MessageResponse>("/api/password/reset", input);
+}
+
+// メールのリンクのトークンでメールアドレスを確認済みにする
+export async function verifyEmail(
+  input: VerifyEmailInput
+): Promise<MessageResponse> {
+  return api.post<MessageResponse>("/api/email/verify", input);
+}
+
+// 確認メールを送り直す（短い間隔で送ると 429 になる）
+export async function resendVerificationEmail(): Promise<MessageResponse> {
+  return api.post<MessageResponse>("/api/email/verification/resend");
+}
+
 export async function getCurrentUser(): Promise<GetMeResponse> {
   return api.get<GetMeResponse>("/api/me");
 }
@@ -132,9 +169,15 @@ export async function getAnimeDetail(
 
 // ========== Review API ==========
 export async function getReviewsByAnime(
-  animeId: number
+  animeId: number,
+  sort: ReviewSort = "newest",
+  cursor?: string
 ): Promise<ReviewListResponse> {
-  return api.get<ReviewListResponse>(`/api/reviews?anime_id=${animeId}`);
+  let url = `/api/reviews?anime_id=${animeId}&sort=${sort}`;
+  if (cursor) {
+    url += `&cursor=${encodeURIComponent(cursor)}`;
+  }
+  return api.get<ReviewListResponse>(url);
 }
 
 export async function createReview(
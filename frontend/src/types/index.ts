@@ -4,6 +4,7 @@ export interface User {
   username: string;
   email: string;
   created_at: string;
+  email_verified_at: string | null; // メールアドレスを確認した日時（未確認ならnull）
 }
 
 // ========== Auth ==========
@@ -32,27 +33,98 @@ export interface GetMeResponse {
   user: User;
 }
 
+export interface ForgotPasswordInput {
+  email: string;
+}
+
+export interface ResetPasswordInput {
+  token: string;
+  password: string;
+}
+
+export interface MessageResponse {
+  message: string;
+}
+
+export interface VerifyEmailInput {
+  token: string;
+}
+
 // ========== Anime ==========
 export interface Anime {
   id: number;
-  annictId: number;
+  annictId: number | null; // Annictにない作品（AniListにしかない作品）はnull
   title: string;
+  titleKana: string | null;
+  titleEn: string | null;
   year: number;
+  season: Season | null;
+  media: string | null;
+  episodesCount: number;
   imageUrl: string | null;
+  officialSiteUrl: string | null;
+  twitterUsername: string | null;
+  wikipediaUrl: string | null;
   createdAt: string;
+  updatedAt: string;
+  syncedAt: string;
+  externalIds?: Record<string, number>; // 取得元ごとの作品ID (例: { annict: 1, anilist: 2 })
 }
 
+export type Season = "winter" | "spring" | "summer" | "autumn";
+
 export interface AnimeStats {
   animeId: number;
   reviewCount: number;
   avgScore: number;
+  median: number;
+  stdDev: number;
+  percentileRanks: ScorePercentileRank[]; // 付けられたスコアごとのパーセンタイル順位（スコアの昇順）
+  histogram: ScoreBucket[];
+  episodes: EpisodeStats[];
+}
+
+// エピソードごとの視聴数と平均点
+export interface EpisodeStats {
+  episodeId: number;
+  number: number | null;
+  numberText: string | null;
+  sortNumber: number;
+  title: string | null;
+  watchCount: number;
+  scoreCount: number;
+  avgScore: number | null;
+}
+
+export interface EpisodeWatch {
+  userId: number;
+  episodeId: number;
+  score: number | null;
+  watchedAt: string;
+}
+
+// スコアのパーセンタイル順位（0〜100。そのスコアがレビュー全体の下から何%の位置にあるか）
+export interface ScorePercentileRank {
+  score: number;
+  count: number;
+  percentileRank: number;
+}
+
+// スコア分布の1区間（min点以上max点以下）
+export interface ScoreBucket {
+  min: number;
+  max: number;
+  count: number;
 }
 
 export interface AnimeWithStats extends Anime {
   reviewCount: number;
   avgScore: number;
+  weightedScore: number;
 }
 
+export type AnimeSort = "average" | "bayesian" | "wilson";
+
 export interface Pagination {
   page: number;
   pageSize: number;
@@ -69,14 +141,30 @@ export interface AnimeListResponse {
 export interface AnnictWork {
   annictId: number;
   title: string;
+  titleKana: string;
+  titleEn: string;
   seasonYear: number | null;
+  seasonName: string | null;
+  media: string;
+  episodesCount: number;
+  officialSiteUrl: string;
+  twitterUsername: string;
+  wikipediaUrl: string;
   image: {
     recommendedImageUrl: string;
   };
 }
 
+// 検索結果 (DBに保存済みのアニメを優先し、足りない分をAnnictから取得)
+export interface AnimeSearchResult extends AnnictWork {
+  source: "local" | "annict";
+  reviewCount: number;
+  avgScore: number;
+  myScore: number | null; // ログイン中のみ (未レビューならnull)
+}
+
 export interface AnimeSearchResponse {
-  data: AnnictWork[];
+  data: AnimeSearchResult[];
   nextCursor: string | null;
 }
 
@@ -93,6 +181,17 @@ export interface Review {
   score: number;
   comment: string | null;
   createdAt: string;
+  updatedAt: string | null;
+  helpfulCount: number;
+  author?: ReviewAuthor;
+}
+
+// レビュー投稿者の公開プロフィール（email は含まない）
+export interface ReviewAuthor {
+  id: number;
+  username: string;
+  reviewCount: number;
+  joinedAt: string;
 }
 
 export interface ReviewInput {
@@ -108,8 +207,11 @@ export interface ReviewWithAnime extends Review {
   animeImageUrl: string | null;
 }
 
+export type ReviewSort = "newest" | "oldest" | "score_desc" | "score_asc" | "helpful";
+
 export interface ReviewListResponse {
   data: Review[];
+  nextCursor: string;
 }
 
 export interface MyReviewListResponse {
@@ -121,6 +223,36 @@ export interface ReviewCreateResponse {
   review: Review;
 }
 
+// ========== Library ==========
+export type WatchStatus = "watching" | "completed" | "dropped" | "plan_to_watch";
+
+export interface LibraryEntry {
+  animeId: number;
+  status: WatchStatus;
+  createdAt: string;
+  updatedAt: string;
+  animeAnnictId: number;
+  animeTitle: string;
+  animeYear: number;
+  animeSeason: Season | null;
+  animeImageUrl: string | null;
+}
+
+export interface LibraryListResponse {
+  data: LibraryEntry[];
+}
+
+export interface LibraryImportEntry {
+  annictId: number;
+  status: WatchStatus;
+}
+
+export interface LibraryImportResponse {
+  message: string;
+  imported: number;
+  notFound: number[];
+}
+
 // ========== API Error ==========
 export interface ApiError {
   error: string;
@@ -0,0 +1,16 @@
+-- 既存のデータベースに適用するマイグレーション
+-- initdb/schema.sql は新規作成時のみ実行されるため、稼働中のDBにはこのファイルを手動で適用する
+-- (docker-entrypoint-initdb.d はサブディレクトリを読まないので、新規作成時に二重に適用されることはない)
+
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS review_revisions (
+    id SERIAL PRIMARY KEY,
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL CHECK (score >= 0 AND score <= 100),
+    comment TEXT,
+    written_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    replaced_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_revisions_review_id ON review_revisions(review_id);
@@ -0,0 +1,11 @@
+-- アニメ別レビュー一覧のページネーション・並び替え対応
+
+CREATE TABLE IF NOT EXISTS review_helpful_votes (
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (review_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_anime_id_created_at ON reviews(anime_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_reviews_anime_id_score ON reviews(anime_id, score, id);
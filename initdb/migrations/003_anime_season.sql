@@ -0,0 +1,7 @@
+-- アニメ一覧の放送年・シーズン絞り込み対応
+-- 既存の行は season が NULL のままになる（Annictから再取得されたときに埋まる）
+
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS season VARCHAR(10)
+    CHECK (season IN ('winter', 'spring', 'summer', 'autumn'));
+
+CREATE INDEX IF NOT EXISTS idx_animes_year_season ON animes(year, season);
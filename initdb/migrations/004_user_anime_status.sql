@@ -0,0 +1,12 @@
+-- 視聴ステータス（ライブラリ）機能
+
+CREATE TABLE IF NOT EXISTS user_anime_status (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('watching', 'completed', 'dropped', 'plan_to_watch')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, anime_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_anime_status_user_status ON user_anime_status(user_id, status, updated_at);
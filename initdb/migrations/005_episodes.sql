@@ -0,0 +1,25 @@
+-- エピソード単位の視聴記録・スコア機能
+
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS episodes_synced_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS episodes (
+    id SERIAL PRIMARY KEY,
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    annict_id INTEGER UNIQUE NOT NULL,
+    number INTEGER,
+    number_text VARCHAR(50),
+    sort_number INTEGER NOT NULL,
+    title VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS episode_watches (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    episode_id INTEGER NOT NULL REFERENCES episodes(id) ON DELETE CASCADE,
+    score INTEGER CHECK (score >= 0 AND score <= 100),
+    watched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, episode_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_episodes_anime_id ON episodes(anime_id, sort_number);
+CREATE INDEX IF NOT EXISTS idx_episode_watches_episode_id ON episode_watches(episode_id);
@@ -0,0 +1,10 @@
+-- Annictの作品情報（放送形態・エピソード数・公式サイトなど）の保存
+-- 既存の行はNULLのままになる（Annictから再取得されたときに埋まる）
+
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS title_kana VARCHAR(255);
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS title_en VARCHAR(255);
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS media VARCHAR(10);
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS episodes_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS official_site_url VARCHAR(500);
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS twitter_username VARCHAR(100);
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS wikipedia_url VARCHAR(500);
@@ -0,0 +1,8 @@
+-- キャッシュ済みアニメ情報のバックグラウンド再取得
+-- 既存の行は synced_at が古い日時になるようにし、次回の再取得対象にする
+
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE animes SET synced_at = created_at WHERE created_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_animes_synced_at ON animes(synced_at);
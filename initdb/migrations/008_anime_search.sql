@@ -0,0 +1,21 @@
+-- DBに保存済みのアニメのタイトル検索 (カナ・全角半角の揺れを吸収した部分一致・あいまい一致)
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- アニメ検索用にタイトルを正規化する関数
+-- 全角英数字・半角カナをそろえ (NFKC)、小文字にし、カタカナをひらがなにし、空白と中黒を取り除く
+-- 「シンゲキ」「ｼﾝｹﾞｷ」「しんげき」や「ＲＥ：ゼロ」「re:ぜろ」が同じ文字列になる
+-- 複数のタイトル (正式名・読み仮名・英語名) は改行でつなぐ (NULLは無視される)
+CREATE OR REPLACE FUNCTION anime_search_text(VARIADIC titles TEXT[]) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(
+        lower(normalize(array_to_string(titles, E'\n'), NFKC)),
+        'ァアィイゥウェエォオカガキギクグケゲコゴサザシジスズセゼソゾタダチヂッツヅテデトドナニヌネノハバパヒビピフブプヘベペホボポマミムメモャヤュユョヨラリルレロヮワヰヱヲンヴヵヶ ・',
+        'ぁあぃいぅうぇえぉおかがきぎくぐけげこごさざしじすずせぜそぞただちぢっつづてでとどなにぬねのはばぱひびぴふぶぷへべぺほぼぽまみむめもゃやゅゆょよらりるれろゎわゐゑをんゔゕゖ'
+    )
+$$;
+
+ALTER TABLE animes ADD COLUMN IF NOT EXISTS search_text TEXT
+    GENERATED ALWAYS AS (anime_search_text(title, title_kana, title_en)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_animes_search_text ON animes USING gin (search_text gin_trgm_ops);
@@ -0,0 +1,15 @@
+-- アニメの外部ID対応表 (Annict以外のメタデータ取得元と作品を対応付ける)
+-- 既存のアニメは annict_id から Annict の対応を作成する
+
+CREATE TABLE IF NOT EXISTS anime_external_ids (
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    external_id INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (anime_id, provider),
+    UNIQUE (provider, external_id)
+);
+
+INSERT INTO anime_external_ids (anime_id, provider, external_id)
+SELECT id, 'annict', annict_id FROM animes
+ON CONFLICT DO NOTHING;
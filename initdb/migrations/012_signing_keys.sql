@@ -0,0 +1,12 @@
+-- JWTの署名鍵 (RS256 / EdDSA)
+-- 複数のプロセスで同じ鍵を使えるようにDBに保存する
+-- 新しい鍵を作ったら古い鍵は retired_at を設定して署名に使わなくし、expires_at までは検証用に残す
+
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,9 @@
+-- メールアドレスの確認
+-- email_verified_at: 確認用のリンクを開いた日時 (NULLなら未確認)
+-- email_verification_sent_at: 最後に確認メールを送った日時 (再送の間隔の制限に使う)
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_sent_at TIMESTAMP WITH TIME ZONE;
+
+-- 確認の仕組みができる前に登録したユーザーは、確認済みとして扱う
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
@@ -0,0 +1,20 @@
+-- ログインの試行履歴 (総当たり攻撃への対策と監査用)
+-- email は小文字にそろえて保存し、登録されていないメールアドレスへの試行も記録する
+-- result: success (成功) / invalid_credentials (メールアドレスかパスワードが違う) / throttled (試行回数が多すぎて拒否)
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    result VARCHAR(20) NOT NULL CHECK (result IN ('success', 'invalid_credentials', 'throttled')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email_created_at ON login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address_created_at ON login_attempts(ip_address, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
+
+-- 管理者 (ログインの試行履歴を閲覧できる)。UPDATE users SET is_admin = TRUE WHERE ... で設定する
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
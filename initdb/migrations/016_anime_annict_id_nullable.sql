@@ -0,0 +1,5 @@
+-- AniList など、Annict にない作品も保存できるようにする
+-- 取得元ごとの作品IDは anime_external_ids で管理し、animes.annict_id は Annict の作品IDの写し (Annict にない作品は NULL)
+-- UNIQUE 制約は NULL 同士では重複とみなされないので、そのまま残す
+
+ALTER TABLE animes ALTER COLUMN annict_id DROP NOT NULL;
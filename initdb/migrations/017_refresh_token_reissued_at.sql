@@ -0,0 +1,4 @@
+-- 交換済みのリフレッシュトークンで、猶予時間内に1回だけ発行し直した日時
+-- 同じトークンでの同時リクエストには1回だけ発行し直し、それ以降の使用は再利用（盗難）とみなしてファミリーごと失効させる
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS reissued_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,6 @@
+-- ログインの試行履歴に、パスワードを再設定したことも記録する
+-- password_reset: パスワードを再設定した (メールアドレスの失敗回数がリセットされ、ロックが解ける)
+
+ALTER TABLE login_attempts DROP CONSTRAINT IF EXISTS login_attempts_result_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_result_check
+    CHECK (result IN ('success', 'invalid_credentials', 'throttled', 'password_reset'));
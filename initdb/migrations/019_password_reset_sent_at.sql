@@ -0,0 +1,10 @@
+-- パスワード再設定メールの再送の間隔を、送信日時の記録で制限する
+-- password_reset_sent_at: 最後にパスワード再設定メールを送った日時
+-- 送ってよいかの確認と記録を1つのUPDATEで行い、同時に頼まれても送るのは1通だけにする
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_sent_at TIMESTAMP WITH TIME ZONE;
+
+-- これまでは最後にトークンを発行した日時で制限していたので、それを引き継ぐ
+UPDATE users SET password_reset_sent_at = latest.created_at
+FROM (SELECT user_id, MAX(created_at) AS created_at FROM password_reset_tokens GROUP BY user_id) AS latest
+WHERE users.id = latest.user_id;
@@ -1,19 +1,135 @@
+-- あいまい検索 (トライグラム) 用の拡張機能
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- アニメ検索用にタイトルを正規化する関数
+-- 全角英数字・半角カナをそろえ (NFKC)、小文字にし、カタカナをひらがなにし、空白と中黒を取り除く
+-- 「シンゲキ」「ｼﾝｹﾞｷ」「しんげき」や「ＲＥ：ゼロ」「re:ぜろ」が同じ文字列になる
+-- 複数のタイトル (正式名・読み仮名・英語名) は改行でつなぐ (NULLは無視される)
+CREATE OR REPLACE FUNCTION anime_search_text(VARIADIC titles TEXT[]) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(
+        lower(normalize(array_to_string(titles, E'\n'), NFKC)),
+        'ァアィイゥウェエォオカガキギクグケゲコゴサザシジスズセゼソゾタダチヂッツヅテデトドナニヌネノハバパヒビピフブプヘベペホボポマミムメモャヤュユョヨラリルレロヮワヰヱヲンヴヵヶ ・',
+        'ぁあぃいぅうぇえぉおかがきぎくぐけげこごさざしじすずせぜそぞただちぢっつづてでとどなにぬねのはばぱひびぴふぶぷへべぺほぼぽまみむめもゃやゅゆょよらりるれろゎわゐゑをんゔゕゖ'
+    )
+$$;
+
 --  Usersテーブル 
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(50) UNIQUE NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    email_verified_at TIMESTAMP WITH TIME ZONE,          -- メールアドレスを確認した日時 (NULLなら未確認)
+    email_verification_sent_at TIMESTAMP WITH TIME ZONE, -- 最後に確認メールを送った日時
+    password_reset_sent_at TIMESTAMP WITH TIME ZONE,     -- 最後にパスワード再設定メールを送った日時
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE              -- 管理者 (ログインの試行履歴を閲覧できる)
+);
+
+--  ログインの試行履歴 (総当たり攻撃への対策と監査用。登録されていないメールアドレスへの試行も記録)
+CREATE TABLE login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,                  -- 小文字にそろえたメールアドレス
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL, -- 登録済みのメールアドレスならそのユーザー
+    ip_address VARCHAR(64) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    result VARCHAR(20) NOT NULL CHECK (result IN ('success', 'invalid_credentials', 'throttled', 'password_reset')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+--  失効させたJWT (ログアウト済みのトークン)
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,                  -- トークンID (JWTの jti クレーム)
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- トークンの有効期限 (過ぎたら削除してよい)
+    revoked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+--  「すべての端末からログアウト」した日時 (これ以前に発行されたトークンはすべて無効)
+CREATE TABLE user_token_revocations (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+--  リフレッシュトークン (トークン自体ではなくハッシュを保存)
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,          -- トークンの SHA-256 (16進数)
+    family_id VARCHAR(64) NOT NULL,               -- 同じログインから交換され続けたトークンのグループ
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP WITH TIME ZONE,          -- 新しいトークンに交換済みならその日時
+    reissued_at TIMESTAMP WITH TIME ZONE,         -- 交換済みのトークンで猶予時間内に1回だけアクセストークンを発行し直した日時
+    revoked_at TIMESTAMP WITH TIME ZONE           -- 失効させた日時
+);
+
+--  パスワード再設定用のトークン (トークン自体ではなくハッシュを保存。1回だけ使える)
+CREATE TABLE password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,          -- トークンの SHA-256 (16進数)
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,             -- 使用済み (または無効化した) 日時
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+--  JWTの署名鍵 (RS256 / EdDSA の秘密鍵。kid で識別)
+CREATE TABLE signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,           -- RS256 / EdDSA
+    private_key TEXT NOT NULL,                -- PKCS#8 の PEM
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE,      -- 署名に使わなくなった日時 (NULLなら現在の署名鍵)
+    expires_at TIMESTAMP WITH TIME ZONE       -- 検証にも使わなくなる日時
+);
+
 --  Animesテーブル (Annict APIデータのキャッシュ)
 CREATE TABLE animes (
     id SERIAL PRIMARY KEY,
-    annict_id INTEGER UNIQUE NOT NULL,   -- Annict API の作品ID
+    annict_id INTEGER UNIQUE,            -- Annict API の作品ID (AniListにしかない作品はNULL。取得元ごとのIDは anime_external_ids)
     title VARCHAR(255) NOT NULL,
+    title_kana VARCHAR(255),            -- タイトルの読み仮名
+    title_en VARCHAR(255),              -- 英語タイトル
     year INTEGER NOT NULL,              -- 放送年 (例: 2024)
+    season VARCHAR(10) CHECK (season IN ('winter', 'spring', 'summer', 'autumn')), -- 放送シーズン (不明な場合はNULL)
+    media VARCHAR(10),                  -- 放送形態 (tv / ova / movie / web / other)
+    episodes_count INTEGER NOT NULL DEFAULT 0, -- エピソード数
     image_url VARCHAR(500),             -- 作品画像URL (Annict APIから取得)
+    official_site_url VARCHAR(500),     -- 公式サイトURL
+    twitter_username VARCHAR(100),      -- 公式Twitterのユーザー名
+    wikipedia_url VARCHAR(500),         -- WikipediaのURL
+    episodes_synced_at TIMESTAMP WITH TIME ZONE, -- エピソードをAnnictから取得した日時 (未取得ならNULL)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- 取得元の情報が最後に変わった日時 (再取得しても内容が同じなら変えない)
+    synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,  -- 最後にAnnictと同期した日時 (再取得の判定用)
+    -- 検索用の正規化したタイトル (タイトルが変わると自動で再計算される)
+    search_text TEXT GENERATED ALWAYS AS (anime_search_text(title, title_kana, title_en)) STORED
+);
+
+--  アニメの外部ID対応表 (Annict / AniList などの取得元ごとの作品ID)
+CREATE TABLE anime_external_ids (
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,      -- 取得元 (annict / anilist)
+    external_id INTEGER NOT NULL,       -- 取得元での作品ID
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- 1つのアニメにつき取得元ごとに1つだけ
+    PRIMARY KEY (anime_id, provider),
+    -- 同じ外部の作品を複数のアニメに対応付けない
+    UNIQUE (provider, external_id)
+);
+
+--  Episodesテーブル (Annict APIのエピソードのキャッシュ)
+CREATE TABLE episodes (
+    id SERIAL PRIMARY KEY,
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    annict_id INTEGER UNIQUE NOT NULL,  -- Annict API のエピソードID
+    number INTEGER,                     -- 話数 (総集編などはNULL)
+    number_text VARCHAR(50),            -- 表示用の話数 (例: 第1話)
+    sort_number INTEGER NOT NULL,       -- 並び順
+    title VARCHAR(255),                 -- サブタイトル
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -25,17 +141,88 @@ CREATE TABLE reviews (
     score INTEGER NOT NULL CHECK (score >= 0 AND score <= 100), -- 0~100点
     comment TEXT, -- NOT NULLを付けないので、NULL(未入力)が許可されます
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE, -- 編集されていない場合はNULL
     
     -- 1ユーザー1アニメにつき1レビューのみの制約
     UNIQUE(user_id, anime_id)
 );
 
+--  Review Revisionsテーブル (レビュー編集前の内容の履歴)
+CREATE TABLE review_revisions (
+    id SERIAL PRIMARY KEY,
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL CHECK (score >= 0 AND score <= 100),
+    comment TEXT,
+    written_at TIMESTAMP WITH TIME ZONE NOT NULL,  -- この内容が書かれた日時
+    replaced_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP -- 編集で置き換えられた日時
+);
+
+--  Review Helpful Votesテーブル (レビューへの「参考になった」)
+CREATE TABLE review_helpful_votes (
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- 1ユーザー1レビューにつき1票のみ
+    PRIMARY KEY (review_id, user_id)
+);
+
+--  Episode Watchesテーブル (エピソードの視聴記録, スコアは任意)
+CREATE TABLE episode_watches (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    episode_id INTEGER NOT NULL REFERENCES episodes(id) ON DELETE CASCADE,
+    score INTEGER CHECK (score >= 0 AND score <= 100), -- NULLならスコアなし
+    watched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, episode_id)
+);
+
+--  User Anime Statusテーブル (視聴ステータス: スコアを付けずに視聴状況だけ記録できる)
+CREATE TABLE user_anime_status (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    anime_id INTEGER NOT NULL REFERENCES animes(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('watching', 'completed', 'dropped', 'plan_to_watch')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- 1ユーザー1アニメにつき1ステータスのみ
+    PRIMARY KEY (user_id, anime_id)
+);
+
 --  インデックス (クエリパフォーマンス向上)
 -- インデックスはinsertやupdateが遅くなる
 CREATE INDEX idx_reviews_user_id ON reviews(user_id);
 CREATE INDEX idx_reviews_anime_id ON reviews(anime_id);
+CREATE INDEX idx_review_revisions_review_id ON review_revisions(review_id);
+-- アニメ別レビュー一覧の並び替え (新着順・スコア順) 用
+CREATE INDEX idx_reviews_anime_id_created_at ON reviews(anime_id, created_at, id);
+CREATE INDEX idx_reviews_anime_id_score ON reviews(anime_id, score, id);
 CREATE INDEX idx_animes_title ON animes(title);
 CREATE INDEX idx_animes_annict_id ON animes(annict_id);
+-- エピソード一覧・エピソード別統計用
+CREATE INDEX idx_episodes_anime_id ON episodes(anime_id, sort_number);
+CREATE INDEX idx_episode_watches_episode_id ON episode_watches(episode_id);
+-- ライブラリのステータス別一覧用
+CREATE INDEX idx_user_anime_status_user_status ON user_anime_status(user_id, status, updated_at);
+-- タイトル検索 (部分一致・あいまい一致) 用のトライグラムインデックス
+CREATE INDEX idx_animes_search_text ON animes USING gin (search_text gin_trgm_ops);
+-- 古くなったキャッシュの再取得対象の検索用
+CREATE INDEX idx_animes_synced_at ON animes(synced_at);
+-- 放送年・シーズンでのランキング絞り込み用
+CREATE INDEX idx_animes_year_season ON animes(year, season);
+-- 有効期限切れの失効トークンの削除用
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+-- リフレッシュトークンの失効 (ファミリー単位・ユーザー単位) と期限切れの削除用
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+-- パスワード再設定用のトークンの無効化 (ユーザー単位) と期限切れの削除用
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+CREATE INDEX idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+-- ログインの失敗回数 (メールアドレス単位・IPアドレス単位) の集計と、古い試行履歴の削除用
+CREATE INDEX idx_login_attempts_email_created_at ON login_attempts(email, created_at);
+CREATE INDEX idx_login_attempts_ip_address_created_at ON login_attempts(ip_address, created_at);
+CREATE INDEX idx_login_attempts_created_at ON login_attempts(created_at);
 
 --  アニメごとの統計情報を表示するビュー
 -- ビューは簡単に言えばよく使う長いクエリをショートカット化するもの